@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lyenrowe/LicenseCenter/internal/config"
+	"github.com/lyenrowe/LicenseCenter/internal/database"
+	"github.com/lyenrowe/LicenseCenter/internal/services"
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+	"github.com/lyenrowe/LicenseCenter/pkg/logger"
+)
+
+// 服务端密钥工具：离线根密钥为服务端签名密钥签发短期证书，主密钥加密数据库中的服务端私钥
+//
+//	keytool root       -alg EdDSA -out root           在离线环境生成根密钥对
+//	keytool export     -config configs/app.yaml       导出当前签名密钥的证书请求
+//	keytool certify    -root root.key -req req.json   在离线环境用根私钥签发证书
+//	keytool import     -config configs/app.yaml -key-id ... -cert cert.txt
+//	keytool master-key -out master.key                生成加密服务端私钥的主密钥
+//	keytool rewrap     -config configs/app.yaml [-old-master-key old.key]
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "root":
+		generateRoot(args)
+	case "export":
+		exportRequest(args)
+	case "certify":
+		certify(args)
+	case "import":
+		importCertificate(args)
+	case "master-key":
+		generateMasterKey(args)
+	case "rewrap":
+		rewrapPrivateKeys(args)
+	default:
+		usage()
+	}
+}
+
+// usage 打印用法后退出
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: keytool <root|export|certify|import|master-key|rewrap> [参数]")
+	os.Exit(2)
+}
+
+// generateRoot 生成根密钥对，私钥应离线保存，公钥内置到客户端并配置到服务端
+func generateRoot(args []string) {
+	fs := flag.NewFlagSet("root", flag.ExitOnError)
+	algorithm := fs.String("alg", crypto.SignatureEdDSA, "根密钥签名算法")
+	out := fs.String("out", "root", "输出文件名前缀，生成<out>.key和<out>.pub")
+	fs.Parse(args)
+
+	keyPair, err := crypto.GenerateSigningKeyPair(*algorithm, 4096)
+	if err != nil {
+		log.Fatalf("生成根密钥失败: %v", err)
+	}
+	publicKey, err := crypto.ParsePublicKeyPEM(keyPair.PublicKeyPEM)
+	if err != nil {
+		log.Fatalf("解析根公钥失败: %v", err)
+	}
+	keyID, err := crypto.KeyIDFromPublicKey(publicKey)
+	if err != nil {
+		log.Fatalf("计算根密钥ID失败: %v", err)
+	}
+
+	if err := os.WriteFile(*out+".key", []byte(keyPair.PrivateKeyPEM), 0600); err != nil {
+		log.Fatalf("写入根私钥失败: %v", err)
+	}
+	if err := os.WriteFile(*out+".pub", []byte(keyPair.PublicKeyPEM), 0644); err != nil {
+		log.Fatalf("写入根公钥失败: %v", err)
+	}
+
+	fmt.Printf("✓ 根密钥已生成 (%s)\n", *algorithm)
+	fmt.Printf("根密钥ID: %s\n", keyID)
+	fmt.Printf("私钥: %s.key（请离线保存）\n", *out)
+	fmt.Printf("公钥: %s.pub（内置到客户端，并配置到 security.root_public_key_file）\n", *out)
+}
+
+// exportRequest 导出签名密钥的证书请求（JSON格式）
+func exportRequest(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "configs/app.yaml", "配置文件路径")
+	keyID := fs.String("key-id", "", "签名密钥ID，为空时使用当前签名密钥")
+	fs.Parse(args)
+
+	rsaService := initService(*configPath)
+	request, err := rsaService.GetCertificateRequest(*keyID)
+	if err != nil {
+		log.Fatalf("导出证书请求失败: %v", err)
+	}
+
+	encoded, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		log.Fatalf("序列化证书请求失败: %v", err)
+	}
+	fmt.Println(string(encoded))
+}
+
+// certify 使用根私钥为证书请求签发证书，有效期从当前时间开始
+func certify(args []string) {
+	fs := flag.NewFlagSet("certify", flag.ExitOnError)
+	rootPath := fs.String("root", "root.key", "根私钥文件")
+	algorithm := fs.String("alg", crypto.SignatureEdDSA, "根密钥签名算法")
+	requestPath := fs.String("req", "", "证书请求文件（export命令的输出）")
+	days := fs.Int("days", 90, "证书有效天数")
+	fs.Parse(args)
+
+	rootPEM, err := os.ReadFile(*rootPath)
+	if err != nil {
+		log.Fatalf("读取根私钥失败: %v", err)
+	}
+	privateKey, err := crypto.ParsePrivateKeyPEM(string(rootPEM))
+	if err != nil {
+		log.Fatalf("解析根私钥失败: %v", err)
+	}
+	root, err := crypto.NewSigner(*algorithm, privateKey)
+	if err != nil {
+		log.Fatalf("创建根密钥签名器失败: %v", err)
+	}
+
+	requestData, err := os.ReadFile(*requestPath)
+	if err != nil {
+		log.Fatalf("读取证书请求失败: %v", err)
+	}
+	var request crypto.CertificateData
+	if err := json.Unmarshal(requestData, &request); err != nil {
+		log.Fatalf("解析证书请求失败: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	request.NotBefore = now
+	request.NotAfter = now.AddDate(0, 0, *days)
+
+	certificate, err := crypto.IssueCertificate(root, request)
+	if err != nil {
+		log.Fatalf("签发证书失败: %v", err)
+	}
+	fmt.Println(certificate)
+}
+
+// importCertificate 将签发的证书导入服务端，之后签发的文件附带该证书
+func importCertificate(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := fs.String("config", "configs/app.yaml", "配置文件路径")
+	keyID := fs.String("key-id", "", "签名密钥ID")
+	certPath := fs.String("cert", "", "证书文件（certify命令的输出）")
+	fs.Parse(args)
+
+	certificate, err := os.ReadFile(*certPath)
+	if err != nil {
+		log.Fatalf("读取证书失败: %v", err)
+	}
+
+	rsaService := initService(*configPath)
+	if err := rsaService.ImportCertificate(*keyID, strings.TrimSpace(string(certificate))); err != nil {
+		log.Fatalf("导入证书失败: %v", err)
+	}
+
+	fmt.Printf("✓ 已导入签名密钥 %s 的证书\n", *keyID)
+}
+
+// generateMasterKey 生成主密钥文件，配置到 security.master_key_file 或 LICENSE_MASTER_KEY 环境变量
+func generateMasterKey(args []string) {
+	fs := flag.NewFlagSet("master-key", flag.ExitOnError)
+	out := fs.String("out", "master.key", "主密钥文件")
+	fs.Parse(args)
+
+	masterKey, err := crypto.GenerateMasterKey()
+	if err != nil {
+		log.Fatalf("生成主密钥失败: %v", err)
+	}
+	if err := os.WriteFile(*out, []byte(masterKey+"\n"), 0600); err != nil {
+		log.Fatalf("写入主密钥失败: %v", err)
+	}
+
+	fmt.Printf("✓ 主密钥已写入 %s（请妥善备份，丢失后无法解密服务端私钥）\n", *out)
+}
+
+// rewrapPrivateKeys 使用当前配置的主密钥重新加密数据库中的服务端私钥
+// 首次启用主密钥时加密明文私钥；更换主密钥时通过 -old-master-key 传入旧主密钥
+func rewrapPrivateKeys(args []string) {
+	fs := flag.NewFlagSet("rewrap", flag.ExitOnError)
+	configPath := fs.String("config", "configs/app.yaml", "配置文件路径")
+	oldKeyPath := fs.String("old-master-key", "", "旧主密钥文件，更换主密钥时使用")
+	fs.Parse(args)
+
+	var previous []crypto.KeyWrapper
+	if *oldKeyPath != "" {
+		encoded, err := os.ReadFile(*oldKeyPath)
+		if err != nil {
+			log.Fatalf("读取旧主密钥失败: %v", err)
+		}
+		wrapper, err := services.LoadLocalKeyWrapper(string(encoded))
+		if err != nil {
+			log.Fatalf("解析旧主密钥失败: %v", err)
+		}
+		previous = append(previous, wrapper)
+	}
+
+	rsaService := initService(*configPath)
+	count, err := rsaService.RewrapPrivateKeys(previous...)
+	if err != nil {
+		log.Fatalf("重新加密服务端私钥失败: %v", err)
+	}
+
+	fmt.Printf("✓ 已重新加密 %d 个服务端私钥\n", count)
+}
+
+// initService 初始化配置、日志和数据库
+func initService(configPath string) *services.RSAService {
+	if err := config.LoadConfig(configPath); err != nil {
+		log.Fatalf("配置加载失败: %v", err)
+	}
+	if err := logger.InitLogger("info", "logs/app.log"); err != nil {
+		log.Fatalf("日志初始化失败: %v", err)
+	}
+	if err := database.InitDatabase(&config.AppConfig.Database); err != nil {
+		log.Fatalf("数据库初始化失败: %v", err)
+	}
+
+	return services.NewRSAService()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/lyenrowe/LicenseCenter/internal/config"
+	"github.com/lyenrowe/LicenseCenter/internal/database"
+	"github.com/lyenrowe/LicenseCenter/internal/services"
+	"github.com/lyenrowe/LicenseCenter/pkg/logger"
+)
+
+// 席位对账工具：根据有效设备重新计算各授权码的已用席位数，默认只报告不一致，-fix时修正
+func main() {
+	configPath := flag.String("config", "configs/app.yaml", "配置文件路径")
+	fix := flag.Bool("fix", false, "修正不一致的已用席位数")
+	flag.Parse()
+
+	// 初始化配置
+	if err := config.LoadConfig(*configPath); err != nil {
+		log.Fatalf("配置加载失败: %v", err)
+	}
+
+	// 初始化日志
+	if err := logger.InitLogger("info", "logs/app.log"); err != nil {
+		log.Fatalf("日志初始化失败: %v", err)
+	}
+
+	// 初始化数据库
+	if err := database.InitDatabase(&config.AppConfig.Database); err != nil {
+		log.Fatalf("数据库初始化失败: %v", err)
+	}
+
+	authService := services.NewAuthorizationService()
+	discrepancies, err := authService.ReconcileSeats(*fix)
+	if err != nil {
+		log.Fatalf("席位对账失败: %v", err)
+	}
+
+	if len(discrepancies) == 0 {
+		fmt.Println("✓ 所有授权码的已用席位数与有效设备一致")
+		return
+	}
+
+	fmt.Printf("发现 %d 个授权码的已用席位数不一致:\n", len(discrepancies))
+	fmt.Println("====================================")
+	for _, d := range discrepancies {
+		status := "未修正"
+		if d.Fixed {
+			status = "已修正"
+		}
+		fmt.Printf("%s (%s): 记录 %d，实际 %d，总席位 %d [%s]\n",
+			d.AuthorizationCode, d.CustomerName, d.RecordedSeats, d.ActualSeats, d.MaxSeats, status)
+	}
+
+	if !*fix {
+		fmt.Println("\n使用 -fix 参数修正以上记录")
+	}
+}
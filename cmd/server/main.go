@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"github.com/lyenrowe/LicenseCenter/internal/config"
 	"github.com/lyenrowe/LicenseCenter/internal/database"
 	"github.com/lyenrowe/LicenseCenter/internal/router"
+	"github.com/lyenrowe/LicenseCenter/internal/services"
 	"github.com/lyenrowe/LicenseCenter/pkg/logger"
 	"go.uber.org/zap"
 )
@@ -59,6 +61,15 @@ func main() {
 		zapLogger.Fatal("初始化系统数据失败", zap.Error(err))
 	}
 
+	// 预加载服务端密钥环，之后签名和解密不再每次查询数据库
+	if _, _, err := services.NewRSAService().GetActiveKeyPair(); err != nil {
+		zapLogger.Fatal("加载服务端密钥失败", zap.Error(err))
+	}
+
+	// 启动异步激活任务处理，会先继续上次关闭前未完成的任务
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	go services.NewActivationJobService().RunWorker(workerCtx)
+
 	// 设置路由
 	r := router.SetupRouter()
 	zapLogger.Info("路由设置完成")
@@ -81,6 +92,9 @@ func main() {
 
 	zapLogger.Info("正在关闭服务器...")
 
+	// 停止异步激活任务处理，处理中的任务在下次启动时继续
+	stopWorker()
+
 	// 关闭数据库连接
 	if err := database.DB.Close(); err != nil {
 		zapLogger.Error("关闭数据库连接失败", zap.Error(err))
@@ -2,12 +2,9 @@ package main
 
 import (
 	"crypto/md5"
-	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,49 +14,19 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lyenrowe/LicenseCenter/pkg/client"
 	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// BindFile 绑定请求文件结构
-type BindFile struct {
-	Hostname    string    `json:"hostname"`
-	MachineID   string    `json:"machine_id"`
-	RequestTime time.Time `json:"request_time"`
-}
-
-// LicenseFile 授权文件结构 (用于解析license文件)
-type LicenseFile struct {
-	LicenseData LicenseData `json:"license_data"`
-	Signature   string      `json:"signature"`
-}
-
-// LicenseData 授权数据结构
-type LicenseData struct {
-	LicenseKey       string    `json:"license_key"`
-	MachineID        string    `json:"machine_id"`
-	Hostname         string    `json:"hostname"`
-	IssuedAt         time.Time `json:"issued_at"`
-	ExpiresAt        time.Time `json:"expires_at"`
-	LicenseType      string    `json:"license_type"`
-	UnbindPrivateKey string    `json:"unbind_private_key"`
-}
-
-// UnbindFile 解绑文件结构
-type UnbindFile struct {
-	LicenseKey     string         `json:"license_key"`
-	MachineID      string         `json:"machine_id"`
-	UnbindMetadata UnbindMetadata `json:"unbind_metadata"`
-	UnbindProof    string         `json:"unbind_proof"`
-}
-
-// UnbindMetadata 解绑元数据
-type UnbindMetadata struct {
-	UnbindTime    time.Time `json:"unbind_time"`
-	Hostname      string    `json:"hostname"`
-	ClientVersion string    `json:"client_version"`
-	UnbindReason  string    `json:"unbind_reason"`
-}
+// 文件结构统一使用客户端SDK中的定义
+type (
+	BindFile       = client.BindFile
+	LicenseFile    = client.LicenseFile
+	LicenseData    = client.LicenseData
+	UnbindFile     = client.UnbindFile
+	UnbindMetadata = client.UnbindMetadata
+)
 
 // PublicKeyResponse 公钥响应结构
 type PublicKeyResponse struct {
@@ -325,25 +292,17 @@ func decryptLicenseFile(encryptedData []byte, aesKey []byte) ([]byte, error) {
 		return nil, fmt.Errorf("Base64解码失败: %v", err)
 	}
 
-	// 检查数据长度
-	if len(encryptedBytes) < 4 {
-		return nil, fmt.Errorf("加密数据格式错误：数据太短")
-	}
-
-	// 解析数据格式：读取AES密钥长度
-	keyLen := binary.BigEndian.Uint32(encryptedBytes[0:4])
-	if len(encryptedBytes) < int(4+keyLen) {
-		return nil, fmt.Errorf("加密数据格式错误：AES密钥数据不完整")
-	}
-
-	// 跳过RSA加密的AES密钥部分，直接取AES加密的数据
+	// 解析信封（兼容旧版无头格式），跳过RSA加密的AES密钥部分
 	// 客户端不需要解密RSA部分，因为它可以自己生成AES密钥
-	aesEncryptedData := encryptedBytes[4+keyLen:]
+	envelope, err := crypto.ParseEnvelope(encryptedBytes)
+	if err != nil {
+		return nil, err
+	}
 
 	// 使用客户端AES密钥解密数据
-	decryptedData, err := crypto.AESGCMDecrypt(aesEncryptedData, aesKey)
+	decryptedData, err := envelope.OpenWithAESKey(aesKey)
 	if err != nil {
-		return nil, fmt.Errorf("AES解密失败: %v", err)
+		return nil, err
 	}
 
 	return decryptedData, nil
@@ -373,37 +332,22 @@ func decryptLicenseFileWithServerKey(encryptedData []byte) ([]byte, error) {
 		return nil, fmt.Errorf("Base64解码失败: %v", err)
 	}
 
-	// 检查数据长度
-	if len(encryptedBytes) < 4 {
-		return nil, fmt.Errorf("加密数据格式错误：数据太短")
-	}
-
-	// 解析数据格式：读取AES密钥长度
-	keyLen := binary.BigEndian.Uint32(encryptedBytes[0:4])
-	if len(encryptedBytes) < int(4+keyLen) {
-		return nil, fmt.Errorf("加密数据格式错误：AES密钥数据不完整")
+	// 解析信封（兼容旧版无头格式）
+	envelope, err := crypto.ParseEnvelope(encryptedBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	// 提取RSA加密的AES密钥和AES加密的数据
-	encryptedAESKey := encryptedBytes[4 : 4+keyLen]
-	aesEncryptedData := encryptedBytes[4+keyLen:]
-
 	// 尝试获取服务端私钥
 	serverPrivateKey, err := getServerPrivateKey()
 	if err != nil {
 		return nil, fmt.Errorf("获取服务端私钥失败: %v", err)
 	}
 
-	// 使用RSA解密AES密钥
-	aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, serverPrivateKey, encryptedAESKey, nil)
-	if err != nil {
-		return nil, fmt.Errorf("RSA解密AES密钥失败: %v", err)
-	}
-
-	// 使用AES解密数据
-	decryptedData, err := crypto.AESGCMDecrypt(aesEncryptedData, aesKey)
+	// 使用RSA私钥解密AES密钥和数据
+	decryptedData, aesKey, err := envelope.Open(serverPrivateKey)
 	if err != nil {
-		return nil, fmt.Errorf("AES解密数据失败: %v", err)
+		return nil, err
 	}
 
 	fmt.Printf("🔓 成功使用服务端私钥解密，提取的AES密钥: %s\n", base64.StdEncoding.EncodeToString(aesKey))
@@ -474,7 +418,7 @@ func loadPrivateKeyFromDatabase() (*rsa.PrivateKey, error) {
 
 	// 查询活跃的RSA私钥
 	var privateKeyPEM string
-	err = db.QueryRow("SELECT private_key FROM rsa_keys WHERE is_active = 1 ORDER BY created_at DESC LIMIT 1").Scan(&privateKeyPEM)
+	err = db.QueryRow("SELECT private_key FROM rsa_keys WHERE status = 'active' ORDER BY created_at DESC LIMIT 1").Scan(&privateKeyPEM)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("数据库中没有找到活跃的RSA私钥")
@@ -482,6 +426,10 @@ func loadPrivateKeyFromDatabase() (*rsa.PrivateKey, error) {
 		return nil, fmt.Errorf("查询私钥失败: %v", err)
 	}
 
+	if crypto.IsSealedPrivateKey(privateKeyPEM) {
+		return nil, fmt.Errorf("数据库中的私钥已使用主密钥加密，请通过LICENSE_SERVER_PRIVATE_KEY环境变量提供私钥")
+	}
+
 	// 解析私钥
 	privateKey, err := crypto.LoadPrivateKeyFromPEM(privateKeyPEM)
 	if err != nil {
@@ -494,22 +442,11 @@ func loadPrivateKeyFromDatabase() (*rsa.PrivateKey, error) {
 
 // generateUnbindProof 生成解绑证明
 func generateUnbindProof(licenseKey, machineID, hostname, privateKeyPEM string, unbindTime time.Time) (string, error) {
-	// 解析私钥
-	privateKey, err := crypto.LoadPrivateKeyFromPEM(privateKeyPEM)
-	if err != nil {
-		return "", fmt.Errorf("解析私钥失败: %v", err)
-	}
-
-	// 构造待签名的数据（必须与服务端验证时的格式完全一致）
-	// 服务端验证格式：licenseKey:machineID:unbindTime(RFC3339):hostname
-	unbindData := fmt.Sprintf("%s:%s:%s:%s",
-		licenseKey,
-		machineID,
-		unbindTime.Format(time.RFC3339),
-		hostname)
+	// 构造待签名的数据（与服务端验证时共用同一格式）
+	unbindData := client.UnbindProofPayload(licenseKey, machineID, unbindTime, hostname)
 
-	// 使用私钥签名
-	signature, err := crypto.SignData(privateKey, []byte(unbindData))
+	// 使用私钥签名，RSA和Ed25519私钥均可
+	signature, err := crypto.SignUnbindProof(privateKeyPEM, unbindData)
 	if err != nil {
 		return "", fmt.Errorf("签名失败: %v", err)
 	}
@@ -575,7 +512,7 @@ func saveBindFile(bindData BindFile, encrypted bool) error {
 			clientAESKey := crypto.GenerateClientAESKey(bindData.MachineID)
 
 			// 使用客户端AES密钥进行混合加密
-			encryptedContent, err := crypto.EncryptFileToBase64WithClientKey(publicKey, jsonData, clientAESKey)
+			encryptedContent, err := crypto.SealFileToBase64(publicKey, crypto.FileTypeBind, jsonData, clientAESKey)
 			if err != nil {
 				return fmt.Errorf("加密bind数据失败: %v", err)
 			}
@@ -625,7 +562,7 @@ func saveUnbindFile(unbindFile UnbindFile, hostname string, encrypted bool) erro
 				return fmt.Errorf("序列化unbind数据失败: %v", err)
 			}
 
-			encryptedContent, err := crypto.EncryptFileToBase64(publicKey, jsonData)
+			encryptedContent, err := crypto.SealFileToBase64(publicKey, crypto.FileTypeUnbind, jsonData, nil)
 			if err != nil {
 				return fmt.Errorf("加密unbind数据失败: %v", err)
 			}
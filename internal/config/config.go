@@ -35,7 +35,14 @@ type SecurityConfig struct {
 	SessionTimeout      int    `mapstructure:"session_timeout"`
 	AdminSessionTimeout int    `mapstructure:"admin_session_timeout"`
 	RSAKeySize          int    `mapstructure:"rsa_key_size"`
-	ForceTOTP           bool   `mapstructure:"force_totp"` // 强制启用双因子认证
+	KeyGraceDays        int    `mapstructure:"key_grace_days"`        // 密钥轮换后旧密钥仍可解密的天数
+	ForceTOTP           bool   `mapstructure:"force_totp"`            // 强制启用双因子认证
+	KeyringCheckSeconds int    `mapstructure:"keyring_check_seconds"` // 检查其他实例是否轮换了密钥的间隔
+	SigningAlgorithm    string `mapstructure:"signing_algorithm"`     // 授权文件签名算法：RS256, PS256, ES256, EdDSA
+	RootPublicKeyFile   string `mapstructure:"root_public_key_file"`  // 离线根密钥的公钥文件（PEM），导入签名密钥证书时必须配置
+	RootKeyAlgorithm    string `mapstructure:"root_key_algorithm"`    // 离线根密钥的签名算法
+	MasterKeyFile       string `mapstructure:"master_key_file"`       // 加密服务端私钥的主密钥文件（Base64编码的32字节）
+	MasterKeyEnv        string `mapstructure:"master_key_env"`        // 未配置主密钥文件时读取主密钥的环境变量
 }
 
 type CaptchaConfig struct {
@@ -55,10 +62,16 @@ type LoggingConfig struct {
 }
 
 type SystemConfig struct {
-	MaxBindFilesPerRequest int    `mapstructure:"max_bind_files_per_request"`
-	BackupRetentionDays    int    `mapstructure:"backup_retention_days"`
-	DataDir                string `mapstructure:"data_dir"`
-	UploadDir              string `mapstructure:"upload_dir"`
+	MaxBindFilesPerRequest   int    `mapstructure:"max_bind_files_per_request"`
+	BackupRetentionDays      int    `mapstructure:"backup_retention_days"`
+	DataDir                  string `mapstructure:"data_dir"`
+	UploadDir                string `mapstructure:"upload_dir"`
+	TrialDays                int    `mapstructure:"trial_days"`                     // 试用授权默认天数
+	MaxTrialDays             int    `mapstructure:"max_trial_days"`                 // 试用授权最长天数
+	MaxBindFilesPerJob       int    `mapstructure:"max_bind_files_per_job"`         // 单个异步激活任务最多绑定文件数
+	ActivationJobPollSecs    int    `mapstructure:"activation_job_poll_seconds"`    // 后台检查待处理激活任务的间隔
+	ActivationJobTimeoutSecs int    `mapstructure:"activation_job_timeout_seconds"` // 处理中的激活任务超过该时间未更新心跳时重新排队
+	UnbindKeyType            string `mapstructure:"unbind_key_type"`                // 新签发授权的一次性解绑密钥类型：rsa（默认）, ed25519
 }
 
 var AppConfig *Config
@@ -98,7 +111,12 @@ func setDefaults() {
 	viper.SetDefault("security.session_timeout", 3600)
 	viper.SetDefault("security.admin_session_timeout", 1800)
 	viper.SetDefault("security.rsa_key_size", 2048)
+	viper.SetDefault("security.key_grace_days", 90)
 	viper.SetDefault("security.force_totp", false)
+	viper.SetDefault("security.keyring_check_seconds", 30)
+	viper.SetDefault("security.signing_algorithm", "RS256")
+	viper.SetDefault("security.root_key_algorithm", "EdDSA")
+	viper.SetDefault("security.master_key_env", "LICENSE_MASTER_KEY")
 
 	viper.SetDefault("captcha.enabled", true)
 
@@ -114,4 +132,10 @@ func setDefaults() {
 	viper.SetDefault("system.backup_retention_days", 30)
 	viper.SetDefault("system.data_dir", "./data")
 	viper.SetDefault("system.upload_dir", "./uploads")
+	viper.SetDefault("system.trial_days", 30)
+	viper.SetDefault("system.max_trial_days", 90)
+	viper.SetDefault("system.max_bind_files_per_job", 1000)
+	viper.SetDefault("system.activation_job_poll_seconds", 5)
+	viper.SetDefault("system.activation_job_timeout_seconds", 300)
+	viper.SetDefault("system.unbind_key_type", "rsa")
 }
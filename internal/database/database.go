@@ -6,6 +6,7 @@ import (
 
 	"github.com/lyenrowe/LicenseCenter/internal/config"
 	"github.com/lyenrowe/LicenseCenter/internal/models"
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -68,14 +69,79 @@ func InitDatabase(cfg *config.DatabaseConfig) error {
 
 // AutoMigrate 自动迁移数据库表结构
 func (d *Database) AutoMigrate() error {
-	return d.DB.AutoMigrate(
+	err := d.DB.AutoMigrate(
 		&models.Authorization{},
 		&models.License{},
 		&models.AdminUser{},
 		&models.AdminLog{},
 		&models.RSAKey{},
 		&models.SystemConfig{},
+		&models.SeatLedger{},
+		&models.ActivationJob{},
+		&models.ActivationJobItem{},
 	)
+	if err != nil {
+		return err
+	}
+
+	return d.migrateLegacyRSAKeys()
+}
+
+// migrateLegacyRSAKeys 为旧版密钥记录补全密钥ID和状态（旧版仅有is_active字段）
+func (d *Database) migrateLegacyRSAKeys() error {
+	var keys []models.RSAKey
+	err := d.DB.Where("key_id = ? OR key_id IS NULL OR status = ? OR status IS NULL", "", "").Find(&keys).Error
+	if err != nil {
+		return fmt.Errorf("查询旧版密钥失败: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	hasIsActive := d.DB.Migrator().HasColumn(&models.RSAKey{}, "is_active")
+
+	for _, key := range keys {
+		updates := map[string]interface{}{}
+
+		if key.KeyID == "" {
+			publicKey, err := crypto.LoadPublicKeyFromPEM(key.PublicKey)
+			if err != nil {
+				return fmt.Errorf("解析密钥 %d 的公钥失败: %w", key.ID, err)
+			}
+			keyID, err := crypto.KeyIDFromPublicKey(publicKey)
+			if err != nil {
+				return fmt.Errorf("计算密钥 %d 的ID失败: %w", key.ID, err)
+			}
+			updates["key_id"] = keyID
+		}
+
+		if key.Status == "" {
+			active := !hasIsActive
+			if hasIsActive {
+				var isActive bool
+				row := d.DB.Table(key.TableName()).Select("is_active").Where("id = ?", key.ID).Row()
+				if err := row.Scan(&isActive); err != nil {
+					return fmt.Errorf("读取密钥 %d 的旧状态失败: %w", key.ID, err)
+				}
+				active = isActive
+			}
+
+			if active {
+				updates["status"] = models.RSAKeyStatusActive
+			} else {
+				// 旧版停用的密钥不再用于解密
+				updates["status"] = models.RSAKeyStatusRetired
+				updates["retired_at"] = key.UpdatedAt
+				updates["grace_until"] = key.UpdatedAt
+			}
+		}
+
+		if err := d.DB.Model(&models.RSAKey{}).Where("id = ?", key.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("迁移密钥 %d 失败: %w", key.ID, err)
+		}
+	}
+
+	return nil
 }
 
 // GetDB 获取数据库实例
@@ -506,3 +506,75 @@ func (h *AdminHandler) VerifyTOTPSetup(c *gin.Context) {
 		"message": "TOTP验证成功",
 	})
 }
+
+// ListRSAKeys 获取RSA密钥列表
+func (h *AdminHandler) ListRSAKeys(c *gin.Context) {
+	keys, err := h.rsaService.ListKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取密钥列表失败",
+			"code":  50000,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": keys,
+	})
+}
+
+// RotateRSAKeys 轮换RSA密钥，旧密钥进入宽限期
+func (h *AdminHandler) RotateRSAKeys(c *gin.Context) {
+	if err := h.rsaService.RotateKeys(); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			c.JSON(appErr.HTTPStatus(), gin.H{
+				"error": appErr.Message,
+				"code":  appErr.Code,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "轮换密钥失败",
+				"code":  50000,
+			})
+		}
+		return
+	}
+
+	activeKey, err := h.rsaService.GetActiveKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取新密钥失败",
+			"code":  50000,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥轮换成功",
+		"data":    activeKey,
+	})
+}
+
+// RetireRSAKey 立即退役指定密钥
+func (h *AdminHandler) RetireRSAKey(c *gin.Context) {
+	keyID := c.Param("kid")
+
+	if err := h.rsaService.RetireKey(keyID); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			c.JSON(appErr.HTTPStatus(), gin.H{
+				"error": appErr.Message,
+				"code":  appErr.Code,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "退役密钥失败",
+				"code":  50000,
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥已退役",
+	})
+}
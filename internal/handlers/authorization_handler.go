@@ -75,6 +75,9 @@ func (h *AuthorizationHandler) CreateAuthorization(c *gin.Context) {
 			"duration_years":     auth.DurationYears,
 			"latest_expiry_date": auth.LatestExpiryDate,
 			"status":             auth.Status,
+			"edition":            auth.Edition,
+			"features":           auth.Features,
+			"limits":             auth.Limits,
 			"created_at":         auth.CreatedAt,
 		},
 	})
@@ -179,7 +182,7 @@ func (h *AuthorizationHandler) UpdateAuthorization(c *gin.Context) {
 		return
 	}
 
-	auth, err := h.authService.UpdateAuthorization(uint(id), &req)
+	auth, err := h.authService.WithActor(actorFromContext(c)).UpdateAuthorization(uint(id), &req)
 	if err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
 			c.JSON(appErr.HTTPStatus(), gin.H{
@@ -205,6 +208,9 @@ func (h *AuthorizationHandler) UpdateAuthorization(c *gin.Context) {
 			"duration_years":     auth.DurationYears,
 			"latest_expiry_date": auth.LatestExpiryDate,
 			"status":             auth.Status,
+			"edition":            auth.Edition,
+			"features":           auth.Features,
+			"limits":             auth.Limits,
 			"updated_at":         auth.UpdatedAt,
 		},
 	})
@@ -273,13 +279,20 @@ func (h *AuthorizationHandler) GetAuthorizationDetails(c *gin.Context) {
 	devices := make([]gin.H, 0, len(auth.Licenses))
 	for _, license := range auth.Licenses {
 		devices = append(devices, gin.H{
-			"id":           license.ID,
-			"hostname":     license.Hostname,
-			"machine_id":   license.MachineID,
-			"activated_at": license.ActivatedAt,
-			"expires_at":   license.ExpiresAt,
-			"status":       license.Status,
-			"unbound_at":   license.UnboundAt,
+			"id":                    license.ID,
+			"hostname":              license.Hostname,
+			"machine_id":            license.MachineID,
+			"activated_at":          license.ActivatedAt,
+			"expires_at":            license.ExpiresAt,
+			"status":                license.Status,
+			"unbound_at":            license.UnboundAt,
+			"license_type":          license.LicenseType,
+			"seat_exempt":           license.SeatExempt,
+			"unbind_info":           unbindInfo(&license, true),
+			"predecessor_id":        license.PredecessorID,
+			"transfer_type":         license.TransferType,
+			"successor_id":          license.SuccessorID,
+			"original_activated_at": license.FirstActivatedAt(),
 		})
 	}
 
@@ -293,6 +306,9 @@ func (h *AuthorizationHandler) GetAuthorizationDetails(c *gin.Context) {
 			"duration_years":     auth.DurationYears,
 			"latest_expiry_date": auth.LatestExpiryDate,
 			"status":             auth.Status,
+			"edition":            auth.Edition,
+			"features":           auth.Features,
+			"limits":             auth.Limits,
 			"created_at":         auth.CreatedAt,
 			"updated_at":         auth.UpdatedAt,
 			"devices":            devices,
@@ -315,3 +331,44 @@ func (h *AuthorizationHandler) GetStatistics(c *gin.Context) {
 		"data": stats,
 	})
 }
+
+// GetSeatLedger 分页获取授权码的席位台账
+func (h *AuthorizationHandler) GetSeatLedger(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的授权码ID",
+			"code":  40000,
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	if _, err := h.authService.GetAuthorizationByID(uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	entries, total, err := h.authService.ListSeatLedger(uint(id), page, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": entries,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
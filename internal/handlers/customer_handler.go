@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/lyenrowe/LicenseCenter/internal/models"
 	"github.com/lyenrowe/LicenseCenter/internal/services"
 	"github.com/lyenrowe/LicenseCenter/pkg/auth"
 	"github.com/lyenrowe/LicenseCenter/pkg/errors"
@@ -175,18 +176,32 @@ func (h *CustomerHandler) GetDashboard(c *gin.Context) {
 		}
 
 		deviceInfo := gin.H{
-			"id":         license.ID,
-			"hostname":   license.Hostname,
-			"machine_id": displayMachineID,
-			"issued_at":  license.IssuedAt,
-			"expires_at": license.ExpiresAt,
-			"status":     license.Status,
+			"id":            license.ID,
+			"hostname":      license.Hostname,
+			"machine_id":    displayMachineID,
+			"issued_at":     license.IssuedAt,
+			"expires_at":    license.ExpiresAt,
+			"status":        license.Status,
+			"license_type":  license.LicenseType,
+			"consumes_seat": license.ConsumesSeat(), // 停用该设备是否归还席位
 		}
 
 		if license.Status == "active" {
+			if !license.IsTrial() && authorization.HasTransferPolicy() {
+				allowance, err := h.licenseService.GetTransferAllowance(authorization, &license)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "获取转移额度失败",
+						"code":  50000,
+					})
+					return
+				}
+				deviceInfo["transfer_allowance"] = allowance
+			}
 			activeDevices = append(activeDevices, deviceInfo)
 		} else {
 			deviceInfo["unbound_at"] = license.UnboundAt
+			deviceInfo["unbind_info"] = unbindInfo(&license, false)
 			historicalDevices = append(historicalDevices, deviceInfo)
 		}
 	}
@@ -198,6 +213,15 @@ func (h *CustomerHandler) GetDashboard(c *gin.Context) {
 			"max_seats":          authorization.MaxSeats,
 			"used_seats":         authorization.UsedSeats,
 			"available_seats":    authorization.GetAvailableSeats(),
+			"edition":            authorization.Edition,
+			"features":           authorization.Features,
+			"limits":             authorization.Limits,
+			"transfer_policy": gin.H{
+				"max_transfers_per_period": authorization.MaxTransfersPerPeriod,
+				"transfer_period_days":     authorization.EffectiveTransferPeriodDays(),
+				"transfer_cooldown_days":   authorization.TransferCooldownDays,
+				"max_total_transfers":      authorization.MaxTotalTransfers,
+			},
 		},
 		"devices": gin.H{
 			"active":     activeDevices,
@@ -205,3 +229,24 @@ func (h *CustomerHandler) GetDashboard(c *gin.Context) {
 		},
 	})
 }
+
+// unbindInfo 整理设备的解绑信息，未解绑时返回nil；客户端不返回管理员身份
+func unbindInfo(license *models.License, includeOperator bool) gin.H {
+	if license.UnboundAt == nil {
+		return nil
+	}
+
+	info := gin.H{
+		"reason":          license.UnbindReason,
+		"hostname":        license.UnbindHostname,
+		"client_version":  license.UnbindClientVersion,
+		"requested_at":    license.UnbindRequestedAt,
+		"unbound_by_type": license.UnboundByType,
+	}
+	if includeOperator {
+		info["unbound_by_id"] = license.UnboundByID
+		info["unbound_by"] = license.UnboundBy
+	}
+
+	return info
+}
@@ -3,28 +3,35 @@ package handlers
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/lyenrowe/LicenseCenter/internal/models"
 	"github.com/lyenrowe/LicenseCenter/internal/services"
 )
 
 // LicenseHandler 授权处理器
 type LicenseHandler struct {
-	licenseService *services.LicenseService
-	rsaService     *services.RSAService
-	validator      *validator.Validate
+	licenseService       *services.LicenseService
+	activationJobService *services.ActivationJobService
+	rsaService           *services.RSAService
+	validator            *validator.Validate
 }
 
 // NewLicenseHandler 创建授权处理器
 func NewLicenseHandler() *LicenseHandler {
 	return &LicenseHandler{
-		licenseService: services.NewLicenseService(),
-		rsaService:     services.NewRSAService(),
-		validator:      validator.New(),
+		licenseService:       services.NewLicenseService(),
+		activationJobService: services.NewActivationJobService(),
+		rsaService:           services.NewRSAService(),
+		validator:            validator.New(),
 	}
 }
 
@@ -41,9 +48,14 @@ type TransferLicenseRequest struct {
 	BindFile          services.BindFile   `json:"bind_file" validate:"required"`
 }
 
+// RenewLicenseRequest 授权续期请求
+type RenewLicenseRequest struct {
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
 // GetPublicKey 获取服务端公钥
 func (h *LicenseHandler) GetPublicKey(c *gin.Context) {
-	publicKeyPEM, err := h.rsaService.GetPublicKeyPEM()
+	activeKey, err := h.rsaService.GetActiveKey()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "获取公钥失败",
@@ -52,8 +64,25 @@ func (h *LicenseHandler) GetPublicKey(c *gin.Context) {
 		return
 	}
 
+	signingKey, err := h.rsaService.GetActiveSigningKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取签名公钥失败",
+			"code":  50000,
+		})
+		return
+	}
+
+	// public_key用于加密绑定文件，signing_key用于验证授权文件签名，签名算法为RSA算法时两者相同
 	c.JSON(http.StatusOK, gin.H{
-		"public_key": publicKeyPEM,
+		"public_key": activeKey.PublicKey,
+		"key_id":     activeKey.KeyID,
+		"algorithm":  activeKey.SignatureAlgorithm(),
+		"signing_key": gin.H{
+			"public_key": signingKey.PublicKey,
+			"key_id":     signingKey.KeyID,
+			"algorithm":  signingKey.SignatureAlgorithm(),
+		},
 	})
 }
 
@@ -71,54 +100,19 @@ func (h *LicenseHandler) ActivateLicenses(c *gin.Context) {
 
 	authCode := username.(string)
 
-	// 解析上传的文件
-	form, err := c.MultipartForm()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "文件上传失败",
-			"code":  40000,
-		})
+	bindFiles, encryptedBindFiles, ok := readBindFiles(c)
+	if !ok {
 		return
 	}
 
-	bindFiles := form.File["bind_files"]
-	if len(bindFiles) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "请上传至少一个.bind文件",
-			"code":  40000,
-		})
+	// 部分成功模式：有效文件照常激活，失败原因写入ZIP中的manifest.json
+	if partial, _ := strconv.ParseBool(c.DefaultPostForm("partial", "false")); partial {
+		h.activateLicensesPartial(c, authCode, bindFiles, encryptedBindFiles)
 		return
 	}
 
-	// 读取并解密bind文件内容
-	var encryptedBindFiles []string
-	for _, fileHeader := range bindFiles {
-		file, err := fileHeader.Open()
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "无法读取文件: " + fileHeader.Filename,
-				"code":  40000,
-			})
-			return
-		}
-		defer file.Close()
-
-		content := make([]byte, fileHeader.Size)
-		_, err = file.Read(content)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "读取文件内容失败: " + fileHeader.Filename,
-				"code":  40000,
-			})
-			return
-		}
-
-		// 将文件内容作为加密数据处理
-		encryptedBindFiles = append(encryptedBindFiles, string(content))
-	}
-
 	// 使用LicenseService的加密激活方法
-	encryptedLicenseFiles, err := h.licenseService.ActivateLicensesEncrypted(authCode, encryptedBindFiles)
+	encryptedLicenseFiles, err := h.licenseService.WithActor(actorFromContext(c)).ActivateLicensesEncrypted(authCode, encryptedBindFiles)
 	if err != nil {
 		// 直接使用gin的Error方法，让错误处理中间件统一处理
 		c.Error(err)
@@ -170,6 +164,257 @@ func (h *LicenseHandler) createLicenseZip(encryptedLicenseFiles []services.Encry
 	return zipBuffer.Bytes(), nil
 }
 
+// PreviewActivation 激活预检，返回上传的绑定文件能否激活、激活后的到期时间和剩余席位，不做任何修改
+func (h *LicenseHandler) PreviewActivation(c *gin.Context) {
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "用户信息不完整",
+			"code":  40100,
+		})
+		return
+	}
+
+	bindFiles, encryptedBindFiles, ok := readBindFiles(c)
+	if !ok {
+		return
+	}
+
+	preview, err := h.licenseService.PreviewActivation(username.(string), encryptedBindFiles)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	for i := range preview.Items {
+		preview.Items[i].Filename = bindFiles[i].Filename
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": preview,
+	})
+}
+
+// readBindFiles 读取表单中上传的bind_files，失败时直接返回错误响应
+func readBindFiles(c *gin.Context) ([]*multipart.FileHeader, []string, bool) {
+	// 解析上传的文件
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "文件上传失败",
+			"code":  40000,
+		})
+		return nil, nil, false
+	}
+
+	bindFiles := form.File["bind_files"]
+	if len(bindFiles) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请上传至少一个.bind文件",
+			"code":  40000,
+		})
+		return nil, nil, false
+	}
+
+	// 读取bind文件内容，文件内容即加密数据
+	encryptedBindFiles := make([]string, 0, len(bindFiles))
+	for _, fileHeader := range bindFiles {
+		content, err := readUploadedFile(fileHeader)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "读取文件内容失败: " + fileHeader.Filename,
+				"code":  40000,
+			})
+			return nil, nil, false
+		}
+
+		encryptedBindFiles = append(encryptedBindFiles, string(content))
+	}
+
+	return bindFiles, encryptedBindFiles, true
+}
+
+// activationManifest 部分成功模式下ZIP中的激活结果清单
+type activationManifest struct {
+	Total     int                       `json:"total"`
+	Succeeded int                       `json:"succeeded"`
+	Failed    int                       `json:"failed"`
+	Results   []activationManifestEntry `json:"results"`
+}
+
+// activationManifestEntry 单个绑定文件的激活结果，成功时附带ZIP中的license文件名
+type activationManifestEntry struct {
+	services.ActivationResult
+	LicenseFile string `json:"license_file,omitempty"`
+}
+
+// activateLicensesPartial 以部分成功模式批量激活，返回包含license文件和manifest.json的ZIP
+func (h *LicenseHandler) activateLicensesPartial(c *gin.Context, authCode string, fileHeaders []*multipart.FileHeader, encryptedBindFiles []string) {
+	results, err := h.licenseService.WithActor(actorFromContext(c)).ActivateLicensesPartialEncrypted(authCode, encryptedBindFiles)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	for i := range results {
+		results[i].Filename = fileHeaders[i].Filename
+	}
+
+	h.sendActivationZip(c, results)
+}
+
+// sendActivationZip 返回包含成功的license文件和manifest.json的ZIP
+func (h *LicenseHandler) sendActivationZip(c *gin.Context, results []services.ActivationResult) {
+	manifest := activationManifest{
+		Total:   len(results),
+		Results: make([]activationManifestEntry, 0, len(results)),
+	}
+
+	var zipBuffer bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBuffer)
+	writeFile := func(name string, content []byte) error {
+		fileWriter, err := zipWriter.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = fileWriter.Write(content)
+		return err
+	}
+
+	for _, result := range results {
+		entry := activationManifestEntry{ActivationResult: result}
+
+		if result.Success {
+			manifest.Succeeded++
+			// 文件序号与上传顺序一致，便于和失败条目对照
+			entry.LicenseFile = fmt.Sprintf("license_%d.license", result.Index)
+			if err := writeFile(entry.LicenseFile, []byte(result.LicenseFile.EncryptedContent)); err != nil {
+				zipWriter.Close()
+				h.sendZipError(c)
+				return
+			}
+		} else {
+			manifest.Failed++
+		}
+
+		manifest.Results = append(manifest.Results, entry)
+	}
+
+	manifestContent, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		zipWriter.Close()
+		h.sendZipError(c)
+		return
+	}
+	if err := writeFile("manifest.json", manifestContent); err != nil {
+		zipWriter.Close()
+		h.sendZipError(c)
+		return
+	}
+	if err := zipWriter.Close(); err != nil {
+		h.sendZipError(c)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename=licenses.zip")
+	c.Header("Content-Length", fmt.Sprintf("%d", zipBuffer.Len()))
+	c.Header("X-Activation-Succeeded", strconv.Itoa(manifest.Succeeded))
+	c.Header("X-Activation-Failed", strconv.Itoa(manifest.Failed))
+	c.Data(http.StatusOK, "application/zip", zipBuffer.Bytes())
+}
+
+// CreateActivationJob 提交异步批量激活任务，适用于大批量绑定文件，立即返回任务ID
+func (h *LicenseHandler) CreateActivationJob(c *gin.Context) {
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "用户信息不完整",
+			"code":  40100,
+		})
+		return
+	}
+
+	bindFiles, encryptedBindFiles, ok := readBindFiles(c)
+	if !ok {
+		return
+	}
+
+	files := make([]services.ActivationJobFile, 0, len(bindFiles))
+	for i, fileHeader := range bindFiles {
+		files = append(files, services.ActivationJobFile{
+			Filename: fileHeader.Filename,
+			Content:  encryptedBindFiles[i],
+		})
+	}
+
+	job, err := h.activationJobService.CreateJob(username.(string), files, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data": job,
+	})
+}
+
+// GetActivationJob 查询异步激活任务的状态和进度
+func (h *LicenseHandler) GetActivationJob(c *gin.Context) {
+	authID, ok := customerAuthID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.activationJobService.GetJob(authID, c.Param("job_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": job,
+	})
+}
+
+// DownloadActivationJob 下载已完成任务的授权文件包，格式与部分成功模式的ZIP一致
+func (h *LicenseHandler) DownloadActivationJob(c *gin.Context) {
+	authID, ok := customerAuthID(c)
+	if !ok {
+		return
+	}
+
+	results, err := h.activationJobService.GetJobResults(authID, c.Param("job_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.sendActivationZip(c, results)
+}
+
+// customerAuthID 从JWT中获取客户端的授权码ID，失败时直接返回错误响应
+func customerAuthID(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "用户未认证",
+			"code":  40100,
+		})
+		return 0, false
+	}
+
+	return userID.(uint), true
+}
+
+// sendZipError 返回创建授权文件包失败的响应
+func (h *LicenseHandler) sendZipError(c *gin.Context) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "创建授权文件包失败",
+		"code":  50000,
+	})
+}
+
 // TransferLicense 授权转移
 func (h *LicenseHandler) TransferLicense(c *gin.Context) {
 	// 从JWT中获取用户信息
@@ -257,7 +502,7 @@ func (h *LicenseHandler) TransferLicense(c *gin.Context) {
 	}
 
 	// 调用服务层方法执行授权转移
-	encryptedLicenseFile, err := h.licenseService.TransferLicenseEncrypted(
+	encryptedLicenseFile, err := h.licenseService.WithActor(actorFromContext(c)).TransferLicenseEncrypted(
 		authCode,
 		string(unbindContent),
 		string(bindContent),
@@ -274,6 +519,56 @@ func (h *LicenseHandler) TransferLicense(c *gin.Context) {
 	c.Data(http.StatusOK, "application/octet-stream", []byte(encryptedLicenseFile.EncryptedContent))
 }
 
+// DeactivateLicense 客户上传解绑文件停用设备并归还席位
+func (h *LicenseHandler) DeactivateLicense(c *gin.Context) {
+	// 从JWT中获取用户信息
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "用户信息不完整",
+			"code":  40100,
+		})
+		return
+	}
+
+	authCode := username.(string)
+
+	fileHeader, err := c.FormFile("unbind_file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请上传一个.unbind文件",
+			"code":  40000,
+		})
+		return
+	}
+
+	unbindContent, err := readUploadedFile(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "读取解绑文件内容失败",
+			"code":  40000,
+		})
+		return
+	}
+
+	license, err := h.licenseService.WithActor(actorFromContext(c)).DeactivateLicenseEncrypted(authCode, string(unbindContent))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设备已停用",
+		"data": gin.H{
+			"id":            license.ID,
+			"hostname":      license.Hostname,
+			"status":        license.Status,
+			"unbound_at":    license.UnboundAt,
+			"seat_released": license.ConsumesSeat(),
+		},
+	})
+}
+
 // GetLicensesByAuth 获取授权码下的设备列表
 func (h *LicenseHandler) GetLicensesByAuth(c *gin.Context) {
 	authCode := c.Query("auth_code")
@@ -316,7 +611,7 @@ func (h *LicenseHandler) ForceUnbindLicense(c *gin.Context) {
 		req.Reason = "管理员强制解绑"
 	}
 
-	err = h.licenseService.ForceUnbindLicense(uint(id), req.Reason)
+	err = h.licenseService.WithActor(actorFromContext(c)).ForceUnbindLicense(uint(id), req.Reason)
 	if err != nil {
 		c.Error(err)
 		return
@@ -361,3 +656,394 @@ func (h *LicenseHandler) DownloadLicense(c *gin.Context) {
 	c.Header("Content-Length", fmt.Sprintf("%d", len(encryptedLicenseFile)))
 	c.Data(http.StatusOK, "application/octet-stream", encryptedLicenseFile)
 }
+
+// IssueTrialLicense 管理员为授权码签发试用授权
+func (h *LicenseHandler) IssueTrialLicense(c *gin.Context) {
+	authID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的授权码ID",
+			"code":  40000,
+		})
+		return
+	}
+
+	fileHeader, err := c.FormFile("bind_file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请上传一个.bind文件",
+			"code":  40000,
+		})
+		return
+	}
+
+	bindContent, err := readUploadedFile(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "读取绑定文件内容失败",
+			"code":  40000,
+		})
+		return
+	}
+
+	durationDays, _ := strconv.Atoi(c.DefaultPostForm("duration_days", "0"))
+	consumeSeat, _ := strconv.ParseBool(c.DefaultPostForm("consume_seat", "false"))
+
+	encryptedLicenseFile, license, err := h.licenseService.WithActor(actorFromContext(c)).IssueTrialLicenseEncrypted(uint(authID), string(bindContent), services.TrialLicenseOptions{
+		DurationDays: durationDays,
+		ConsumeSeat:  consumeSeat,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// 返回试用license文件
+	filename := fmt.Sprintf("%s.trial.license", license.Hostname)
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header("Content-Length", fmt.Sprintf("%d", len(encryptedLicenseFile.EncryptedContent)))
+	c.Data(http.StatusOK, "application/octet-stream", []byte(encryptedLicenseFile.EncryptedContent))
+}
+
+// ConvertTrialLicense 管理员将试用授权转为正式授权，转换后通过下载接口获取新的license文件
+func (h *LicenseHandler) ConvertTrialLicense(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的授权ID",
+			"code":  40000,
+		})
+		return
+	}
+
+	license, err := h.licenseService.WithActor(actorFromContext(c)).ConvertTrialLicense(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已转为正式授权，请重新下载授权文件",
+		"data": gin.H{
+			"id":           license.ID,
+			"hostname":     license.Hostname,
+			"machine_id":   license.MachineID,
+			"license_type": license.LicenseType,
+			"expires_at":   license.ExpiresAt,
+			"status":       license.Status,
+		},
+	})
+}
+
+// ReplaceDevice 管理员为无法生成解绑文件的故障设备更换新设备，返回新设备的license文件
+func (h *LicenseHandler) ReplaceDevice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的授权ID",
+			"code":  40000,
+		})
+		return
+	}
+
+	fileHeader, err := c.FormFile("bind_file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请上传新设备的.bind文件",
+			"code":  40000,
+		})
+		return
+	}
+
+	bindContent, err := readUploadedFile(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "读取绑定文件内容失败",
+			"code":  40000,
+		})
+		return
+	}
+
+	reason := c.PostForm("reason")
+	if reason == "" {
+		reason = "设备故障，管理员替换"
+	}
+
+	encryptedLicenseFile, license, err := h.licenseService.WithActor(actorFromContext(c)).ReplaceDeviceEncrypted(uint(id), string(bindContent), reason)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// 返回新设备的license文件
+	filename := fmt.Sprintf("%s.license", license.Hostname)
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header("Content-Length", fmt.Sprintf("%d", len(encryptedLicenseFile.EncryptedContent)))
+	c.Data(http.StatusOK, "application/octet-stream", []byte(encryptedLicenseFile.EncryptedContent))
+}
+
+// GetLicenseLineage 管理员按授权ID或机器ID查询设备转移链路
+func (h *LicenseHandler) GetLicenseLineage(c *gin.Context) {
+	var (
+		lineage *services.LicenseLineage
+		err     error
+	)
+
+	if licenseID := c.Query("license_id"); licenseID != "" {
+		id, parseErr := strconv.ParseUint(licenseID, 10, 32)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "无效的授权ID",
+				"code":  40000,
+			})
+			return
+		}
+		lineage, err = h.licenseService.GetLicenseLineage(uint(id))
+	} else if machineID := c.Query("machine_id"); machineID != "" {
+		lineage, err = h.licenseService.GetLicenseLineageByMachineID(machineID)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请提供授权ID或机器ID",
+			"code":  40000,
+		})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	devices := make([]gin.H, 0, len(lineage.Licenses))
+	for _, license := range lineage.Licenses {
+		devices = append(devices, gin.H{
+			"id":             license.ID,
+			"hostname":       license.Hostname,
+			"machine_id":     license.MachineID,
+			"activated_at":   license.ActivatedAt,
+			"expires_at":     license.ExpiresAt,
+			"status":         license.Status,
+			"unbound_at":     license.UnboundAt,
+			"predecessor_id": license.PredecessorID,
+			"transfer_type":  license.TransferType,
+			"successor_id":   license.SuccessorID,
+			"unbind_info":    unbindInfo(&license, true),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"authorization_id":      lineage.Licenses[0].AuthorizationID,
+			"root_license_id":       lineage.RootLicenseID,
+			"original_activated_at": lineage.OriginalActivatedAt,
+			"licenses":              devices,
+		},
+	})
+}
+
+// RenewLicense 管理员延长单个授权的有效期，返回设备离线应用的续期文件
+func (h *LicenseHandler) RenewLicense(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的授权ID",
+			"code":  40000,
+		})
+		return
+	}
+
+	var req RenewLicenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+			"code":  40000,
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "参数验证失败",
+			"code":  40000,
+		})
+		return
+	}
+
+	renewed, err := h.licenseService.RenewLicense(uint(id), req.ExpiresAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// 返回续期文件
+	content := []byte(renewed.RenewalFile.EncryptedContent)
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", renewalFileName(&renewed.License)))
+	c.Header("Content-Length", fmt.Sprintf("%d", len(content)))
+	c.Data(http.StatusOK, "application/octet-stream", content)
+}
+
+// RenewAuthorizationLicenses 管理员批量延长授权码下所有设备的有效期，返回续期文件ZIP包
+func (h *LicenseHandler) RenewAuthorizationLicenses(c *gin.Context) {
+	authID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的授权码ID",
+			"code":  40000,
+		})
+		return
+	}
+
+	var req RenewLicenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+			"code":  40000,
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "参数验证失败",
+			"code":  40000,
+		})
+		return
+	}
+
+	renewed, err := h.licenseService.RenewAuthorizationLicenses(uint(authID), req.ExpiresAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	zipBuffer, err := h.createRenewalZip(renewed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "创建续期文件包失败",
+			"code":  50000,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename=renewals.zip")
+	c.Header("Content-Length", fmt.Sprintf("%d", len(zipBuffer)))
+	c.Data(http.StatusOK, "application/zip", zipBuffer)
+}
+
+// createRenewalZip 创建包含所有续期文件的ZIP包，文件按设备命名便于分发
+func (h *LicenseHandler) createRenewalZip(renewed []services.RenewedLicense) ([]byte, error) {
+	var zipBuffer bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBuffer)
+
+	for i := range renewed {
+		fileWriter, err := zipWriter.Create(renewalFileName(&renewed[i].License))
+		if err != nil {
+			zipWriter.Close()
+			return nil, err
+		}
+
+		_, err = fileWriter.Write([]byte(renewed[i].RenewalFile.EncryptedContent))
+		if err != nil {
+			zipWriter.Close()
+			return nil, err
+		}
+	}
+
+	err := zipWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return zipBuffer.Bytes(), nil
+}
+
+// renewalFileName 生成续期文件名，同一主机名的多个设备通过授权ID区分
+func renewalFileName(license *models.License) string {
+	if license.Hostname == "" {
+		return fmt.Sprintf("license_%d.renew", license.ID)
+	}
+	return fmt.Sprintf("%s_%d.renew", license.Hostname, license.ID)
+}
+
+// DownloadRevocationList 管理员下载包含所有授权码的吊销列表
+func (h *LicenseHandler) DownloadRevocationList(c *gin.Context) {
+	h.sendRevocationList(c, 0)
+}
+
+// DownloadCustomerRevocationList 客户下载本授权码的吊销列表
+func (h *LicenseHandler) DownloadCustomerRevocationList(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "用户未认证",
+			"code":  40100,
+		})
+		return
+	}
+
+	h.sendRevocationList(c, userID.(uint))
+}
+
+// sendRevocationList 生成并返回吊销列表文件
+func (h *LicenseHandler) sendRevocationList(c *gin.Context, authID uint) {
+	revocationList, err := h.licenseService.GenerateRevocationList(authID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	content, err := json.MarshalIndent(revocationList, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "生成吊销列表失败",
+			"code":  50000,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", "attachment; filename=revocations.crl")
+	c.Header("Content-Length", fmt.Sprintf("%d", len(content)))
+	c.Data(http.StatusOK, "application/octet-stream", content)
+}
+
+// actorFromContext 根据JWT中的用户信息构造操作者，用于记录席位变更由谁发起
+func actorFromContext(c *gin.Context) services.Actor {
+	actor := services.Actor{
+		Type:      models.SeatActorSystem,
+		IPAddress: c.ClientIP(),
+	}
+
+	if userType, exists := c.Get("user_type"); exists {
+		switch userType {
+		case "admin":
+			actor.Type = models.SeatActorAdmin
+		case "customer":
+			actor.Type = models.SeatActorCustomer
+		}
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(uint); ok {
+			actor.ID = &id
+		}
+	}
+	if username, ok := c.Get("username"); ok {
+		actor.Name, _ = username.(string)
+	}
+
+	return actor
+}
+
+// readUploadedFile 读取上传文件的全部内容
+func readUploadedFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
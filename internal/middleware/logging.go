@@ -128,13 +128,19 @@ func parseActionFromRequest(c *gin.Context, requestBody []byte) (action, targetT
 	// 授权码相关操作
 	if strings.HasPrefix(path, "/api/admin/authorizations") {
 		targetType = "authorization"
-		switch method {
-		case "POST":
+		switch {
+		case method == "POST" && strings.HasSuffix(path, "/trial-licenses"):
+			action = "issue_trial_license"
+			targetID = extractIDFromPath(path)
+		case method == "POST" && strings.HasSuffix(path, "/renew"):
+			action = "renew_authorization_licenses"
+			targetID = extractIDFromPath(path)
+		case method == "POST":
 			action = "create_authorization"
-		case "PUT":
+		case method == "PUT":
 			action = "update_authorization"
 			targetID = extractIDFromPath(path)
-		case "DELETE":
+		case method == "DELETE":
 			action = "delete_authorization"
 			targetID = extractIDFromPath(path)
 		}
@@ -143,17 +149,30 @@ func parseActionFromRequest(c *gin.Context, requestBody []byte) (action, targetT
 	// 设备相关操作
 	if strings.HasPrefix(path, "/api/admin/licenses") {
 		targetType = "license"
-		if method == "DELETE" || strings.Contains(path, "unbind") {
+		switch {
+		case method == "DELETE" || strings.Contains(path, "unbind"):
 			action = "force_unbind_license"
 			targetID = extractIDFromPath(path)
+		case method == "POST" && strings.HasSuffix(path, "/convert"):
+			action = "convert_trial_license"
+			targetID = extractIDFromPath(path)
+		case method == "POST" && strings.HasSuffix(path, "/renew"):
+			action = "renew_license"
+			targetID = extractIDFromPath(path)
+		case method == "POST" && strings.HasSuffix(path, "/replace"):
+			// 替换设备的审计日志在业务事务中写入，这里不重复记录
 		}
 	}
 
 	// RSA密钥相关操作
 	if strings.HasPrefix(path, "/api/admin/rsa") {
 		targetType = "rsa_key"
-		if method == "POST" && strings.Contains(path, "rotate") {
+		switch {
+		case method == "POST" && strings.Contains(path, "rotate"):
 			action = "rotate_rsa_keys"
+		case method == "POST" && strings.HasSuffix(path, "/retire"):
+			action = "retire_rsa_key"
+			targetID = c.Param("kid")
 		}
 	}
 
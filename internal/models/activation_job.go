@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+)
+
+// ActivationJob 异步批量激活任务表模型，大批量上传的绑定文件在后台逐个激活
+type ActivationJob struct {
+	ID              uint       `gorm:"primaryKey" json:"-"`
+	JobID           string     `gorm:"unique;not null;size:36" json:"job_id"` // 对外暴露的任务ID
+	AuthorizationID uint       `gorm:"not null;index" json:"authorization_id"`
+	Status          string     `gorm:"not null;size:20;index" json:"status"` // 'pending', 'running', 'completed', 'failed'
+	Total           int        `json:"total"`
+	Processed       int        `json:"processed"`
+	Succeeded       int        `json:"succeeded"`
+	Failed          int        `json:"failed"`
+	IPAddress       string     `gorm:"size:45" json:"-"`                // 提交任务的客户端IP，记录到席位台账
+	Error           string     `gorm:"size:500" json:"error,omitempty"` // 任务整体失败的原因
+	WorkerID        string     `gorm:"size:100" json:"-"`               // 正在处理任务的后台实例
+	HeartbeatAt     *time.Time `gorm:"index" json:"-"`                  // 处理实例最近一次上报进度的时间，超时后任务可被其他实例接管
+	StartedAt       *time.Time `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// 关联关系
+	Items []ActivationJobItem `gorm:"foreignKey:ActivationJobID" json:"-"`
+}
+
+// TableName 指定表名
+func (ActivationJob) TableName() string {
+	return "activation_jobs"
+}
+
+// ActivationJobItem 异步激活任务中的单个绑定文件
+type ActivationJobItem struct {
+	ID              uint       `gorm:"primaryKey" json:"-"`
+	ActivationJobID uint       `gorm:"not null;index" json:"-"`
+	FileIndex       int        `gorm:"not null" json:"index"` // 文件在本次上传中的序号，从1开始
+	Filename        string     `gorm:"size:255" json:"filename"`
+	BindFile        string     `gorm:"type:text" json:"-"`             // 加密的绑定文件内容
+	Status          string     `gorm:"not null;size:20" json:"status"` // 'pending', 'succeeded', 'failed'
+	MachineID       string     `gorm:"size:255" json:"machine_id"`
+	Hostname        string     `gorm:"size:255" json:"hostname"`
+	Reissued        bool       `json:"reissued"`
+	ErrorCode       int        `json:"error_code,omitempty"`
+	Message         string     `gorm:"size:500" json:"message,omitempty"`
+	LicenseContent  string     `gorm:"type:text" json:"-"` // 激活成功后的加密授权文件
+	ProcessedAt     *time.Time `json:"processed_at"`
+}
+
+// TableName 指定表名
+func (ActivationJobItem) TableName() string {
+	return "activation_job_items"
+}
+
+// ActivationJobStatus 异步激活任务状态常量
+const (
+	ActivationJobPending   = "pending"   // 等待处理
+	ActivationJobRunning   = "running"   // 处理中
+	ActivationJobCompleted = "completed" // 全部文件已处理
+	ActivationJobFailed    = "failed"    // 任务整体失败，如授权码已被禁用
+)
+
+// ActivationJobItemStatus 绑定文件处理状态常量
+const (
+	ActivationItemPending   = "pending"
+	ActivationItemSucceeded = "succeeded"
+	ActivationItemFailed    = "failed"
+)
+
+// IsFinished 检查任务是否已结束
+func (j *ActivationJob) IsFinished() bool {
+	return j.Status == ActivationJobCompleted || j.Status == ActivationJobFailed
+}
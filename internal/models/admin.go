@@ -58,8 +58,9 @@ const (
 	LogActionEnableAuth  = "enable_authorization"
 
 	// 设备管理
-	LogActionForceUnbind  = "force_unbind_device"
-	LogActionViewCustomer = "view_customer_details"
+	LogActionForceUnbind   = "force_unbind_device"
+	LogActionReplaceDevice = "replace_device"
+	LogActionViewCustomer  = "view_customer_details"
 
 	// 系统管理
 	LogActionBackup       = "system_backup"
@@ -6,16 +6,25 @@ import (
 
 // Authorization 授权码表模型
 type Authorization struct {
-	ID                uint       `gorm:"primaryKey" json:"id"`
-	CustomerName      string     `gorm:"not null;size:255" json:"customer_name" validate:"required,max=255"`
-	AuthorizationCode string     `gorm:"unique;not null;size:255" json:"authorization_code" validate:"required,max=255"`
-	MaxSeats          int        `gorm:"not null" json:"max_seats" validate:"required,min=1"`
-	UsedSeats         int        `gorm:"default:0" json:"used_seats"`
-	DurationYears     *int       `json:"duration_years" validate:"omitempty,min=1"`
-	LatestExpiryDate  *time.Time `json:"latest_expiry_date"`
-	Status            int        `gorm:"default:1" json:"status"` // 1:有效 0:禁用
-	CreatedAt         time.Time  `json:"created_at"`
-	UpdatedAt         time.Time  `json:"updated_at"`
+	ID                uint             `gorm:"primaryKey" json:"id"`
+	CustomerName      string           `gorm:"not null;size:255" json:"customer_name" validate:"required,max=255"`
+	AuthorizationCode string           `gorm:"unique;not null;size:255" json:"authorization_code" validate:"required,max=255"`
+	MaxSeats          int              `gorm:"not null" json:"max_seats" validate:"required,min=1"`
+	UsedSeats         int              `gorm:"default:0" json:"used_seats"`
+	DurationYears     *int             `json:"duration_years" validate:"omitempty,min=1"`
+	LatestExpiryDate  *time.Time       `json:"latest_expiry_date"`
+	Status            int              `gorm:"default:1" json:"status"`                   // 1:有效 0:禁用
+	Edition           string           `gorm:"size:50" json:"edition"`                    // 产品版本，如 standard、professional
+	Features          []string         `gorm:"serializer:json;type:text" json:"features"` // 启用的功能模块
+	Limits            map[string]int64 `gorm:"serializer:json;type:text" json:"limits"`   // 数值限制，如 max_users
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+
+	// 设备转移策略，0表示不限制
+	MaxTransfersPerPeriod int `gorm:"default:0" json:"max_transfers_per_period"` // 每个席位在统计周期内最多转移次数
+	TransferPeriodDays    int `gorm:"default:0" json:"transfer_period_days"`     // 统计周期天数，未设置时按365天
+	TransferCooldownDays  int `gorm:"default:0" json:"transfer_cooldown_days"`   // 同一席位两次转移的最少间隔天数
+	MaxTotalTransfers     int `gorm:"default:0" json:"max_total_transfers"`      // 授权码累计转移次数上限
 
 	// 关联关系
 	Licenses []License `gorm:"foreignKey:AuthorizationID" json:"licenses,omitempty"`
@@ -41,6 +50,27 @@ func (a *Authorization) GetAvailableSeats() int {
 	return a.MaxSeats - a.UsedSeats
 }
 
+// DefaultTransferPeriodDays 未设置统计周期时的默认天数
+const DefaultTransferPeriodDays = 365
+
+// EffectiveTransferPeriodDays 获取转移次数统计周期的天数
+func (a *Authorization) EffectiveTransferPeriodDays() int {
+	if a.TransferPeriodDays <= 0 {
+		return DefaultTransferPeriodDays
+	}
+	return a.TransferPeriodDays
+}
+
+// TransferPeriod 获取转移次数的统计周期
+func (a *Authorization) TransferPeriod() time.Duration {
+	return time.Duration(a.EffectiveTransferPeriodDays()) * 24 * time.Hour
+}
+
+// HasTransferPolicy 检查是否设置了任一转移限制
+func (a *Authorization) HasTransferPolicy() bool {
+	return a.MaxTransfersPerPeriod > 0 || a.TransferCooldownDays > 0 || a.MaxTotalTransfers > 0
+}
+
 // CalculateExpiryDate 计算授权到期时间
 func (a *Authorization) CalculateExpiryDate() time.Time {
 	now := time.Now()
@@ -11,16 +11,36 @@ type License struct {
 	LicenseKey       string     `gorm:"unique;not null" json:"license_key"` // .license文件内容的哈希或唯一标识
 	MachineID        string     `gorm:"not null;size:255" json:"machine_id"`
 	Hostname         string     `gorm:"size:255" json:"hostname"`
-	UnbindPublicKey  string     `gorm:"type:text" json:"unbind_public_key"` // 用于验证解绑凭证的一次性公钥
-	UnbindPrivateKey string     `gorm:"type:text" json:"-"`                 // 用于重新生成license的一次性私钥（敏感信息，不返回给前端）
+	ClientPublicKey  string     `gorm:"size:64" json:"client_public_key"`           // 设备的X25519公钥，为空时使用旧版基于机器ID的加密
+	UnbindKeyType    string     `gorm:"size:20;default:rsa" json:"unbind_key_type"` // 一次性解绑密钥类型：rsa, ed25519
+	UnbindPublicKey  string     `gorm:"type:text" json:"unbind_public_key"`         // 用于验证解绑凭证的一次性公钥
+	UnbindPrivateKey string     `gorm:"type:text" json:"-"`                         // 用于重新生成license的一次性私钥（敏感信息，不返回给前端）
 	IssuedAt         time.Time  `gorm:"not null" json:"issued_at"`
 	ExpiresAt        time.Time  `json:"expires_at"`
-	Status           string     `gorm:"not null;size:50" json:"status"` // 'active', 'unbound', 'force_unbound'
+	Status           string     `gorm:"not null;size:50" json:"status"`                 // 'active', 'unbound', 'force_unbound'
+	LicenseType      string     `gorm:"size:20;default:FULL;index" json:"license_type"` // 'FULL', 'TRIAL'
+	SeatExempt       bool       `gorm:"default:false" json:"seat_exempt"`               // 不占用授权码席位（免席位试用）
+	TrialIssuedAt    *time.Time `gorm:"index" json:"trial_issued_at"`                   // 作为试用授权签发的时间，转为正式授权后保留，用于限制每台设备只试用一次
 	ActivatedAt      time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"activated_at"`
 	UnboundAt        *time.Time `json:"unbound_at"`
 	CreatedAt        time.Time  `json:"created_at"`
 	UpdatedAt        time.Time  `json:"updated_at"`
 
+	// 设备转移链路，转移生成的新授权指向被替换的旧授权
+	PredecessorID       *uint      `gorm:"index" json:"predecessor_id"`
+	SuccessorID         *uint      `gorm:"index" json:"successor_id"`
+	TransferType        string     `gorm:"size:20" json:"transfer_type"` // 接续旧授权的方式：transfer, replace，未经转移时为空
+	OriginalActivatedAt *time.Time `json:"original_activated_at"`        // 转移链路中首台设备的激活时间，未经转移时为空
+
+	// 解绑信息，客户解绑时来自解绑文件的元数据，强制解绑时记录管理员填写的原因
+	UnbindReason        string     `gorm:"size:500" json:"unbind_reason"`
+	UnbindHostname      string     `gorm:"size:255" json:"unbind_hostname"`       // 解绑时客户端上报的主机名
+	UnbindClientVersion string     `gorm:"size:100" json:"unbind_client_version"` // 生成解绑文件的客户端版本
+	UnbindRequestedAt   *time.Time `json:"unbind_requested_at"`                   // 客户端生成解绑文件的时间
+	UnboundByType       string     `gorm:"size:20" json:"unbound_by_type"`        // 'admin', 'customer', 'system'
+	UnboundByID         *uint      `json:"unbound_by_id"`                         // 管理员ID或授权码ID
+	UnboundBy           string     `gorm:"size:100" json:"unbound_by"`            // 管理员用户名或授权码
+
 	// 关联关系
 	Authorization Authorization `gorm:"foreignKey:AuthorizationID" json:"authorization,omitempty"`
 }
@@ -37,6 +57,41 @@ const (
 	LicenseStatusForceUnbound = "force_unbound" // 管理员强制解绑
 )
 
+// LicenseType 授权类型常量
+const (
+	LicenseTypeFull  = "FULL"  // 正式授权
+	LicenseTypeTrial = "TRIAL" // 试用授权
+)
+
+// TransferType 设备接续方式常量
+const (
+	TransferTypeCustomer = "transfer" // 客户使用解绑文件转移，计入转移额度
+	TransferTypeReplace  = "replace"  // 管理员替换故障设备，不计入转移额度
+)
+
+// IsCustomerTransfer 检查授权是否由客户转移产生
+func (l *License) IsCustomerTransfer() bool {
+	return l.PredecessorID != nil && l.TransferType == TransferTypeCustomer
+}
+
+// IsTrial 检查是否为试用授权
+func (l *License) IsTrial() bool {
+	return l.LicenseType == LicenseTypeTrial
+}
+
+// ConsumesSeat 检查授权是否占用授权码席位
+func (l *License) ConsumesSeat() bool {
+	return !l.SeatExempt
+}
+
+// FirstActivatedAt 获取该席位首次激活的时间，设备转移后仍保持不变
+func (l *License) FirstActivatedAt() time.Time {
+	if l.OriginalActivatedAt != nil {
+		return *l.OriginalActivatedAt
+	}
+	return l.ActivatedAt
+}
+
 // IsActive 检查授权是否有效
 func (l *License) IsActive() bool {
 	return l.Status == LicenseStatusActive && time.Now().Before(l.ExpiresAt)
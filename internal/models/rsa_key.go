@@ -4,14 +4,20 @@ import (
 	"time"
 )
 
-// RSAKey RSA密钥表模型
+// RSAKey 服务端密钥表模型
+// RSA密钥（RS256、PS256）同时用于加密文件信封和签名，ECDSA和Ed25519密钥只用于签名
 type RSAKey struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	PrivateKey string    `gorm:"not null;type:text" json:"-"`          // 私钥，不在JSON中返回
-	PublicKey  string    `gorm:"not null;type:text" json:"public_key"` // 公钥
-	IsActive   bool      `gorm:"default:true" json:"is_active"`        // 是否为当前活跃密钥
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	KeyID       string     `gorm:"size:64;index" json:"key_id"`            // 密钥ID，写入加密文件信封和授权文件
+	Algorithm   string     `gorm:"size:20;default:RS256" json:"algorithm"` // 签名算法：RS256, PS256, ES256, EdDSA
+	PrivateKey  string     `gorm:"not null;type:text" json:"-"`            // 私钥，不在JSON中返回
+	PublicKey   string     `gorm:"not null;type:text" json:"public_key"`   // 公钥
+	Certificate string     `gorm:"type:text" json:"certificate,omitempty"` // 离线根密钥签发的签名密钥证书（Base64）
+	Status      string     `gorm:"size:20;index" json:"status"`            // 'active', 'retiring', 'retired'
+	RetiredAt   *time.Time `json:"retired_at"`                             // 退出活跃状态的时间
+	GraceUntil  *time.Time `json:"grace_until"`                            // 宽限期截止时间，之后不再用于解密
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 // TableName 指定表名
@@ -19,6 +25,46 @@ func (RSAKey) TableName() string {
 	return "rsa_keys"
 }
 
+// RSAKeyStatus 密钥状态常量
+const (
+	RSAKeyStatusActive   = "active"   // 当前活跃密钥，用于签名和加密
+	RSAKeyStatusRetiring = "retiring" // 已轮换，宽限期内仍可解密旧文件
+	RSAKeyStatusRetired  = "retired"  // 已退役，不再使用
+)
+
+// IsActive 检查是否为当前活跃密钥
+func (k *RSAKey) IsActive() bool {
+	return k.Status == RSAKeyStatusActive
+}
+
+// IsRSA 检查是否为RSA密钥，只有RSA密钥可用于加密文件信封
+func (k *RSAKey) IsRSA() bool {
+	return k.Algorithm == "" || k.Algorithm == "RS256" || k.Algorithm == "PS256"
+}
+
+// SignatureAlgorithm 获取签名算法，旧版记录没有算法时为RS256
+func (k *RSAKey) SignatureAlgorithm() string {
+	if k.Algorithm == "" {
+		return "RS256"
+	}
+	return k.Algorithm
+}
+
+// CanDecrypt 检查密钥是否仍可用于解密
+func (k *RSAKey) CanDecrypt() bool {
+	if !k.IsRSA() {
+		return false
+	}
+	switch k.Status {
+	case RSAKeyStatusActive:
+		return true
+	case RSAKeyStatusRetiring:
+		return k.GraceUntil == nil || time.Now().Before(*k.GraceUntil)
+	default:
+		return false
+	}
+}
+
 // SystemConfig 系统配置表模型
 type SystemConfig struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
@@ -41,4 +87,6 @@ const (
 	ConfigBackupRetentionDays = "backup_retention_days"
 	ConfigMaintenanceMode     = "maintenance_mode"
 	ConfigSystemVersion       = "system_version"
+	ConfigKeyringVersion      = "keyring_version"    // 服务端密钥环版本，密钥变更时更新
+	ConfigRevocationVersion   = "revocation_version" // 吊销列表版本，每次解绑授权时递增
 )
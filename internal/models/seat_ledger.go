@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// SeatLedger 席位台账表模型，记录授权码每一次席位数量的变化
+type SeatLedger struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	AuthorizationID uint      `gorm:"not null;index" json:"authorization_id"`
+	LicenseID       *uint     `gorm:"index" json:"license_id"`        // 关联的设备授权，调整总席位时为空
+	Reason          string    `gorm:"not null;size:50" json:"reason"` // 变更原因
+	UsedDelta       int       `json:"used_delta"`                     // 已用席位变化量
+	MaxDelta        int       `json:"max_delta"`                      // 总席位变化量
+	UsedSeats       int       `json:"used_seats"`                     // 变更后的已用席位数
+	MaxSeats        int       `json:"max_seats"`                      // 变更后的总席位数
+	ActorType       string    `gorm:"size:20" json:"actor_type"`      // 操作者类型
+	ActorID         *uint     `json:"actor_id"`                       // 管理员ID或客户登录的授权码ID，系统操作时为空
+	ActorName       string    `gorm:"size:100" json:"actor_name"`     // 管理员用户名或授权码
+	IPAddress       string    `gorm:"size:45" json:"ip_address"`
+	Remark          string    `gorm:"size:255" json:"remark"`
+	CreatedAt       time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (SeatLedger) TableName() string {
+	return "seat_ledgers"
+}
+
+// SeatReason 席位变更原因常量
+const (
+	SeatReasonActivate     = "activate"      // 客户激活设备
+	SeatReasonTransfer     = "transfer"      // 设备转移（席位数不变）
+	SeatReasonReplace      = "replace"       // 管理员替换故障设备（席位数不变）
+	SeatReasonDeactivate   = "deactivate"    // 客户停用设备
+	SeatReasonForceUnbind  = "force_unbind"  // 管理员强制解绑
+	SeatReasonTrialIssue   = "trial_issue"   // 签发占用席位的试用授权
+	SeatReasonTrialConvert = "trial_convert" // 试用授权转为正式授权
+	SeatReasonMaxSeats     = "max_seats"     // 管理员调整总席位数
+	SeatReasonReconcile    = "reconcile"     // 席位对账修正
+	SeatReasonAdjust       = "adjust"        // 其他调整
+)
+
+// SeatActor 操作者类型常量
+const (
+	SeatActorAdmin    = "admin"
+	SeatActorCustomer = "customer"
+	SeatActorSystem   = "system"
+)
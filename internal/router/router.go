@@ -54,6 +54,7 @@ func SetupRouter() *gin.Engine {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Expose-Headers", "Content-Disposition, X-Activation-Succeeded, X-Activation-Failed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -97,7 +98,8 @@ func SetupRouter() *gin.Engine {
 		// 客户端控制台路由
 		client := api.Group("/client", middleware.JWTAuthMiddleware(), middleware.CustomerAuthMiddleware())
 		{
-			client.GET("/dashboard", customerHandler.GetDashboard) // 客户端控制台
+			client.GET("/dashboard", customerHandler.GetDashboard)                    // 客户端控制台
+			client.GET("/revocations", licenseHandler.DownloadCustomerRevocationList) // 下载吊销列表
 		}
 
 		// 管理员路由组
@@ -131,11 +133,24 @@ func SetupRouter() *gin.Engine {
 				adminAuth.POST("/authorizations", authHandler.CreateAuthorization)
 				adminAuth.GET("/authorizations", authHandler.ListAuthorizations)
 				adminAuth.GET("/authorizations/:id/details", authHandler.GetAuthorizationDetails)
+				adminAuth.GET("/authorizations/:id/seat-ledger", authHandler.GetSeatLedger)
 				adminAuth.PUT("/authorizations/:id", authHandler.UpdateAuthorization)
 				adminAuth.DELETE("/authorizations/:id", authHandler.DeleteAuthorization)
+				adminAuth.POST("/authorizations/:id/trial-licenses", licenseHandler.IssueTrialLicense)
+				adminAuth.POST("/authorizations/:id/renew", licenseHandler.RenewAuthorizationLicenses)
 
 				// 设备管理
 				adminAuth.POST("/licenses/:id/force-unbind", licenseHandler.ForceUnbindLicense)
+				adminAuth.POST("/licenses/:id/convert", licenseHandler.ConvertTrialLicense)
+				adminAuth.POST("/licenses/:id/renew", licenseHandler.RenewLicense)
+				adminAuth.POST("/licenses/:id/replace", licenseHandler.ReplaceDevice)
+				adminAuth.GET("/licenses/lineage", licenseHandler.GetLicenseLineage)
+				adminAuth.GET("/revocations", licenseHandler.DownloadRevocationList)
+
+				// RSA密钥管理
+				adminAuth.GET("/rsa/keys", adminHandler.ListRSAKeys)
+				adminAuth.POST("/rsa/rotate", adminHandler.RotateRSAKeys)
+				adminAuth.POST("/rsa/keys/:kid/retire", adminHandler.RetireRSAKey)
 			}
 		}
 
@@ -143,7 +158,12 @@ func SetupRouter() *gin.Engine {
 		actions := api.Group("/actions", middleware.JWTAuthMiddleware(), middleware.CustomerAuthMiddleware())
 		{
 			actions.POST("/activate-licenses", licenseHandler.ActivateLicenses)
+			actions.POST("/activate-licenses/preview", licenseHandler.PreviewActivation)
+			actions.POST("/activation-jobs", licenseHandler.CreateActivationJob)
+			actions.GET("/activation-jobs/:job_id", licenseHandler.GetActivationJob)
+			actions.GET("/activation-jobs/:job_id/download", licenseHandler.DownloadActivationJob)
 			actions.POST("/transfer-license", licenseHandler.TransferLicense)
+			actions.POST("/deactivate-license", licenseHandler.DeactivateLicense)
 		}
 
 		// 许可证相关路由 (需要JWT认证，但不区分管理员或客户端)
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lyenrowe/LicenseCenter/internal/config"
+	"github.com/lyenrowe/LicenseCenter/internal/database"
+	"github.com/lyenrowe/LicenseCenter/internal/models"
+	"github.com/lyenrowe/LicenseCenter/pkg/errors"
+	"github.com/lyenrowe/LicenseCenter/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// activationJobSignal 提交新任务时唤醒后台处理，缓冲为1避免提交方阻塞
+var activationJobSignal = make(chan struct{}, 1)
+
+// errJobReclaimed 任务心跳超时后已被其他实例接管，当前实例停止处理
+var errJobReclaimed = errors.NewAppError(50001, "激活任务已被其他实例接管")
+
+// ActivationJobService 异步批量激活任务服务
+type ActivationJobService struct {
+	db             *gorm.DB
+	licenseService *LicenseService
+	workerID       string // 认领任务时记录，区分多个实例
+}
+
+// NewActivationJobService 创建异步激活任务服务实例
+func NewActivationJobService() *ActivationJobService {
+	hostname, _ := os.Hostname()
+
+	return &ActivationJobService{
+		db:             database.GetDB(),
+		licenseService: NewLicenseService(),
+		workerID:       fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8]),
+	}
+}
+
+// ActivationJobFile 提交到异步任务的绑定文件
+type ActivationJobFile struct {
+	Filename string
+	Content  string // 加密的绑定文件内容
+}
+
+// CreateJob 创建异步激活任务，绑定文件保存到数据库后由后台逐个激活（按部分成功模式处理）
+func (s *ActivationJobService) CreateJob(authCode string, files []ActivationJobFile, ipAddress string) (*models.ActivationJob, error) {
+	auth, err := s.licenseService.authService.ValidateAuthorizationCode(authCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, errors.NewAppError(40000, "请上传至少一个.bind文件")
+	}
+	if maxFiles := maxBindFilesPerJob(); len(files) > maxFiles {
+		return nil, errors.NewAppError(40000, fmt.Sprintf("单个激活任务最多%d个绑定文件", maxFiles))
+	}
+
+	job := &models.ActivationJob{
+		JobID:           uuid.New().String(),
+		AuthorizationID: auth.ID,
+		Status:          models.ActivationJobPending,
+		Total:           len(files),
+		IPAddress:       ipAddress,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return errors.WrapError(err, 50001, "创建激活任务失败")
+		}
+
+		items := make([]models.ActivationJobItem, 0, len(files))
+		for i, file := range files {
+			items = append(items, models.ActivationJobItem{
+				ActivationJobID: job.ID,
+				FileIndex:       i + 1,
+				Filename:        file.Filename,
+				BindFile:        file.Content,
+				Status:          models.ActivationItemPending,
+			})
+		}
+		if err := tx.CreateInBatches(items, 100).Error; err != nil {
+			return errors.WrapError(err, 50001, "保存激活任务文件失败")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 唤醒后台处理，已有待处理信号时无需重复发送
+	select {
+	case activationJobSignal <- struct{}{}:
+	default:
+	}
+
+	logger.GetLogger().Info("创建异步激活任务",
+		zap.String("auth_code", auth.AuthorizationCode),
+		zap.String("job_id", job.JobID),
+		zap.Int("total", job.Total))
+
+	return job, nil
+}
+
+// GetJob 获取授权码下的激活任务及进度
+func (s *ActivationJobService) GetJob(authID uint, jobID string) (*models.ActivationJob, error) {
+	var job models.ActivationJob
+	err := s.db.Where("job_id = ? AND authorization_id = ?", jobID, authID).First(&job).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrJobNotFound
+		}
+		return nil, errors.WrapError(err, 50001, "获取激活任务失败")
+	}
+
+	return &job, nil
+}
+
+// GetJobResults 获取已结束任务中每个绑定文件的激活结果
+func (s *ActivationJobService) GetJobResults(authID uint, jobID string) ([]ActivationResult, error) {
+	job, err := s.GetJob(authID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !job.IsFinished() {
+		return nil, errors.ErrJobNotFinished
+	}
+
+	var items []models.ActivationJobItem
+	err = s.db.Where("activation_job_id = ?", job.ID).Order("file_index ASC").Find(&items).Error
+	if err != nil {
+		return nil, errors.WrapError(err, 50001, "获取激活任务结果失败")
+	}
+
+	results := make([]ActivationResult, 0, len(items))
+	for _, item := range items {
+		result := ActivationResult{
+			Index:     item.FileIndex,
+			Filename:  item.Filename,
+			Success:   item.Status == models.ActivationItemSucceeded,
+			MachineID: item.MachineID,
+			Hostname:  item.Hostname,
+			Reissued:  item.Reissued,
+			ErrorCode: item.ErrorCode,
+			Message:   item.Message,
+		}
+		if result.Success {
+			result.LicenseFile = &EncryptedFileResponse{
+				EncryptedContent: item.LicenseContent,
+				FileType:         "license",
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// RunWorker 在后台处理激活任务，直到ctx取消；每轮先接管心跳超时的中断任务
+func (s *ActivationJobService) RunWorker(ctx context.Context) {
+	ticker := time.NewTicker(activationJobPollInterval())
+	defer ticker.Stop()
+
+	for {
+		if err := s.ResumeInterruptedJobs(); err != nil {
+			logger.GetLogger().Error("恢复激活任务失败", zap.Error(err))
+		}
+		if err := s.ProcessPendingJobs(ctx); err != nil {
+			logger.GetLogger().Error("处理激活任务失败", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-activationJobSignal:
+		}
+	}
+}
+
+// ResumeInterruptedJobs 将心跳超时的处理中任务重新排队，已处理的文件不会重复处理
+// 仍在其他实例上正常处理的任务会持续更新心跳，不会被接管
+func (s *ActivationJobService) ResumeInterruptedJobs() error {
+	staleBefore := time.Now().Add(-activationJobTimeout())
+	result := s.db.Model(&models.ActivationJob{}).
+		Where("status = ? AND heartbeat_at < ?", models.ActivationJobRunning, staleBefore).
+		Updates(map[string]interface{}{
+			"status":    models.ActivationJobPending,
+			"worker_id": "",
+		})
+	if result.Error != nil {
+		return errors.WrapError(result.Error, 50001, "恢复激活任务失败")
+	}
+
+	if result.RowsAffected > 0 {
+		logger.GetLogger().Info("已恢复中断的激活任务", zap.Int64("count", result.RowsAffected))
+	}
+
+	return nil
+}
+
+// ProcessPendingJobs 按提交顺序处理所有待处理的任务
+func (s *ActivationJobService) ProcessPendingJobs(ctx context.Context) error {
+	for ctx.Err() == nil {
+		var job models.ActivationJob
+		err := s.db.Where("status = ?", models.ActivationJobPending).Order("id ASC").First(&job).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return errors.WrapError(err, 50001, "获取待处理激活任务失败")
+		}
+
+		// 条件更新认领任务，避免多个实例重复处理
+		now := time.Now()
+		result := s.db.Model(&models.ActivationJob{}).
+			Where("id = ? AND status = ?", job.ID, models.ActivationJobPending).
+			Updates(map[string]interface{}{
+				"status":       models.ActivationJobRunning,
+				"worker_id":    s.workerID,
+				"heartbeat_at": &now,
+				"started_at":   gorm.Expr("COALESCE(started_at, ?)", now),
+			})
+		if result.Error != nil {
+			return errors.WrapError(result.Error, 50001, "认领激活任务失败")
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := s.processJob(ctx, &job); err != nil {
+			if err == errJobReclaimed {
+				logger.GetLogger().Warn("激活任务心跳超时，已被其他实例接管", zap.String("job_id", job.JobID))
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// processJob 逐个激活任务中尚未处理的绑定文件
+func (s *ActivationJobService) processJob(ctx context.Context, job *models.ActivationJob) error {
+	var auth models.Authorization
+	if err := s.db.First(&auth, job.AuthorizationID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return s.failJob(job, errors.ErrAuthCodeNotFound)
+		}
+		return errors.WrapError(err, 50001, "获取授权码失败")
+	}
+	if !auth.IsActive() {
+		return s.failJob(job, errors.ErrAuthCodeDisabled)
+	}
+
+	licenseService := s.licenseService.WithActor(Actor{
+		Type:      models.SeatActorCustomer,
+		ID:        &auth.ID,
+		Name:      auth.AuthorizationCode,
+		IPAddress: job.IPAddress,
+	})
+
+	var items []models.ActivationJobItem
+	err := s.db.Where("activation_job_id = ? AND status = ?", job.ID, models.ActivationItemPending).
+		Order("file_index ASC").Find(&items).Error
+	if err != nil {
+		return errors.WrapError(err, 50001, "获取激活任务文件失败")
+	}
+
+	for i := range items {
+		// 服务关闭时停止处理，任务重新排队，由下次启动或其他实例继续
+		if ctx.Err() != nil {
+			return s.releaseJob(job)
+		}
+
+		result := licenseService.activateEncryptedBindFile(&auth, items[i].BindFile)
+		if err := s.saveItemResult(job, &items[i], &result); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	result := s.db.Model(&models.ActivationJob{}).
+		Where("id = ? AND worker_id = ?", job.ID, s.workerID).
+		Updates(map[string]interface{}{
+			"status":       models.ActivationJobCompleted,
+			"heartbeat_at": &now,
+			"finished_at":  &now,
+		})
+	if result.Error != nil {
+		return errors.WrapError(result.Error, 50001, "更新激活任务状态失败")
+	}
+	if result.RowsAffected == 0 {
+		return errJobReclaimed
+	}
+
+	logger.GetLogger().Info("异步激活任务完成",
+		zap.String("auth_code", auth.AuthorizationCode),
+		zap.String("job_id", job.JobID),
+		zap.Int("total", job.Total))
+
+	return nil
+}
+
+// saveItemResult 保存单个文件的激活结果，同时更新任务进度和心跳。激活成功但结果未保存时，重新处理会按重复激活重新签发原授权，不会多占席位
+// 任务已被其他实例接管时放弃保存并返回errJobReclaimed
+func (s *ActivationJobService) saveItemResult(job *models.ActivationJob, item *models.ActivationJobItem, result *ActivationResult) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":       models.ActivationItemFailed,
+		"machine_id":   result.MachineID,
+		"hostname":     result.Hostname,
+		"reissued":     result.Reissued,
+		"error_code":   result.ErrorCode,
+		"message":      result.Message,
+		"processed_at": &now,
+	}
+	counter := "failed"
+	if result.Success {
+		updates["status"] = models.ActivationItemSucceeded
+		updates["license_content"] = result.LicenseFile.EncryptedContent
+		counter = "succeeded"
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.ActivationJobItem{}).Where("id = ?", item.ID).Updates(updates).Error
+		if err != nil {
+			return errors.WrapError(err, 50001, "保存激活结果失败")
+		}
+
+		progress := tx.Model(&models.ActivationJob{}).
+			Where("id = ? AND worker_id = ?", job.ID, s.workerID).
+			Updates(map[string]interface{}{
+				"processed":    gorm.Expr("processed + 1"),
+				counter:        gorm.Expr(counter + " + 1"),
+				"heartbeat_at": &now,
+			})
+		if progress.Error != nil {
+			return errors.WrapError(progress.Error, 50001, "更新激活任务进度失败")
+		}
+		if progress.RowsAffected == 0 {
+			return errJobReclaimed
+		}
+
+		return nil
+	})
+}
+
+// releaseJob 放弃当前实例认领的任务，使其可被立即重新认领
+func (s *ActivationJobService) releaseJob(job *models.ActivationJob) error {
+	err := s.db.Model(&models.ActivationJob{}).
+		Where("id = ? AND worker_id = ? AND status = ?", job.ID, s.workerID, models.ActivationJobRunning).
+		Updates(map[string]interface{}{
+			"status":    models.ActivationJobPending,
+			"worker_id": "",
+		}).Error
+	if err != nil {
+		return errors.WrapError(err, 50001, "释放激活任务失败")
+	}
+
+	return nil
+}
+
+// failJob 任务无法继续时将剩余文件标记为失败
+func (s *ActivationJobService) failJob(job *models.ActivationJob, appErr *errors.AppError) error {
+	now := time.Now()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ActivationJobItem{}).
+			Where("activation_job_id = ? AND status = ?", job.ID, models.ActivationItemPending).
+			Updates(map[string]interface{}{
+				"status":       models.ActivationItemFailed,
+				"error_code":   appErr.Code,
+				"message":      appErr.Message,
+				"processed_at": &now,
+			})
+		if result.Error != nil {
+			return errors.WrapError(result.Error, 50001, "更新激活任务文件失败")
+		}
+
+		err := tx.Model(&models.ActivationJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":      models.ActivationJobFailed,
+			"error":       appErr.Message,
+			"processed":   gorm.Expr("processed + ?", result.RowsAffected),
+			"failed":      gorm.Expr("failed + ?", result.RowsAffected),
+			"finished_at": &now,
+		}).Error
+		if err != nil {
+			return errors.WrapError(err, 50001, "更新激活任务状态失败")
+		}
+
+		return nil
+	})
+}
+
+// maxBindFilesPerJob 单个任务允许的最多绑定文件数
+func maxBindFilesPerJob() int {
+	if config.AppConfig != nil && config.AppConfig.System.MaxBindFilesPerJob > 0 {
+		return config.AppConfig.System.MaxBindFilesPerJob
+	}
+	return 1000
+}
+
+// activationJobTimeout 处理中任务的心跳超时时间
+func activationJobTimeout() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.System.ActivationJobTimeoutSecs > 0 {
+		return time.Duration(config.AppConfig.System.ActivationJobTimeoutSecs) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+// activationJobPollInterval 后台检查待处理任务的间隔
+func activationJobPollInterval() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.System.ActivationJobPollSecs > 0 {
+		return time.Duration(config.AppConfig.System.ActivationJobPollSecs) * time.Second
+	}
+	return 5 * time.Second
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/lyenrowe/LicenseCenter/internal/database"
 	"github.com/lyenrowe/LicenseCenter/internal/models"
 	"github.com/lyenrowe/LicenseCenter/pkg/errors"
+	"github.com/lyenrowe/LicenseCenter/pkg/logger"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // 全局计数器，用于确保授权码唯一性
@@ -18,32 +21,78 @@ var authCodeCounter int64
 
 // AuthorizationService 授权码管理服务
 type AuthorizationService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	actor Actor // 当前操作者，写入席位台账
 }
 
 // NewAuthorizationService 创建授权码服务实例
 func NewAuthorizationService() *AuthorizationService {
 	return &AuthorizationService{
-		db: database.GetDB(),
+		db:    database.GetDB(),
+		actor: SystemActor,
 	}
 }
 
+// WithActor 创建以指定操作者身份执行的授权码服务实例
+func (s *AuthorizationService) WithActor(actor Actor) *AuthorizationService {
+	return &AuthorizationService{
+		db:    s.db,
+		actor: actor,
+	}
+}
+
+// Actor 操作者信息，用于记录席位变更由谁发起
+type Actor struct {
+	Type      string // models.SeatActorAdmin / SeatActorCustomer / SeatActorSystem
+	ID        *uint  // 管理员ID或客户登录的授权码ID
+	Name      string // 管理员用户名或授权码
+	IPAddress string
+}
+
+// SystemActor 系统操作者，用于命令行工具和未指定操作者的调用
+var SystemActor = Actor{Type: models.SeatActorSystem, Name: "system"}
+
+// SeatChange 席位变更说明
+type SeatChange struct {
+	Reason    string // models.SeatReason*
+	LicenseID *uint  // 关联的设备授权
+	Remark    string
+}
+
 // CreateAuthorizationRequest 创建授权码请求结构
 type CreateAuthorizationRequest struct {
-	CustomerName      string     `json:"customer_name" validate:"required,max=255"`
-	AuthorizationCode string     `json:"authorization_code,omitempty" validate:"omitempty,max=255"`
-	MaxSeats          int        `json:"max_seats" validate:"required,min=1"`
-	DurationYears     *int       `json:"duration_years,omitempty" validate:"omitempty,min=1"`
-	LatestExpiryDate  *time.Time `json:"latest_expiry_date,omitempty"`
+	CustomerName      string           `json:"customer_name" validate:"required,max=255"`
+	AuthorizationCode string           `json:"authorization_code,omitempty" validate:"omitempty,max=255"`
+	MaxSeats          int              `json:"max_seats" validate:"required,min=1"`
+	DurationYears     *int             `json:"duration_years,omitempty" validate:"omitempty,min=1"`
+	LatestExpiryDate  *time.Time       `json:"latest_expiry_date,omitempty"`
+	Edition           string           `json:"edition,omitempty" validate:"omitempty,max=50"`
+	Features          []string         `json:"features,omitempty" validate:"omitempty,dive,required,max=100"`
+	Limits            map[string]int64 `json:"limits,omitempty" validate:"omitempty,dive,keys,required,max=100,endkeys,min=0"`
+
+	// 设备转移策略，0表示不限制
+	MaxTransfersPerPeriod int `json:"max_transfers_per_period,omitempty" validate:"omitempty,min=0"`
+	TransferPeriodDays    int `json:"transfer_period_days,omitempty" validate:"omitempty,min=0"`
+	TransferCooldownDays  int `json:"transfer_cooldown_days,omitempty" validate:"omitempty,min=0"`
+	MaxTotalTransfers     int `json:"max_total_transfers,omitempty" validate:"omitempty,min=0"`
 }
 
 // UpdateAuthorizationRequest 更新授权码请求结构
 type UpdateAuthorizationRequest struct {
-	CustomerName     string     `json:"customer_name,omitempty" validate:"omitempty,max=255"`
-	MaxSeats         *int       `json:"max_seats,omitempty" validate:"omitempty,min=1"`
-	DurationYears    *int       `json:"duration_years,omitempty" validate:"omitempty,min=1"`
-	LatestExpiryDate *time.Time `json:"latest_expiry_date,omitempty"`
-	Status           *int       `json:"status,omitempty" validate:"omitempty,oneof=0 1"`
+	CustomerName     string            `json:"customer_name,omitempty" validate:"omitempty,max=255"`
+	MaxSeats         *int              `json:"max_seats,omitempty" validate:"omitempty,min=1"`
+	DurationYears    *int              `json:"duration_years,omitempty" validate:"omitempty,min=1"`
+	LatestExpiryDate *time.Time        `json:"latest_expiry_date,omitempty"`
+	Status           *int              `json:"status,omitempty" validate:"omitempty,oneof=0 1"`
+	Edition          *string           `json:"edition,omitempty" validate:"omitempty,max=50"`
+	Features         *[]string         `json:"features,omitempty" validate:"omitempty,dive,required,max=100"`                  // 传空数组表示清空
+	Limits           *map[string]int64 `json:"limits,omitempty" validate:"omitempty,dive,keys,required,max=100,endkeys,min=0"` // 传空对象表示清空
+
+	// 设备转移策略，传0表示取消限制
+	MaxTransfersPerPeriod *int `json:"max_transfers_per_period,omitempty" validate:"omitempty,min=0"`
+	TransferPeriodDays    *int `json:"transfer_period_days,omitempty" validate:"omitempty,min=0"`
+	TransferCooldownDays  *int `json:"transfer_cooldown_days,omitempty" validate:"omitempty,min=0"`
+	MaxTotalTransfers     *int `json:"max_total_transfers,omitempty" validate:"omitempty,min=0"`
 }
 
 // CreateAuthorization 创建新的授权码
@@ -72,6 +121,14 @@ func (s *AuthorizationService) CreateAuthorization(req *CreateAuthorizationReque
 		DurationYears:     req.DurationYears,
 		LatestExpiryDate:  req.LatestExpiryDate,
 		Status:            1, // 默认启用
+		Edition:           strings.TrimSpace(req.Edition),
+		Features:          normalizeFeatures(req.Features),
+		Limits:            req.Limits,
+
+		MaxTransfersPerPeriod: req.MaxTransfersPerPeriod,
+		TransferPeriodDays:    req.TransferPeriodDays,
+		TransferCooldownDays:  req.TransferCooldownDays,
+		MaxTotalTransfers:     req.MaxTotalTransfers,
 	}
 
 	err = s.db.Create(auth).Error
@@ -113,41 +170,81 @@ func (s *AuthorizationService) GetAuthorizationByID(id uint) (*models.Authorizat
 // UpdateAuthorization 更新授权码
 func (s *AuthorizationService) UpdateAuthorization(id uint, req *UpdateAuthorizationRequest) (*models.Authorization, error) {
 	var auth models.Authorization
-	err := s.db.First(&auth, id).Error
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, errors.ErrAuthCodeNotFound
+
+	// 锁定授权码记录后再检查席位，避免并发激活使已用席位数超过新的最大席位数
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&auth, id).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrAuthCodeNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权码失败")
 		}
-		return nil, errors.WrapError(err, 50001, "获取授权码失败")
-	}
 
-	// 更新字段
-	if req.CustomerName != "" {
-		auth.CustomerName = req.CustomerName
-	}
-	if req.MaxSeats != nil {
-		// 只能增加席位，不能减少
-		if *req.MaxSeats < auth.UsedSeats {
-			return nil, errors.NewAppError(41001, "最大席位数不能小于已使用席位数")
+		// 更新字段
+		if req.CustomerName != "" {
+			auth.CustomerName = req.CustomerName
+		}
+		oldMaxSeats := auth.MaxSeats
+		if req.MaxSeats != nil {
+			// 只能增加席位，不能减少
+			if *req.MaxSeats < auth.UsedSeats {
+				return errors.NewAppError(41001, "最大席位数不能小于已使用席位数")
+			}
+			auth.MaxSeats = *req.MaxSeats
+		}
+		if req.DurationYears != nil {
+			auth.DurationYears = req.DurationYears
+		}
+		if req.LatestExpiryDate != nil {
+			auth.LatestExpiryDate = req.LatestExpiryDate
+		}
+		if req.Status != nil {
+			auth.Status = *req.Status
+		}
+		if req.Edition != nil {
+			auth.Edition = strings.TrimSpace(*req.Edition)
+		}
+		if req.Features != nil {
+			auth.Features = normalizeFeatures(*req.Features)
+		}
+		if req.Limits != nil {
+			auth.Limits = *req.Limits
+			if len(auth.Limits) == 0 {
+				auth.Limits = nil
+			}
+		}
+		if req.MaxTransfersPerPeriod != nil {
+			auth.MaxTransfersPerPeriod = *req.MaxTransfersPerPeriod
+		}
+		if req.TransferPeriodDays != nil {
+			auth.TransferPeriodDays = *req.TransferPeriodDays
+		}
+		if req.TransferCooldownDays != nil {
+			auth.TransferCooldownDays = *req.TransferCooldownDays
+		}
+		if req.MaxTotalTransfers != nil {
+			auth.MaxTotalTransfers = *req.MaxTotalTransfers
+		}
+
+		// 已用席位由激活和解绑原子更新，这里不覆盖
+		if err := tx.Omit("used_seats").Save(&auth).Error; err != nil {
+			return errors.WrapError(err, 50001, "更新授权码失败")
 		}
-		auth.MaxSeats = *req.MaxSeats
-	}
-	if req.DurationYears != nil {
-		auth.DurationYears = req.DurationYears
-	}
-	if req.LatestExpiryDate != nil {
-		auth.LatestExpiryDate = req.LatestExpiryDate
-	}
-	if req.Status != nil {
-		auth.Status = *req.Status
-	}
 
-	err = s.db.Save(&auth).Error
+		if auth.MaxSeats == oldMaxSeats {
+			return nil
+		}
+		return s.recordSeatChange(tx, auth.ID, 0, auth.MaxSeats-oldMaxSeats, SeatChange{
+			Reason: models.SeatReasonMaxSeats,
+			Remark: fmt.Sprintf("总席位数 %d -> %d", oldMaxSeats, auth.MaxSeats),
+		})
+	})
 	if err != nil {
-		return nil, errors.WrapError(err, 50001, "更新授权码失败")
+		return nil, err
 	}
 
-	return &auth, nil
+	return s.GetAuthorizationByID(auth.ID)
 }
 
 // ListAuthorizations 获取授权码列表
@@ -197,6 +294,9 @@ func (s *AuthorizationService) ListAuthorizations(page, limit int, search string
 			"duration_years":     auth.DurationYears,
 			"latest_expiry_date": auth.LatestExpiryDate,
 			"status":             auth.Status,
+			"edition":            auth.Edition,
+			"features":           auth.Features,
+			"limits":             auth.Limits,
 			"created_at":         auth.CreatedAt,
 			"updated_at":         auth.UpdatedAt,
 			"active_devices":     activeDevices,
@@ -226,73 +326,219 @@ func (s *AuthorizationService) ValidateAuthorizationCode(code string) (*models.A
 
 // ConsumeSeats 消耗席位
 func (s *AuthorizationService) ConsumeSeats(authID uint, count int) error {
-	// 先检查可用席位
-	var auth models.Authorization
-	err := s.db.First(&auth, authID).Error
-	if err != nil {
-		return err
+	return s.ConsumeSeatsWithDB(s.db, authID, count, SeatChange{Reason: models.SeatReasonAdjust})
+}
+
+// ConsumeSeatsWithDB 消耗席位并写入席位台账（使用指定的数据库连接，支持事务）
+// 可用席位检查和扣减在同一条条件更新中完成，并发激活时不会超额占用
+func (s *AuthorizationService) ConsumeSeatsWithDB(db *gorm.DB, authID uint, count int, change SeatChange) error {
+	result := db.Model(&models.Authorization{}).
+		Where("id = ? AND max_seats - used_seats >= ?", authID, count).
+		Update("used_seats", gorm.Expr("used_seats + ?", count))
+	if result.Error != nil {
+		return errors.WrapError(result.Error, 50001, "占用席位失败")
 	}
 
-	if !auth.HasAvailableSeats(count) {
+	if result.RowsAffected == 0 {
+		// 区分授权码不存在和席位不足
+		var exists int64
+		if err := db.Model(&models.Authorization{}).Where("id = ?", authID).Count(&exists).Error; err != nil {
+			return errors.WrapError(err, 50001, "获取授权码失败")
+		}
+		if exists == 0 {
+			return errors.ErrAuthCodeNotFound
+		}
 		return errors.ErrInsufficientSeats
 	}
 
-	// 直接更新使用的席位数
-	err = s.db.Model(&auth).Update("used_seats", gorm.Expr("used_seats + ?", count)).Error
-	if err != nil {
-		return err
+	return s.recordSeatChange(db, authID, count, 0, change)
+}
+
+// ReleaseSeats 释放席位
+func (s *AuthorizationService) ReleaseSeats(authID uint, count int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.ReleaseSeatsWithDB(tx, authID, count, SeatChange{Reason: models.SeatReasonAdjust})
+	})
+}
+
+// ReleaseSeatsWithDB 释放席位并写入席位台账（使用指定的数据库连接，支持事务）
+// 使用条件更新原子扣减，已用席位数不会小于0；先锁定授权码记录，台账记录的释放数量与实际一致
+func (s *AuthorizationService) ReleaseSeatsWithDB(db *gorm.DB, authID uint, count int, change SeatChange) error {
+	var before models.Authorization
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("used_seats").First(&before, authID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errors.ErrAuthCodeNotFound
+		}
+		return errors.WrapError(err, 50001, "获取授权码失败")
 	}
 
-	return nil
+	result := db.Model(&models.Authorization{}).
+		Where("id = ?", authID).
+		Update("used_seats", gorm.Expr("CASE WHEN used_seats > ? THEN used_seats - ? ELSE 0 END", count, count))
+	if result.Error != nil {
+		return errors.WrapError(result.Error, 50001, "释放席位失败")
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.ErrAuthCodeNotFound
+	}
+
+	// 台账记录实际释放的数量
+	released := count
+	if before.UsedSeats < count {
+		released = before.UsedSeats
+	}
+
+	return s.recordSeatChange(db, authID, -released, 0, change)
 }
 
-// ConsumeSeatsWithDB 在指定数据库连接中消耗席位（支持事务）
-func (s *AuthorizationService) ConsumeSeatsWithDB(db *gorm.DB, authID uint, count int) error {
-	// 先检查可用席位
+// RecordSeatChangeWithDB 记录不改变席位数的变更（如设备转移），保持台账完整
+func (s *AuthorizationService) RecordSeatChangeWithDB(db *gorm.DB, authID uint, change SeatChange) error {
+	return s.recordSeatChange(db, authID, 0, 0, change)
+}
+
+// recordSeatChange 写入席位台账，记录变更后的席位数
+func (s *AuthorizationService) recordSeatChange(db *gorm.DB, authID uint, usedDelta, maxDelta int, change SeatChange) error {
 	var auth models.Authorization
-	err := db.First(&auth, authID).Error
-	if err != nil {
-		return err
+	if err := db.Select("id", "used_seats", "max_seats").First(&auth, authID).Error; err != nil {
+		return errors.WrapError(err, 50001, "获取授权码失败")
 	}
 
-	if !auth.HasAvailableSeats(count) {
-		return errors.ErrInsufficientSeats
+	entry := models.SeatLedger{
+		AuthorizationID: authID,
+		LicenseID:       change.LicenseID,
+		Reason:          change.Reason,
+		UsedDelta:       usedDelta,
+		MaxDelta:        maxDelta,
+		UsedSeats:       auth.UsedSeats,
+		MaxSeats:        auth.MaxSeats,
+		ActorType:       s.actor.Type,
+		ActorID:         s.actor.ID,
+		ActorName:       s.actor.Name,
+		IPAddress:       s.actor.IPAddress,
+		Remark:          change.Remark,
 	}
+	if err := db.Create(&entry).Error; err != nil {
+		return errors.WrapError(err, 50001, "写入席位台账失败")
+	}
+
+	return nil
+}
+
+// ListSeatLedger 分页获取授权码的席位台账，按时间倒序
+func (s *AuthorizationService) ListSeatLedger(authID uint, page, limit int) ([]models.SeatLedger, int64, error) {
+	var entries []models.SeatLedger
+	var total int64
+
+	query := s.db.Model(&models.SeatLedger{}).Where("authorization_id = ?", authID)
 
-	// 直接更新使用的席位数
-	err = db.Model(&auth).Update("used_seats", gorm.Expr("used_seats + ?", count)).Error
+	err := query.Count(&total).Error
 	if err != nil {
-		return err
+		return nil, 0, errors.WrapError(err, 50001, "获取席位台账总数失败")
 	}
 
-	return nil
+	offset := (page - 1) * limit
+	err = query.Offset(offset).Limit(limit).Order("id DESC").Find(&entries).Error
+	if err != nil {
+		return nil, 0, errors.WrapError(err, 50001, "获取席位台账失败")
+	}
+
+	return entries, total, nil
 }
 
-// ReleaseSeats 释放席位
-func (s *AuthorizationService) ReleaseSeats(authID uint, count int) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		// 先获取当前记录
-		var auth models.Authorization
-		err := tx.First(&auth, authID).Error
-		if err != nil {
-			return errors.WrapError(err, 50001, "获取授权码失败")
+// SeatDiscrepancy 席位计数与实际占用席位的设备数不一致的授权码
+type SeatDiscrepancy struct {
+	AuthorizationID   uint   `json:"authorization_id"`
+	AuthorizationCode string `json:"authorization_code"`
+	CustomerName      string `json:"customer_name"`
+	MaxSeats          int    `json:"max_seats"`
+	RecordedSeats     int    `json:"recorded_seats"` // 授权码记录的已用席位数
+	ActualSeats       int    `json:"actual_seats"`   // 实际占用席位的有效设备数
+	Fixed             bool   `json:"fixed"`
+}
+
+// ReconcileSeats 根据有效设备重新计算各授权码的已用席位数，fix为true时修正不一致的记录
+// 免席位的试用授权不计入；已过期但未解绑的设备仍占用席位
+func (s *AuthorizationService) ReconcileSeats(fix bool) ([]SeatDiscrepancy, error) {
+	var counts []struct {
+		AuthorizationID uint
+		Total           int
+	}
+	err := s.db.Model(&models.License{}).
+		Select("authorization_id, COUNT(*) AS total").
+		Where("status = ? AND seat_exempt = ?", models.LicenseStatusActive, false).
+		Group("authorization_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, errors.WrapError(err, 50001, "统计有效设备失败")
+	}
+
+	actual := make(map[uint]int, len(counts))
+	for _, c := range counts {
+		actual[c.AuthorizationID] = c.Total
+	}
+
+	var auths []models.Authorization
+	if err := s.db.Order("id ASC").Find(&auths).Error; err != nil {
+		return nil, errors.WrapError(err, 50001, "获取授权码列表失败")
+	}
+
+	discrepancies := make([]SeatDiscrepancy, 0)
+	for _, auth := range auths {
+		if auth.UsedSeats == actual[auth.ID] {
+			continue
 		}
 
-		// 计算新的已用席位数，确保不会小于0
-		newUsedSeats := auth.UsedSeats - count
-		if newUsedSeats < 0 {
-			newUsedSeats = 0
+		discrepancy := SeatDiscrepancy{
+			AuthorizationID:   auth.ID,
+			AuthorizationCode: auth.AuthorizationCode,
+			CustomerName:      auth.CustomerName,
+			MaxSeats:          auth.MaxSeats,
+			RecordedSeats:     auth.UsedSeats,
+			ActualSeats:       actual[auth.ID],
 		}
 
-		// 更新已用席位数
-		auth.UsedSeats = newUsedSeats
-		err = tx.Save(&auth).Error
-		if err != nil {
-			return errors.WrapError(err, 50001, "释放席位失败")
+		if fix {
+			// 先锁定授权码记录再重新统计：激活和解绑会更新同一行，锁定后统计期间无法并发修改席位
+			err := s.db.Transaction(func(tx *gorm.DB) error {
+				var current models.Authorization
+				err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("used_seats").First(&current, auth.ID).Error
+				if err != nil {
+					return err
+				}
+
+				var total int64
+				err = tx.Model(&models.License{}).
+					Where("authorization_id = ? AND status = ? AND seat_exempt = ?", auth.ID, models.LicenseStatusActive, false).
+					Count(&total).Error
+				if err != nil {
+					return err
+				}
+				discrepancy.ActualSeats = int(total)
+
+				if err := tx.Model(&models.Authorization{}).Where("id = ?", auth.ID).Update("used_seats", total).Error; err != nil {
+					return err
+				}
+				return s.recordSeatChange(tx, auth.ID, int(total)-current.UsedSeats, 0, SeatChange{
+					Reason: models.SeatReasonReconcile,
+					Remark: fmt.Sprintf("已用席位数 %d -> %d", current.UsedSeats, total),
+				})
+			})
+			if err != nil {
+				return nil, errors.WrapError(err, 50001, "修正已用席位数失败")
+			}
+			discrepancy.Fixed = true
+
+			logger.GetLogger().Warn("已修正授权码席位计数",
+				zap.String("auth_code", auth.AuthorizationCode),
+				zap.Int("recorded_seats", discrepancy.RecordedSeats),
+				zap.Int("actual_seats", discrepancy.ActualSeats))
 		}
 
-		return nil
-	})
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies, nil
 }
 
 // DeleteAuthorization 删除授权码（软删除）
@@ -390,3 +636,23 @@ func (s *AuthorizationService) GetStatistics() (map[string]interface{}, error) {
 
 	return stats, nil
 }
+
+// normalizeFeatures 去除功能名称首尾空格并去重，保持原有顺序
+func normalizeFeatures(features []string) []string {
+	if len(features) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(features))
+	result := make([]string, 0, len(features))
+	for _, feature := range features {
+		feature = strings.TrimSpace(feature)
+		if feature == "" || seen[feature] {
+			continue
+		}
+		seen[feature] = true
+		result = append(result, feature)
+	}
+
+	return result
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"crypto/rsa"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lyenrowe/LicenseCenter/internal/config"
+	"github.com/lyenrowe/LicenseCenter/internal/database"
+	"github.com/lyenrowe/LicenseCenter/internal/models"
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+	"github.com/lyenrowe/LicenseCenter/pkg/errors"
+	"github.com/lyenrowe/LicenseCenter/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultKeyringCheckSeconds 未配置时检查密钥环版本的间隔秒数
+const defaultKeyringCheckSeconds = 30
+
+// keyringCache 进程内共享的服务端密钥环缓存
+var keyringCache = &keyringStore{}
+
+// keyringKey 已解析的服务端密钥
+type keyringKey struct {
+	record      models.RSAKey
+	signer      crypto.Signer
+	certificate *crypto.Certificate // 根密钥签发的证书，未导入时为空
+	privateKey  *rsa.PrivateKey     // 仅RSA密钥，用于解密文件信封
+	publicKey   *rsa.PublicKey      // 仅RSA密钥，用于加密文件信封
+}
+
+// keyring 某一版本下所有可用的服务端密钥（活跃和宽限期内的密钥）
+type keyring struct {
+	source    *gorm.DB // 加载时的全局数据库连接，数据库重新初始化后缓存失效
+	version   string
+	active    *keyringKey            // 活跃的RSA密钥，用于加密文件信封
+	signers   map[string]*keyringKey // 按签名算法索引的活跃密钥
+	keys      map[string]*keyringKey // 按密钥ID索引
+	decrypt   []*keyringKey          // 可解密的RSA密钥，活跃密钥在前，其余按创建时间倒序
+	checkedAt time.Time
+}
+
+// keyringStore 密钥环缓存，密钥轮换或退役时更新版本号，其他实例定期比对版本号后重新加载
+type keyringStore struct {
+	mu      sync.RWMutex
+	current *keyring
+}
+
+// get 获取仍在检查间隔内的缓存
+func (c *keyringStore) get() *keyring {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.current == nil || c.current.source != database.GetDB() {
+		return nil
+	}
+	if time.Since(c.current.checkedAt) >= keyringCheckInterval() {
+		return nil
+	}
+	return c.current
+}
+
+// stale 获取已超过检查间隔、需要比对版本号的缓存
+func (c *keyringStore) stale() *keyring {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.current == nil || c.current.source != database.GetDB() {
+		return nil
+	}
+	return c.current
+}
+
+// store 保存新加载的密钥环
+func (c *keyringStore) store(kr *keyring) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = kr
+}
+
+// touch 版本号未变化时刷新检查时间
+func (c *keyringStore) touch(kr *keyring) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == kr {
+		kr.checkedAt = time.Now()
+	}
+}
+
+// invalidate 清空缓存，下次使用时重新加载
+func (c *keyringStore) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = nil
+}
+
+// loadKeyring 获取密钥环，超过检查间隔时比对版本号，版本变化后重新从数据库加载
+func (s *RSAService) loadKeyring() (*keyring, error) {
+	if kr := keyringCache.get(); kr != nil {
+		return kr, nil
+	}
+
+	version, err := s.keyringVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if kr := keyringCache.stale(); kr != nil && kr.version == version {
+		keyringCache.touch(kr)
+		return kr, nil
+	}
+
+	var records []models.RSAKey
+	err = s.db.Where("status IN ?", []string{models.RSAKeyStatusActive, models.RSAKeyStatusRetiring}).
+		Order("id DESC").Find(&records).Error
+	if err != nil {
+		return nil, errors.WrapError(err, 50001, "获取RSA密钥列表失败")
+	}
+
+	kr := &keyring{
+		source:    database.GetDB(),
+		version:   version,
+		signers:   make(map[string]*keyringKey),
+		keys:      make(map[string]*keyringKey, len(records)),
+		checkedAt: time.Now(),
+	}
+	for i := range records {
+		key, err := newKeyringKey(&records[i])
+		if err != nil {
+			logger.GetLogger().Error("解析服务端密钥失败，已跳过",
+				zap.String("key_id", records[i].KeyID),
+				zap.Error(err))
+			continue
+		}
+
+		kr.keys[key.record.KeyID] = key
+		algorithm := key.record.SignatureAlgorithm()
+		if _, ok := kr.signers[algorithm]; key.record.IsActive() && !ok {
+			kr.signers[algorithm] = key
+		}
+		if !key.record.IsRSA() {
+			continue
+		}
+		if key.record.IsActive() && kr.active == nil {
+			kr.active = key
+			kr.decrypt = append([]*keyringKey{key}, kr.decrypt...)
+		} else {
+			kr.decrypt = append(kr.decrypt, key)
+		}
+	}
+
+	// 事务中读取的密钥可能随事务回滚，只缓存事务外加载的结果
+	if _, inTx := s.db.Statement.ConnPool.(gorm.TxCommitter); !inTx {
+		keyringCache.store(kr)
+	}
+
+	logger.GetLogger().Debug("已加载服务端密钥环",
+		zap.String("version", version),
+		zap.Int("keys", len(kr.keys)))
+
+	return kr, nil
+}
+
+// newKeyringKey 解析密钥记录，RSA密钥同时保留加解密所需的密钥对
+func newKeyringKey(record *models.RSAKey) (*keyringKey, error) {
+	signer, err := parseSigner(record)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &keyringKey{record: *record, signer: signer}
+	if record.Certificate != "" {
+		// 证书无法解析时仍可签名，只是不再附带证书
+		key.certificate, err = crypto.ParseCertificate(record.Certificate)
+		if err != nil {
+			logger.GetLogger().Warn("解析签名密钥证书失败",
+				zap.String("key_id", record.KeyID),
+				zap.Error(err))
+		}
+	}
+	if record.IsRSA() {
+		key.privateKey, key.publicKey, err = parseKeyPair(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// keyringVersion 获取数据库中的密钥环版本号，从未轮换过时为空
+func (s *RSAService) keyringVersion() (string, error) {
+	var setting models.SystemConfig
+	err := s.db.Where("config_key = ?", models.ConfigKeyringVersion).Limit(1).Find(&setting).Error
+	if err != nil {
+		return "", errors.WrapError(err, 50001, "获取密钥环版本失败")
+	}
+
+	return setting.ConfigValue, nil
+}
+
+// bumpKeyringVersionWithDB 更新密钥环版本号，通知所有实例重新加载密钥
+func bumpKeyringVersionWithDB(db *gorm.DB) error {
+	setting := models.SystemConfig{
+		ConfigKey:   models.ConfigKeyringVersion,
+		ConfigValue: uuid.New().String(),
+		Description: "服务端密钥环版本，密钥轮换或退役时更新",
+		UpdatedAt:   time.Now(),
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "config_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"config_value", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return errors.WrapError(err, 50001, "更新密钥环版本失败")
+	}
+
+	return nil
+}
+
+// keyringCheckInterval 获取检查密钥环版本的间隔
+func keyringCheckInterval() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.Security.KeyringCheckSeconds > 0 {
+		return time.Duration(config.AppConfig.Security.KeyringCheckSeconds) * time.Second
+	}
+	return defaultKeyringCheckSeconds * time.Second
+}
@@ -2,25 +2,25 @@ package services
 
 import (
 	"bytes"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
-	"encoding/base64"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lyenrowe/LicenseCenter/internal/config"
 	"github.com/lyenrowe/LicenseCenter/internal/database"
 	"github.com/lyenrowe/LicenseCenter/internal/models"
+	"github.com/lyenrowe/LicenseCenter/pkg/client"
 	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
 	"github.com/lyenrowe/LicenseCenter/pkg/errors"
 	"github.com/lyenrowe/LicenseCenter/pkg/logger"
 	"github.com/lyenrowe/LicenseCenter/pkg/utils"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // LicenseService 授权服务
@@ -39,50 +39,33 @@ func NewLicenseService() *LicenseService {
 	}
 }
 
-// BindFile 绑定请求文件结构
-type BindFile struct {
-	Hostname    string    `json:"hostname"`
-	MachineID   string    `json:"machine_id"`
-	RequestTime time.Time `json:"request_time"`
-}
-
-// LicenseFile 授权文件结构
-type LicenseFile struct {
-	LicenseData LicenseData `json:"license_data"`
-	Signature   string      `json:"signature"`
-}
-
-// LicenseData 授权数据结构
-type LicenseData struct {
-	LicenseKey       string    `json:"license_key"`
-	MachineID        string    `json:"machine_id"`
-	Hostname         string    `json:"hostname"`
-	IssuedAt         time.Time `json:"issued_at"`
-	ExpiresAt        time.Time `json:"expires_at"`
-	LicenseType      string    `json:"license_type"`
-	UnbindPrivateKey string    `json:"unbind_private_key"`
-}
-
-// UnbindFile 解绑文件结构
-type UnbindFile struct {
-	LicenseKey     string         `json:"license_key"`
-	MachineID      string         `json:"machine_id"`
-	UnbindMetadata UnbindMetadata `json:"unbind_metadata"`
-	UnbindProof    string         `json:"unbind_proof"`
+// WithActor 创建以指定操作者身份执行的授权服务实例，席位变更记录该操作者
+func (s *LicenseService) WithActor(actor Actor) *LicenseService {
+	return &LicenseService{
+		db:          s.db,
+		rsaService:  s.rsaService,
+		authService: s.authService.WithActor(actor),
+	}
 }
 
-// UnbindMetadata 解绑元数据
-type UnbindMetadata struct {
-	UnbindTime    time.Time `json:"unbind_time"`
-	Hostname      string    `json:"hostname"`
-	ClientVersion string    `json:"client_version"`
-	UnbindReason  string    `json:"unbind_reason"`
-}
+// 授权相关文件结构与客户端SDK共用，保证服务端签名和客户端验签使用同一份定义
+type (
+	BindFile       = client.BindFile
+	LicenseFile    = client.LicenseFile
+	LicenseData    = client.LicenseData
+	UnbindFile     = client.UnbindFile
+	UnbindMetadata = client.UnbindMetadata
+	RenewalFile    = client.RenewalFile
+	RenewalData    = client.RenewalData
+	RevocationList = client.RevocationList
+	RevocationData = client.RevocationData
+	RevocationItem = client.RevocationItem
+)
 
 // EncryptedFileResponse 加密文件响应结构
 type EncryptedFileResponse struct {
 	EncryptedContent string `json:"encrypted_content"` // Base64编码的加密数据
-	FileType         string `json:"file_type"`         // 文件类型：bind, license, unbind
+	FileType         string `json:"file_type"`         // 文件类型：bind, license, unbind, renew
 }
 
 // ActivateLicensesEncrypted 批量激活设备（返回加密文件）
@@ -102,7 +85,7 @@ func (s *LicenseService) ActivateLicensesEncrypted(authCode string, encryptedBin
 	// 3. 使用对应的客户端AES密钥加密每个授权文件
 	var encryptedLicenseFiles []EncryptedFileResponse
 	for i, licenseFile := range licenseFiles {
-		encryptedFile, err := s.EncryptLicenseFileWithClientAES(licenseFile, clientAESKeys[i])
+		encryptedFile, err := s.EncryptLicenseFileForDevice(licenseFile, bindFiles[i].ClientPublicKey, clientAESKeys[i])
 		if err != nil {
 			return nil, err
 		}
@@ -112,6 +95,128 @@ func (s *LicenseService) ActivateLicensesEncrypted(authCode string, encryptedBin
 	return encryptedLicenseFiles, nil
 }
 
+// ActivationPreview 激活预检报告
+type ActivationPreview struct {
+	MaxSeats       int                     `json:"max_seats"`
+	UsedSeats      int                     `json:"used_seats"`
+	AvailableSeats int                     `json:"available_seats"`
+	SeatsRequired  int                     `json:"seats_required"`  // 可激活的新设备需要的席位数
+	SeatsRemaining int                     `json:"seats_remaining"` // 激活后剩余席位数
+	CanActivateAll bool                    `json:"can_activate_all"`
+	Items          []ActivationPreviewItem `json:"items"`
+}
+
+// ActivationPreviewItem 单个绑定文件的预检结果
+type ActivationPreviewItem struct {
+	Index     int        `json:"index"` // 文件在本次上传中的序号，从1开始
+	Filename  string     `json:"filename,omitempty"`
+	Action    string     `json:"action"` // activate, reissue, reject
+	MachineID string     `json:"machine_id,omitempty"`
+	Hostname  string     `json:"hostname,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // 激活后的到期时间
+	ErrorCode int        `json:"error_code,omitempty"`
+	Message   string     `json:"message,omitempty"`
+}
+
+// 预检结果的处理方式
+const (
+	PreviewActionActivate = "activate" // 激活新设备并消耗席位
+	PreviewActionReissue  = "reissue"  // 设备已激活，重新签发原授权
+	PreviewActionReject   = "reject"   // 无法激活
+)
+
+// reject 记录无法激活的错误码和原因
+func (i *ActivationPreviewItem) reject(err error) {
+	i.Action = PreviewActionReject
+	i.ExpiresAt = nil
+	i.ErrorCode, i.Message = activationErrorDetail(err)
+}
+
+// PreviewActivation 预检批量激活：按激活的规则检查绑定文件、重复设备和席位，不写入任何数据
+func (s *LicenseService) PreviewActivation(authCode string, encryptedBindFiles []string) (*ActivationPreview, error) {
+	auth, err := s.authService.ValidateAuthorizationCode(authCode)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ActivationPreview{
+		MaxSeats:       auth.MaxSeats,
+		UsedSeats:      auth.UsedSeats,
+		AvailableSeats: auth.GetAvailableSeats(),
+		CanActivateAll: true,
+		Items:          make([]ActivationPreviewItem, len(encryptedBindFiles)),
+	}
+
+	expiresAt := auth.CalculateExpiryDate()
+	batchMachines := make(map[string]bool)
+
+	for i, encryptedData := range encryptedBindFiles {
+		item := &preview.Items[i]
+		item.Index = i + 1
+
+		bindFiles, _, err := s.DecryptBindFilesAndExtractAESKeys([]string{encryptedData})
+		if err != nil {
+			item.reject(err)
+			continue
+		}
+		bindFile := bindFiles[0]
+		item.MachineID = bindFile.MachineID
+		item.Hostname = bindFile.Hostname
+
+		if err := s.validateBindFile(&bindFile); err != nil {
+			item.reject(err)
+			continue
+		}
+
+		// 同一批次中重复的设备在激活时按重新签发处理
+		if batchMachines[bindFile.MachineID] {
+			item.Action = PreviewActionReissue
+			item.ExpiresAt = &expiresAt
+			continue
+		}
+
+		var existing models.License
+		err = s.db.Where("machine_id = ? AND status = ?",
+			bindFile.MachineID, models.LicenseStatusActive).First(&existing).Error
+		if err == nil {
+			switch {
+			case existing.AuthorizationID != auth.ID || existing.IsTrial():
+				item.reject(errors.ErrDuplicateMachine)
+			case existing.IsExpired():
+				item.reject(errors.NewAppError(41006, "授权已过期，无法下载"))
+			default:
+				item.Action = PreviewActionReissue
+				existingExpiresAt := existing.ExpiresAt
+				item.ExpiresAt = &existingExpiresAt
+			}
+			continue
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, errors.WrapError(err, 50001, "检查机器状态失败")
+		}
+
+		// 席位按上传顺序分配
+		if preview.SeatsRequired >= preview.AvailableSeats {
+			item.reject(errors.ErrInsufficientSeats)
+			continue
+		}
+
+		batchMachines[bindFile.MachineID] = true
+		preview.SeatsRequired++
+		item.Action = PreviewActionActivate
+		item.ExpiresAt = &expiresAt
+	}
+
+	for _, item := range preview.Items {
+		if item.Action == PreviewActionReject {
+			preview.CanActivateAll = false
+		}
+	}
+	preview.SeatsRemaining = preview.AvailableSeats - preview.SeatsRequired
+
+	return preview, nil
+}
+
 // TransferLicenseEncrypted 授权转移（使用加密文件）
 func (s *LicenseService) TransferLicenseEncrypted(authCode string, encryptedUnbindFile, encryptedBindFile string) (*EncryptedFileResponse, error) {
 	// 1. 解密文件
@@ -126,19 +231,13 @@ func (s *LicenseService) TransferLicenseEncrypted(authCode string, encryptedUnbi
 	}
 
 	// 2. 从解密过程中提取新设备的AES密钥
-	privateKey, _, err := s.rsaService.GetActiveKeyPair()
-	if err != nil {
-		return nil, err
-	}
-
-	_, newDeviceAESKey, err := s.decryptFileAndExtractAESKey(privateKey, encryptedBindFile)
+	_, newDeviceAESKey, err := s.decryptFileAndExtractAESKey(encryptedBindFile, crypto.FileTypeBind)
 	if err != nil {
 		return nil, errors.WrapError(err, 41003, "提取新设备AES密钥失败")
 	}
 
-	// 验证AES密钥与机器ID匹配
-	expectedAESKey := crypto.GenerateClientAESKey(bindFile.MachineID)
-	if !bytes.Equal(newDeviceAESKey, expectedAESKey) {
+	// v1绑定文件验证AES密钥与机器ID匹配
+	if bindFile.ClientPublicKey == "" && !bytes.Equal(newDeviceAESKey, crypto.GenerateClientAESKey(bindFile.MachineID)) {
 		return nil, errors.NewAppError(41003, "新设备AES密钥与机器ID不匹配")
 	}
 
@@ -149,7 +248,7 @@ func (s *LicenseService) TransferLicenseEncrypted(authCode string, encryptedUnbi
 	}
 
 	// 4. 使用新设备的AES密钥加密新的授权文件
-	encryptedLicenseFile, err := s.EncryptLicenseFileWithClientAES(*newLicenseFile, newDeviceAESKey)
+	encryptedLicenseFile, err := s.EncryptLicenseFileForDevice(*newLicenseFile, bindFile.ClientPublicKey, newDeviceAESKey)
 	if err != nil {
 		return nil, err
 	}
@@ -159,14 +258,9 @@ func (s *LicenseService) TransferLicenseEncrypted(authCode string, encryptedUnbi
 
 // DecryptBindFiles 解密绑定文件列表
 func (s *LicenseService) DecryptBindFiles(encryptedBindFiles []string) ([]BindFile, error) {
-	privateKey, _, err := s.rsaService.GetActiveKeyPair()
-	if err != nil {
-		return nil, err
-	}
-
 	var bindFiles []BindFile
 	for i, encryptedData := range encryptedBindFiles {
-		jsonData, err := crypto.DecryptFileFromBase64(privateKey, encryptedData)
+		jsonData, _, err := s.rsaService.DecryptFileAs(encryptedData, crypto.FileTypeBind)
 		if err != nil {
 			return nil, errors.WrapError(err, 41003, fmt.Sprintf("解密第%d个绑定文件失败", i+1))
 		}
@@ -184,17 +278,12 @@ func (s *LicenseService) DecryptBindFiles(encryptedBindFiles []string) ([]BindFi
 
 // DecryptBindFilesAndExtractAESKeys 解密绑定文件并从解密过程中提取客户端AES密钥
 func (s *LicenseService) DecryptBindFilesAndExtractAESKeys(encryptedBindFiles []string) ([]BindFile, [][]byte, error) {
-	privateKey, _, err := s.rsaService.GetActiveKeyPair()
-	if err != nil {
-		return nil, nil, err
-	}
-
 	var bindFiles []BindFile
 	var clientAESKeys [][]byte
 
 	for i, encryptedData := range encryptedBindFiles {
 		// 解密并提取AES密钥
-		jsonData, aesKey, err := s.decryptFileAndExtractAESKey(privateKey, encryptedData)
+		jsonData, aesKey, err := s.decryptFileAndExtractAESKey(encryptedData, crypto.FileTypeBind)
 		if err != nil {
 			return nil, nil, errors.WrapError(err, 41003, fmt.Sprintf("解密第%d个绑定文件失败", i+1))
 		}
@@ -204,9 +293,8 @@ func (s *LicenseService) DecryptBindFilesAndExtractAESKeys(encryptedBindFiles []
 			return nil, nil, errors.WrapError(err, 41003, fmt.Sprintf("解析第%d个绑定文件失败", i+1))
 		}
 
-		// 验证提取的AES密钥是否与机器ID匹配
-		expectedAESKey := crypto.GenerateClientAESKey(bindFile.MachineID)
-		if !bytes.Equal(aesKey, expectedAESKey) {
+		// v1绑定文件使用机器ID派生的AES密钥，需与机器ID匹配；v2绑定文件携带客户端公钥，AES密钥随机生成
+		if bindFile.ClientPublicKey == "" && !bytes.Equal(aesKey, crypto.GenerateClientAESKey(bindFile.MachineID)) {
 			return nil, nil, errors.NewAppError(41003, fmt.Sprintf("第%d个绑定文件的AES密钥与机器ID不匹配", i+1))
 		}
 
@@ -217,51 +305,14 @@ func (s *LicenseService) DecryptBindFilesAndExtractAESKeys(encryptedBindFiles []
 	return bindFiles, clientAESKeys, nil
 }
 
-// decryptFileAndExtractAESKey 解密文件并提取其中的AES密钥
-func (s *LicenseService) decryptFileAndExtractAESKey(privateKey *rsa.PrivateKey, base64Data string) ([]byte, []byte, error) {
-	// Base64解码
-	encryptedData, err := base64.StdEncoding.DecodeString(base64Data)
-	if err != nil {
-		return nil, nil, fmt.Errorf("Base64解码失败: %w", err)
-	}
-
-	if len(encryptedData) < 4 {
-		return nil, nil, fmt.Errorf("加密数据格式错误：数据太短")
-	}
-
-	// 解析数据格式：读取AES密钥长度
-	keyLen := binary.BigEndian.Uint32(encryptedData[0:4])
-	if len(encryptedData) < int(4+keyLen) {
-		return nil, nil, fmt.Errorf("加密数据格式错误：AES密钥数据不完整")
-	}
-
-	// 提取RSA加密的AES密钥和AES加密的数据
-	encryptedAESKey := encryptedData[4 : 4+keyLen]
-	aesEncryptedData := encryptedData[4+keyLen:]
-
-	// 使用RSA解密AES密钥
-	aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, encryptedAESKey, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("RSA解密AES密钥失败: %w", err)
-	}
-
-	// 使用AES解密数据
-	jsonData, err := crypto.AESGCMDecrypt(aesEncryptedData, aesKey)
-	if err != nil {
-		return nil, nil, fmt.Errorf("AES解密数据失败: %w", err)
-	}
-
-	return jsonData, aesKey, nil
+// decryptFileAndExtractAESKey 解密文件并提取其中的AES密钥（按信封中的密钥ID选择服务端私钥）
+func (s *LicenseService) decryptFileAndExtractAESKey(base64Data string, fileType crypto.FileType) ([]byte, []byte, error) {
+	return s.rsaService.DecryptFileAs(base64Data, fileType)
 }
 
 // DecryptBindFile 解密单个绑定文件
 func (s *LicenseService) DecryptBindFile(encryptedBindFile string) (*BindFile, error) {
-	privateKey, _, err := s.rsaService.GetActiveKeyPair()
-	if err != nil {
-		return nil, err
-	}
-
-	jsonData, err := crypto.DecryptFileFromBase64(privateKey, encryptedBindFile)
+	jsonData, _, err := s.rsaService.DecryptFileAs(encryptedBindFile, crypto.FileTypeBind)
 	if err != nil {
 		return nil, errors.WrapError(err, 41003, "解密绑定文件失败")
 	}
@@ -276,12 +327,7 @@ func (s *LicenseService) DecryptBindFile(encryptedBindFile string) (*BindFile, e
 
 // DecryptUnbindFile 解密解绑文件
 func (s *LicenseService) DecryptUnbindFile(encryptedUnbindFile string) (*UnbindFile, error) {
-	privateKey, _, err := s.rsaService.GetActiveKeyPair()
-	if err != nil {
-		return nil, err
-	}
-
-	jsonData, err := crypto.DecryptFileFromBase64(privateKey, encryptedUnbindFile)
+	jsonData, _, err := s.rsaService.DecryptFileAs(encryptedUnbindFile, crypto.FileTypeUnbind)
 	if err != nil {
 		return nil, errors.WrapError(err, 41004, "解密解绑文件失败")
 	}
@@ -347,31 +393,61 @@ func (s *LicenseService) EncryptLicenseFile(licenseFile LicenseFile) (*Encrypted
 
 // EncryptLicenseFileWithClientAES 使用客户端AES密钥加密授权文件
 func (s *LicenseService) EncryptLicenseFileWithClientAES(licenseFile LicenseFile, clientAESKey []byte) (*EncryptedFileResponse, error) {
+	return s.EncryptLicenseFileForDevice(licenseFile, "", clientAESKey)
+}
+
+// EncryptLicenseFileForDevice 加密返回给设备的授权文件
+// 设备提供了X25519公钥时加密给该公钥，否则使用旧版基于机器ID的客户端AES密钥
+func (s *LicenseService) EncryptLicenseFileForDevice(licenseFile LicenseFile, clientPublicKey string, clientAESKey []byte) (*EncryptedFileResponse, error) {
 	// 1. 序列化license
 	jsonData, err := json.Marshal(licenseFile)
 	if err != nil {
 		return nil, errors.WrapError(err, 50002, "序列化授权文件失败")
 	}
 
-	// 2. 获取服务端公钥（用于混合加密）
-	_, publicKey, err := s.rsaService.GetActiveKeyPair()
+	// 2. 加密给设备
+	encryptedContent, err := s.encryptForDevice(jsonData, crypto.FileTypeLicense, clientPublicKey, clientAESKey)
 	if err != nil {
 		return nil, err
 	}
 
-	// 3. 使用客户端AES密钥进行混合加密
-	encryptedContent, err := crypto.EncryptFileToBase64WithClientKey(publicKey, jsonData, clientAESKey)
-	if err != nil {
-		return nil, errors.WrapError(err, 50002, "混合加密授权文件失败")
-	}
-
 	return &EncryptedFileResponse{
 		EncryptedContent: encryptedContent,
 		FileType:         "license",
 	}, nil
 }
 
-// EncryptBindFile 加密绑定文件（客户端使用，使用基于机器ID的AES密钥）
+// encryptForDevice 加密发给设备的文件
+// 设备提供了客户端公钥时生成带文件类型的v3信封；旧版设备无法识别v3信封，仍使用客户端AES密钥与服务端公钥混合加密的v1信封
+func (s *LicenseService) encryptForDevice(jsonData []byte, fileType crypto.FileType, clientPublicKey string, clientAESKey []byte) (string, error) {
+	return s.encryptForDeviceWithDB(s.db, jsonData, fileType, clientPublicKey, clientAESKey)
+}
+
+// encryptForDeviceWithDB 加密发给设备的文件（使用指定的数据库连接读取服务端密钥，支持事务）
+func (s *LicenseService) encryptForDeviceWithDB(db *gorm.DB, jsonData []byte, fileType crypto.FileType, clientPublicKey string, clientAESKey []byte) (string, error) {
+	if clientPublicKey != "" {
+		encryptedContent, err := crypto.SealToClientBase64(clientPublicKey, fileType, jsonData)
+		if err != nil {
+			return "", errors.WrapError(err, 50002, "加密文件失败")
+		}
+		return encryptedContent, nil
+	}
+
+	// 获取服务端公钥（用于混合加密）
+	_, publicKey, err := s.rsaService.WithDB(db).GetActiveKeyPair()
+	if err != nil {
+		return "", err
+	}
+
+	encryptedContent, err := crypto.EncryptFileToBase64WithClientKey(publicKey, jsonData, clientAESKey)
+	if err != nil {
+		return "", errors.WrapError(err, 50002, "混合加密文件失败")
+	}
+
+	return encryptedContent, nil
+}
+
+// EncryptBindFile 加密绑定文件（客户端使用）
 func (s *LicenseService) EncryptBindFile(bindFile BindFile) (*EncryptedFileResponse, error) {
 	_, publicKey, err := s.rsaService.GetActiveKeyPair()
 	if err != nil {
@@ -383,11 +459,12 @@ func (s *LicenseService) EncryptBindFile(bindFile BindFile) (*EncryptedFileRespo
 		return nil, errors.WrapError(err, 50002, "序列化绑定文件失败")
 	}
 
-	// 生成基于机器ID的客户端AES密钥
-	clientAESKey := crypto.GenerateClientAESKey(bindFile.MachineID)
-
-	// 使用客户端AES密钥进行混合加密
-	encryptedContent, err := crypto.EncryptFileToBase64WithClientKey(publicKey, jsonData, clientAESKey)
+	// 携带客户端公钥的绑定文件使用随机AES密钥；否则使用基于机器ID的客户端AES密钥
+	var aesKey []byte
+	if bindFile.ClientPublicKey == "" {
+		aesKey = crypto.GenerateClientAESKey(bindFile.MachineID)
+	}
+	encryptedContent, err := crypto.SealFileToBase64(publicKey, crypto.FileTypeBind, jsonData, aesKey)
 	if err != nil {
 		return nil, errors.WrapError(err, 50002, "混合加密绑定文件失败")
 	}
@@ -414,7 +491,7 @@ func (s *LicenseService) EncryptUnbindFile(unbindFile UnbindFile) (*EncryptedFil
 	clientAESKey := crypto.GenerateClientAESKey(unbindFile.MachineID)
 
 	// 使用客户端AES密钥进行混合加密
-	encryptedContent, err := crypto.EncryptFileToBase64WithClientKey(publicKey, jsonData, clientAESKey)
+	encryptedContent, err := crypto.SealFileToBase64(publicKey, crypto.FileTypeUnbind, jsonData, clientAESKey)
 	if err != nil {
 		return nil, errors.WrapError(err, 50002, "混合加密解绑文件失败")
 	}
@@ -433,70 +510,182 @@ func (s *LicenseService) ActivateLicenses(authCode string, bindFiles []BindFile)
 		return nil, err
 	}
 
-	// 检查席位是否足够
-	if !auth.HasAvailableSeats(len(bindFiles)) {
-		return nil, errors.ErrInsufficientSeats
-	}
-
 	var licenseFiles []LicenseFile
 
 	// 使用事务确保批量操作的原子性
 	err = s.db.Transaction(func(tx *gorm.DB) error {
 		for _, bindFile := range bindFiles {
-			// 验证绑定文件
-			if err := s.validateBindFile(&bindFile); err != nil {
+			licenseFile, _, err := s.activateLicenseWithDB(tx, auth, &bindFile)
+			if err != nil {
 				return err
 			}
 
-			// 检查机器是否已经激活
-			var existing models.License
-			err := tx.Where("machine_id = ? AND status = ?",
-				bindFile.MachineID, models.LicenseStatusActive).First(&existing).Error
-			if err == nil {
-				// 记录设备重复激活的错误日志
-				logger.GetLogger().Warn("设备重复激活被阻止",
-					zap.String("auth_code", auth.AuthorizationCode),
-					zap.String("machine_id", bindFile.MachineID),
-					zap.String("hostname", bindFile.Hostname),
-					zap.Uint("existing_license_id", existing.ID),
-					zap.Time("existing_activated_at", existing.ActivatedAt),
-					zap.String("customer_name", auth.CustomerName),
-				)
-				return errors.ErrDuplicateMachine
-			}
-			if err != gorm.ErrRecordNotFound {
-				return errors.WrapError(err, 50001, "检查机器状态失败")
-			}
+			licenseFiles = append(licenseFiles, *licenseFile)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return licenseFiles, nil
+}
 
-			// 生成授权文件（在事务中）
-			licenseFile, license, err := s.generateLicenseFileWithExpiryAndDB(auth, &bindFile, auth.CalculateExpiryDate(), tx)
+// activateLicenseWithDB 在事务中激活单台设备并消耗一个席位；同一授权码下已激活的设备直接重新签发授权文件，不再消耗席位
+func (s *LicenseService) activateLicenseWithDB(tx *gorm.DB, auth *models.Authorization, bindFile *BindFile) (*LicenseFile, bool, error) {
+	// 验证绑定文件
+	if err := s.validateBindFile(bindFile); err != nil {
+		return nil, false, err
+	}
+
+	// 检查机器是否已经激活
+	var existing models.License
+	err := tx.Where("machine_id = ? AND status = ?",
+		bindFile.MachineID, models.LicenseStatusActive).First(&existing).Error
+	if err == nil {
+		// 客户丢失授权文件后重新上传同一设备的绑定文件，按原授权重新签发；试用授权需由管理员转正
+		if existing.AuthorizationID == auth.ID && !existing.IsTrial() {
+			licenseFile, err := s.reissueLicenseFileWithDB(tx, &existing, auth)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
 
-			// 保存到数据库
-			err = tx.Create(license).Error
-			if err != nil {
-				return errors.WrapError(err, 50001, "保存授权记录失败")
+			// 记录新绑定文件中的客户端公钥，之后重新下载的授权文件加密给该公钥
+			if existing.ClientPublicKey != bindFile.ClientPublicKey {
+				err = tx.Model(&existing).Update("client_public_key", bindFile.ClientPublicKey).Error
+				if err != nil {
+					return nil, false, errors.WrapError(err, 50001, "更新客户端公钥失败")
+				}
 			}
 
-			licenseFiles = append(licenseFiles, *licenseFile)
+			logger.GetLogger().Info("设备重复激活，重新签发原授权文件",
+				zap.String("auth_code", auth.AuthorizationCode),
+				zap.String("machine_id", bindFile.MachineID),
+				zap.Uint("license_id", existing.ID),
+			)
+			return licenseFile, true, nil
 		}
 
-		// 在事务中消耗席位
-		err := s.authService.ConsumeSeatsWithDB(tx, auth.ID, len(bindFiles))
-		if err != nil {
-			return err
-		}
+		// 记录设备重复激活的错误日志
+		logger.GetLogger().Warn("设备重复激活被阻止",
+			zap.String("auth_code", auth.AuthorizationCode),
+			zap.String("machine_id", bindFile.MachineID),
+			zap.String("hostname", bindFile.Hostname),
+			zap.Uint("existing_license_id", existing.ID),
+			zap.Time("existing_activated_at", existing.ActivatedAt),
+			zap.String("customer_name", auth.CustomerName),
+		)
+		return nil, false, errors.ErrDuplicateMachine
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, false, errors.WrapError(err, 50001, "检查机器状态失败")
+	}
 
-		return nil
+	// 生成授权文件（在事务中）
+	licenseFile, license, err := s.generateLicenseFileWithExpiryAndDB(auth, bindFile, auth.CalculateExpiryDate(), models.LicenseTypeFull, tx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 保存到数据库
+	err = tx.Create(license).Error
+	if err != nil {
+		return nil, false, errors.WrapError(err, 50001, "保存授权记录失败")
+	}
+
+	// 在事务中逐台消耗席位，台账关联到具体设备
+	err = s.authService.ConsumeSeatsWithDB(tx, auth.ID, 1, SeatChange{
+		Reason:    models.SeatReasonActivate,
+		LicenseID: &license.ID,
+		Remark:    bindFile.Hostname,
 	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return licenseFile, false, nil
+}
+
+// ActivationResult 部分成功模式下单个绑定文件的激活结果
+type ActivationResult struct {
+	Index       int                    `json:"index"` // 文件在本次上传中的序号，从1开始
+	Filename    string                 `json:"filename,omitempty"`
+	Success     bool                   `json:"success"`
+	MachineID   string                 `json:"machine_id,omitempty"`
+	Hostname    string                 `json:"hostname,omitempty"`
+	Reissued    bool                   `json:"reissued,omitempty"` // 设备此前已激活，重新签发原授权且未消耗席位
+	ErrorCode   int                    `json:"error_code,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	LicenseFile *EncryptedFileResponse `json:"-"` // 激活成功时的加密授权文件
+}
+
+// fail 记录激活失败的错误码和原因
+func (r *ActivationResult) fail(err error) {
+	r.Success = false
+	r.ErrorCode, r.Message = activationErrorDetail(err)
+}
+
+// activationErrorDetail 提取激活失败的错误码和原因
+func activationErrorDetail(err error) (int, string) {
+	if appErr, ok := err.(*errors.AppError); ok {
+		return appErr.Code, appErr.Message
+	}
+	return 50000, "激活失败"
+}
 
+// ActivateLicensesPartialEncrypted 部分成功模式的批量激活：有效的绑定文件逐个激活，无效文件单独报告失败原因，不影响其他文件
+func (s *LicenseService) ActivateLicensesPartialEncrypted(authCode string, encryptedBindFiles []string) ([]ActivationResult, error) {
+	// 授权码无效时整批失败
+	auth, err := s.authService.ValidateAuthorizationCode(authCode)
 	if err != nil {
 		return nil, err
 	}
 
-	return licenseFiles, nil
+	results := make([]ActivationResult, len(encryptedBindFiles))
+	for i, encryptedData := range encryptedBindFiles {
+		results[i] = s.activateEncryptedBindFile(auth, encryptedData)
+		results[i].Index = i + 1
+	}
+
+	return results, nil
+}
+
+// activateEncryptedBindFile 在独立事务中激活单个加密绑定文件，失败只回滚该设备
+func (s *LicenseService) activateEncryptedBindFile(auth *models.Authorization, encryptedData string) ActivationResult {
+	var result ActivationResult
+
+	bindFiles, clientAESKeys, err := s.DecryptBindFilesAndExtractAESKeys([]string{encryptedData})
+	if err != nil {
+		result.fail(err)
+		return result
+	}
+	bindFile := bindFiles[0]
+	result.MachineID = bindFile.MachineID
+	result.Hostname = bindFile.Hostname
+
+	var licenseFile *LicenseFile
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		licenseFile, result.Reissued, err = s.activateLicenseWithDB(tx, auth, &bindFile)
+		return err
+	})
+	if err != nil {
+		result.fail(err)
+		return result
+	}
+
+	// 设备已激活，加密失败时客户仍可在控制台重新下载授权文件
+	encryptedFile, err := s.EncryptLicenseFileForDevice(*licenseFile, bindFile.ClientPublicKey, clientAESKeys[0])
+	if err != nil {
+		result.fail(err)
+		return result
+	}
+
+	result.Success = true
+	result.LicenseFile = encryptedFile
+	return result
 }
 
 // TransferLicense 授权转移
@@ -522,6 +711,27 @@ func (s *LicenseService) TransferLicense(authCode string, unbindFile UnbindFile,
 			return errors.NewAppError(41004, "解绑文件不属于当前授权码")
 		}
 
+		// 试用授权绑定在申请试用的设备上，不能转移
+		if oldLicense.IsTrial() {
+			return errors.ErrTrialNotAllowed
+		}
+
+		// 检查授权码的转移策略
+		allowance, err := s.transferAllowanceWithDB(tx, auth, oldLicense, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := allowance.CanTransfer(); err != nil {
+			logger.GetLogger().Warn("设备转移超出授权码的转移限制",
+				zap.String("auth_code", auth.AuthorizationCode),
+				zap.Uint("license_id", oldLicense.ID),
+				zap.String("machine_id", oldLicense.MachineID),
+				zap.Int("transfers_in_period", allowance.TransfersInPeriod),
+				zap.Error(err),
+			)
+			return err
+		}
+
 		// 验证新绑定文件
 		if err := s.validateBindFile(&bindFile); err != nil {
 			return err
@@ -549,22 +759,24 @@ func (s *LicenseService) TransferLicense(authCode string, unbindFile UnbindFile,
 		}
 
 		// 标记旧授权为解绑状态
-		oldLicense.Unbind(false)
-		err = tx.Save(oldLicense).Error
-		if err != nil {
-			return errors.WrapError(err, 50001, "更新旧授权状态失败")
+		if err := s.unbindLicenseWithDB(tx, oldLicense, false, unbindDetailsFromMetadata(unbindFile.UnbindMetadata)); err != nil {
+			return err
 		}
 
 		// 生成新授权文件（继承旧授权的到期时间）
-		licenseFile, license, err := s.generateLicenseFileWithExpiryAndDB(auth, &bindFile, oldLicense.ExpiresAt, tx)
+		licenseFile, license, err := s.issueSuccessorLicenseWithDB(tx, auth, oldLicense, &bindFile, models.TransferTypeCustomer)
 		if err != nil {
 			return err
 		}
 
-		// 保存新授权记录
-		err = tx.Create(license).Error
+		// 转移不改变席位数，仍记录到台账
+		err = s.authService.RecordSeatChangeWithDB(tx, auth.ID, SeatChange{
+			Reason:    models.SeatReasonTransfer,
+			LicenseID: &license.ID,
+			Remark:    fmt.Sprintf("由授权 %d 转移", oldLicense.ID),
+		})
 		if err != nil {
-			return errors.WrapError(err, 50001, "保存新授权记录失败")
+			return err
 		}
 
 		newLicenseFile = licenseFile
@@ -578,91 +790,579 @@ func (s *LicenseService) TransferLicense(authCode string, unbindFile UnbindFile,
 	return newLicenseFile, nil
 }
 
-// ForceUnbindLicense 管理员强制解绑设备
-func (s *LicenseService) ForceUnbindLicense(licenseID uint, reason string) error {
-	// 先获取许可证信息（不在事务中）
-	var license models.License
-	err := s.db.First(&license, licenseID).Error
+// issueSuccessorLicenseWithDB 为已解绑的旧授权在新设备上签发接续授权，继承到期时间并记录转移链路
+// transferType区分客户转移和管理员替换，只有客户转移计入转移额度
+func (s *LicenseService) issueSuccessorLicenseWithDB(tx *gorm.DB, auth *models.Authorization, oldLicense *models.License, bindFile *BindFile, transferType string) (*LicenseFile, *models.License, error) {
+	licenseFile, license, err := s.generateLicenseFileWithExpiryAndDB(auth, bindFile, oldLicense.ExpiresAt, models.LicenseTypeFull, tx)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return errors.ErrLicenseNotFound
-		}
-		return errors.WrapError(err, 50001, "获取授权记录失败")
-	}
-
-	if !license.CanUnbind() {
-		return errors.NewAppError(41004, "授权状态不允许解绑")
+		return nil, nil, err
 	}
 
-	// 使用事务更新许可证状态
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		// 重新获取许可证以确保数据一致性
-		var currentLicense models.License
-		err := tx.First(&currentLicense, licenseID).Error
-		if err != nil {
-			return errors.WrapError(err, 50001, "获取授权记录失败")
-		}
+	// 新授权接续旧授权，保留首次激活时间
+	originalActivatedAt := oldLicense.FirstActivatedAt()
+	license.PredecessorID = &oldLicense.ID
+	license.OriginalActivatedAt = &originalActivatedAt
+	license.TransferType = transferType
 
-		if !currentLicense.CanUnbind() {
-			return errors.NewAppError(41004, "授权状态不允许解绑")
-		}
+	// 保存新授权记录
+	err = tx.Create(license).Error
+	if err != nil {
+		return nil, nil, errors.WrapError(err, 50001, "保存新授权记录失败")
+	}
 
-		// 标记为强制解绑
-		currentLicense.Unbind(true)
-		return tx.Save(&currentLicense).Error
-	})
+	err = tx.Model(&models.License{}).Where("id = ?", oldLicense.ID).
+		Update("successor_id", license.ID).Error
 	if err != nil {
-		return errors.WrapError(err, 50001, "更新授权状态失败")
+		return nil, nil, errors.WrapError(err, 50001, "更新旧授权转移记录失败")
 	}
 
-	// 在事务外释放席位，避免长时间持有锁
-	return s.authService.ReleaseSeats(license.AuthorizationID, 1)
+	return licenseFile, license, nil
 }
 
-// GetLicensesByAuth 获取授权码下的所有设备
-func (s *LicenseService) GetLicensesByAuth(authCode string) ([]models.License, error) {
-	auth, err := s.authService.GetAuthorizationByCode(authCode)
+// ReplaceDeviceEncrypted 管理员使用加密的绑定文件替换故障设备
+func (s *LicenseService) ReplaceDeviceEncrypted(licenseID uint, encryptedBindFile string, reason string) (*EncryptedFileResponse, *models.License, error) {
+	bindFiles, clientAESKeys, err := s.DecryptBindFilesAndExtractAESKeys([]string{encryptedBindFile})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var licenses []models.License
-	err = s.db.Where("authorization_id = ?", auth.ID).
-		Order("created_at DESC").Find(&licenses).Error
+	licenseFile, license, err := s.ReplaceDevice(licenseID, bindFiles[0], reason)
 	if err != nil {
-		return nil, errors.WrapError(err, 50001, "获取授权设备列表失败")
+		return nil, nil, err
 	}
 
-	return licenses, nil
-}
-
-// validateBindFile 验证绑定文件
-func (s *LicenseService) validateBindFile(bindFile *BindFile) error {
-	// 验证机器ID格式
-	if !utils.ValidateMachineID(bindFile.MachineID) {
-		return errors.ErrInvalidBindFile
+	encryptedFile, err := s.EncryptLicenseFileForDevice(*licenseFile, bindFiles[0].ClientPublicKey, clientAESKeys[0])
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 验证主机名
-	if bindFile.Hostname == "" {
-		return errors.NewAppError(41003, "主机名不能为空")
-	}
+	return encryptedFile, license, nil
+}
 
-	// 验证请求时间（不能太旧）
-	if time.Since(bindFile.RequestTime) > 24*time.Hour {
-		return errors.NewAppError(41003, "绑定请求已过期")
+// ReplaceDevice 管理员替换无法提供解绑文件的故障设备：强制解绑旧授权，并在新设备上签发继承到期时间的授权
+func (s *LicenseService) ReplaceDevice(licenseID uint, bindFile BindFile, reason string) (*LicenseFile, *models.License, error) {
+	if err := s.validateBindFile(&bindFile); err != nil {
+		return nil, nil, err
 	}
 
-	return nil
-}
+	var (
+		newLicenseFile *LicenseFile
+		newLicense     *models.License
+	)
 
-// validateUnbindFile 验证解绑文件（使用默认数据库连接）
-func (s *LicenseService) validateUnbindFile(unbindFile *UnbindFile) (*models.License, error) {
-	return s.validateUnbindFileWithDB(s.db, unbindFile)
-}
+	// 解绑旧设备、签发新授权和审计日志在同一事务中完成
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var oldLicense models.License
+		err := tx.First(&oldLicense, licenseID).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrLicenseNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权记录失败")
+		}
 
-// validateUnbindFileWithDB 验证解绑文件（可指定数据库连接）
-func (s *LicenseService) validateUnbindFileWithDB(db *gorm.DB, unbindFile *UnbindFile) (*models.License, error) {
+		if !oldLicense.CanUnbind() {
+			return errors.NewAppError(41004, "授权状态不允许解绑")
+		}
+
+		// 试用授权绑定在申请试用的设备上，不能替换
+		if oldLicense.IsTrial() {
+			return errors.ErrTrialNotAllowed
+		}
+
+		var auth models.Authorization
+		if err := tx.First(&auth, oldLicense.AuthorizationID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrAuthCodeNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权码失败")
+		}
+		if !auth.IsActive() {
+			return errors.ErrAuthCodeDisabled
+		}
+
+		// 检查新机器是否已经激活
+		var existing models.License
+		err = tx.Where("machine_id = ? AND status = ?",
+			bindFile.MachineID, models.LicenseStatusActive).First(&existing).Error
+		if err == nil {
+			return errors.ErrDuplicateMachine
+		}
+		if err != gorm.ErrRecordNotFound {
+			return errors.WrapError(err, 50001, "检查新机器状态失败")
+		}
+
+		if err := s.unbindLicenseWithDB(tx, &oldLicense, true, UnbindDetails{Reason: reason}); err != nil {
+			return err
+		}
+
+		licenseFile, license, err := s.issueSuccessorLicenseWithDB(tx, &auth, &oldLicense, &bindFile, models.TransferTypeReplace)
+		if err != nil {
+			return err
+		}
+
+		// 替换不改变席位数，仍记录到台账
+		err = s.authService.RecordSeatChangeWithDB(tx, auth.ID, SeatChange{
+			Reason:    models.SeatReasonReplace,
+			LicenseID: &license.ID,
+			Remark:    fmt.Sprintf("替换授权 %d", oldLicense.ID),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := s.recordReplaceAuditWithDB(tx, &oldLicense, license, reason); err != nil {
+			return err
+		}
+
+		newLicenseFile = licenseFile
+		newLicense = license
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.GetLogger().Info("管理员替换设备",
+		zap.Uint("old_license_id", licenseID),
+		zap.Uint("new_license_id", newLicense.ID),
+		zap.String("machine_id", newLicense.MachineID),
+		zap.String("hostname", newLicense.Hostname))
+
+	return newLicenseFile, newLicense, nil
+}
+
+// recordReplaceAuditWithDB 在替换设备的事务中写入管理员操作日志
+func (s *LicenseService) recordReplaceAuditWithDB(tx *gorm.DB, oldLicense, newLicense *models.License, reason string) error {
+	details, err := json.Marshal(map[string]interface{}{
+		"authorization_id": oldLicense.AuthorizationID,
+		"old_license_id":   oldLicense.ID,
+		"old_machine_id":   oldLicense.MachineID,
+		"old_hostname":     oldLicense.Hostname,
+		"new_license_id":   newLicense.ID,
+		"new_machine_id":   newLicense.MachineID,
+		"new_hostname":     newLicense.Hostname,
+		"expires_at":       newLicense.ExpiresAt,
+		"reason":           reason,
+	})
+	if err != nil {
+		return errors.WrapError(err, 50002, "序列化操作详情失败")
+	}
+
+	actor := s.authService.actor
+	var adminID *uint
+	if actor.Type == models.SeatActorAdmin {
+		adminID = actor.ID
+	}
+
+	log := &models.AdminLog{
+		AdminID:    adminID,
+		Action:     models.LogActionReplaceDevice,
+		TargetType: models.LogTargetLicense,
+		TargetID:   fmt.Sprintf("%d", oldLicense.ID),
+		Details:    string(details),
+		IPAddress:  actor.IPAddress,
+	}
+	if err := tx.Create(log).Error; err != nil {
+		return errors.WrapError(err, 50001, "记录操作日志失败")
+	}
+
+	return nil
+}
+
+// DeactivateLicenseEncrypted 客户停用设备（使用加密解绑文件）
+func (s *LicenseService) DeactivateLicenseEncrypted(authCode string, encryptedUnbindFile string) (*models.License, error) {
+	unbindFile, err := s.DecryptUnbindFile(encryptedUnbindFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.DeactivateLicense(authCode, *unbindFile)
+}
+
+// DeactivateLicense 客户凭解绑文件停用设备并归还席位，无需同时绑定新设备
+func (s *LicenseService) DeactivateLicense(authCode string, unbindFile UnbindFile) (*models.License, error) {
+	// 验证授权码
+	auth, err := s.authService.ValidateAuthorizationCode(authCode)
+	if err != nil {
+		return nil, err
+	}
+
+	var license *models.License
+
+	// 解绑和释放席位在同一事务中完成
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		oldLicense, err := s.validateUnbindFileWithDB(tx, &unbindFile)
+		if err != nil {
+			return err
+		}
+
+		// 检查解绑的授权是否属于当前授权码
+		if oldLicense.AuthorizationID != auth.ID {
+			return errors.NewAppError(41004, "解绑文件不属于当前授权码")
+		}
+
+		if err := s.unbindLicenseWithDB(tx, oldLicense, false, unbindDetailsFromMetadata(unbindFile.UnbindMetadata)); err != nil {
+			return err
+		}
+
+		// 免席位的试用授权无需释放席位
+		if oldLicense.ConsumesSeat() {
+			err := s.authService.ReleaseSeatsWithDB(tx, auth.ID, 1, SeatChange{
+				Reason:    models.SeatReasonDeactivate,
+				LicenseID: &oldLicense.ID,
+				Remark:    unbindFile.UnbindMetadata.UnbindReason,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		license = oldLicense
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.GetLogger().Info("客户停用设备",
+		zap.String("auth_code", auth.AuthorizationCode),
+		zap.Uint("license_id", license.ID),
+		zap.String("machine_id", license.MachineID),
+		zap.String("hostname", license.Hostname))
+
+	return license, nil
+}
+
+// ForceUnbindLicense 管理员强制解绑设备
+func (s *LicenseService) ForceUnbindLicense(licenseID uint, reason string) error {
+	// 解绑和释放席位在同一事务中完成，避免席位计数与设备状态不一致
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var license models.License
+		err := tx.First(&license, licenseID).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrLicenseNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权记录失败")
+		}
+
+		if !license.CanUnbind() {
+			return errors.NewAppError(41004, "授权状态不允许解绑")
+		}
+
+		// 标记为强制解绑
+		if err := s.unbindLicenseWithDB(tx, &license, true, UnbindDetails{Reason: reason}); err != nil {
+			return err
+		}
+
+		// 免席位的试用授权无需释放席位
+		if !license.ConsumesSeat() {
+			return nil
+		}
+
+		return s.authService.ReleaseSeatsWithDB(tx, license.AuthorizationID, 1, SeatChange{
+			Reason:    models.SeatReasonForceUnbind,
+			LicenseID: &license.ID,
+			Remark:    reason,
+		})
+	})
+}
+
+// UnbindDetails 解绑时随授权记录保存的信息
+type UnbindDetails struct {
+	Reason        string
+	Hostname      string
+	ClientVersion string
+	RequestedAt   *time.Time
+}
+
+// unbindDetailsFromMetadata 从解绑文件的元数据构造解绑信息
+func unbindDetailsFromMetadata(metadata UnbindMetadata) UnbindDetails {
+	details := UnbindDetails{
+		Reason:        metadata.UnbindReason,
+		Hostname:      metadata.Hostname,
+		ClientVersion: metadata.ClientVersion,
+	}
+	if !metadata.UnbindTime.IsZero() {
+		requestedAt := metadata.UnbindTime
+		details.RequestedAt = &requestedAt
+	}
+	return details
+}
+
+// unbindLicenseWithDB 将有效授权标记为解绑并递增吊销列表版本号，使用条件更新保证同一授权只会被解绑一次，避免重复释放席位
+func (s *LicenseService) unbindLicenseWithDB(db *gorm.DB, license *models.License, isForced bool, details UnbindDetails) error {
+	license.Unbind(isForced)
+
+	actor := s.authService.actor
+	license.UnbindReason = details.Reason
+	license.UnbindHostname = details.Hostname
+	license.UnbindClientVersion = details.ClientVersion
+	license.UnbindRequestedAt = details.RequestedAt
+	license.UnboundByType = actor.Type
+	license.UnboundByID = actor.ID
+	license.UnboundBy = actor.Name
+
+	result := db.Model(&models.License{}).
+		Where("id = ? AND status = ?", license.ID, models.LicenseStatusActive).
+		Updates(map[string]interface{}{
+			"status":                license.Status,
+			"unbound_at":            license.UnboundAt,
+			"unbind_reason":         license.UnbindReason,
+			"unbind_hostname":       license.UnbindHostname,
+			"unbind_client_version": license.UnbindClientVersion,
+			"unbind_requested_at":   license.UnbindRequestedAt,
+			"unbound_by_type":       license.UnboundByType,
+			"unbound_by_id":         license.UnboundByID,
+			"unbound_by":            license.UnboundBy,
+		})
+	if result.Error != nil {
+		return errors.WrapError(result.Error, 50001, "更新授权状态失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewAppError(41004, "授权状态不允许解绑")
+	}
+
+	return bumpRevocationVersionWithDB(db)
+}
+
+// LicenseLineage 设备转移链路，按转移先后排列
+type LicenseLineage struct {
+	RootLicenseID       uint             `json:"root_license_id"`
+	OriginalActivatedAt time.Time        `json:"original_activated_at"`
+	Licenses            []models.License `json:"licenses"`
+}
+
+// GetLicenseLineage 获取授权所在的完整转移链路
+func (s *LicenseService) GetLicenseLineage(licenseID uint) (*LicenseLineage, error) {
+	var license models.License
+	err := s.db.First(&license, licenseID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrLicenseNotFound
+		}
+		return nil, errors.WrapError(err, 50001, "获取授权记录失败")
+	}
+
+	return s.buildLicenseLineage(&license)
+}
+
+// GetLicenseLineageByMachineID 获取机器最近一次授权所在的转移链路
+func (s *LicenseService) GetLicenseLineageByMachineID(machineID string) (*LicenseLineage, error) {
+	var license models.License
+	err := s.db.Where("machine_id = ?", machineID).Order("id DESC").First(&license).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrLicenseNotFound
+		}
+		return nil, errors.WrapError(err, 50001, "获取授权记录失败")
+	}
+
+	return s.buildLicenseLineage(&license)
+}
+
+// buildLicenseLineage 从指定授权向前追溯到首台设备，再向后依次查找接续的授权
+func (s *LicenseService) buildLicenseLineage(license *models.License) (*LicenseLineage, error) {
+	visited := map[uint]bool{license.ID: true}
+
+	root := *license
+	for root.PredecessorID != nil && !visited[*root.PredecessorID] {
+		var predecessor models.License
+		if err := s.db.First(&predecessor, *root.PredecessorID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				break
+			}
+			return nil, errors.WrapError(err, 50001, "获取前序授权失败")
+		}
+		visited[predecessor.ID] = true
+		root = predecessor
+	}
+
+	chain := []models.License{root}
+	visited = map[uint]bool{root.ID: true}
+	current := root
+	for current.SuccessorID != nil && !visited[*current.SuccessorID] {
+		var successor models.License
+		if err := s.db.First(&successor, *current.SuccessorID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				break
+			}
+			return nil, errors.WrapError(err, 50001, "获取后续授权失败")
+		}
+		visited[successor.ID] = true
+		chain = append(chain, successor)
+		current = successor
+	}
+
+	return &LicenseLineage{
+		RootLicenseID:       root.ID,
+		OriginalActivatedAt: root.FirstActivatedAt(),
+		Licenses:            chain,
+	}, nil
+}
+
+// TransferAllowance 设备剩余的转移额度
+type TransferAllowance struct {
+	TransfersInPeriod int        `json:"transfers_in_period"` // 该席位在当前统计周期内已转移次数
+	RemainingInPeriod *int       `json:"remaining_in_period"` // 当前统计周期内剩余次数，为空表示不限
+	RemainingTotal    *int       `json:"remaining_total"`     // 授权码累计剩余次数，为空表示不限
+	NextTransferAt    *time.Time `json:"next_transfer_at"`    // 冷却期结束时间，为空表示可立即转移
+}
+
+// CanTransfer 检查当前是否允许转移
+func (a *TransferAllowance) CanTransfer() error {
+	if a.RemainingTotal != nil && *a.RemainingTotal <= 0 {
+		return errors.ErrTransferLimited
+	}
+	if a.RemainingInPeriod != nil && *a.RemainingInPeriod <= 0 {
+		return errors.ErrTransferLimited
+	}
+	if a.NextTransferAt != nil {
+		return errors.ErrTransferCooldown
+	}
+	return nil
+}
+
+// GetTransferAllowance 按授权码的转移策略计算设备剩余的转移额度
+func (s *LicenseService) GetTransferAllowance(auth *models.Authorization, license *models.License) (*TransferAllowance, error) {
+	return s.transferAllowanceWithDB(s.db, auth, license, time.Now())
+}
+
+// transferAllowanceWithDB 计算转移额度，管理员替换故障设备产生的授权不计入
+func (s *LicenseService) transferAllowanceWithDB(db *gorm.DB, auth *models.Authorization, license *models.License, now time.Time) (*TransferAllowance, error) {
+	allowance := &TransferAllowance{}
+
+	var transferTimes []time.Time
+	if auth.MaxTransfersPerPeriod > 0 || auth.TransferCooldownDays > 0 {
+		var err error
+		transferTimes, err = s.seatTransferTimesWithDB(db, license)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if auth.MaxTransfersPerPeriod > 0 {
+		since := now.Add(-auth.TransferPeriod())
+		count := 0
+		for _, transferredAt := range transferTimes {
+			if !transferredAt.Before(since) {
+				count++
+			}
+		}
+		remaining := auth.MaxTransfersPerPeriod - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		allowance.TransfersInPeriod = count
+		allowance.RemainingInPeriod = &remaining
+	}
+
+	// 冷却期从该席位上一次客户转移算起，首次转移不受限制
+	if auth.TransferCooldownDays > 0 && len(transferTimes) > 0 {
+		nextTransferAt := transferTimes[0].AddDate(0, 0, auth.TransferCooldownDays)
+		if nextTransferAt.After(now) {
+			allowance.NextTransferAt = &nextTransferAt
+		}
+	}
+
+	if auth.MaxTotalTransfers > 0 {
+		var total int64
+		err := db.Model(&models.License{}).
+			Where("authorization_id = ? AND predecessor_id IS NOT NULL AND transfer_type = ?", auth.ID, models.TransferTypeCustomer).
+			Count(&total).Error
+		if err != nil {
+			return nil, errors.WrapError(err, 50001, "统计转移次数失败")
+		}
+		remaining := auth.MaxTotalTransfers - int(total)
+		if remaining < 0 {
+			remaining = 0
+		}
+		allowance.RemainingTotal = &remaining
+	}
+
+	return allowance, nil
+}
+
+// seatTransferTimesWithDB 沿转移链路向前收集席位每次客户转移的时间（由近及远），管理员替换不计入
+func (s *LicenseService) seatTransferTimesWithDB(db *gorm.DB, license *models.License) ([]time.Time, error) {
+	var times []time.Time
+	visited := map[uint]bool{license.ID: true}
+
+	current := *license
+	for current.PredecessorID != nil {
+		if current.IsCustomerTransfer() {
+			times = append(times, current.ActivatedAt)
+		}
+		if visited[*current.PredecessorID] {
+			break
+		}
+
+		var predecessor models.License
+		if err := db.First(&predecessor, *current.PredecessorID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				break
+			}
+			return nil, errors.WrapError(err, 50001, "获取前序授权失败")
+		}
+		visited[predecessor.ID] = true
+		current = predecessor
+	}
+
+	return times, nil
+}
+
+// GetLicensesByAuth 获取授权码下的所有设备
+func (s *LicenseService) GetLicensesByAuth(authCode string) ([]models.License, error) {
+	auth, err := s.authService.GetAuthorizationByCode(authCode)
+	if err != nil {
+		return nil, err
+	}
+
+	var licenses []models.License
+	err = s.db.Where("authorization_id = ?", auth.ID).
+		Order("created_at DESC").Find(&licenses).Error
+	if err != nil {
+		return nil, errors.WrapError(err, 50001, "获取授权设备列表失败")
+	}
+
+	return licenses, nil
+}
+
+// validateBindFile 验证绑定文件
+func (s *LicenseService) validateBindFile(bindFile *BindFile) error {
+	// 验证机器ID格式
+	if !utils.ValidateMachineID(bindFile.MachineID) {
+		return errors.ErrInvalidBindFile
+	}
+
+	// 验证主机名
+	if bindFile.Hostname == "" {
+		return errors.NewAppError(41003, "主机名不能为空")
+	}
+
+	// 验证请求时间（不能太旧）
+	if time.Since(bindFile.RequestTime) > 24*time.Hour {
+		return errors.NewAppError(41003, "绑定请求已过期")
+	}
+
+	// v2绑定文件中的客户端公钥必须有效，否则设备无法解密授权文件
+	if bindFile.ClientPublicKey != "" {
+		if _, err := crypto.ParseClientPublicKey(bindFile.ClientPublicKey); err != nil {
+			return errors.WrapError(err, 41003, "绑定文件中的客户端公钥无效")
+		}
+	}
+
+	return nil
+}
+
+// validateUnbindFile 验证解绑文件（使用默认数据库连接）
+func (s *LicenseService) validateUnbindFile(unbindFile *UnbindFile) (*models.License, error) {
+	return s.validateUnbindFileWithDB(s.db, unbindFile)
+}
+
+// validateUnbindFileWithDB 验证解绑文件（可指定数据库连接）
+func (s *LicenseService) validateUnbindFileWithDB(db *gorm.DB, unbindFile *UnbindFile) (*models.License, error) {
 	// 根据license_key查找授权记录
 	var license models.License
 	err := db.Where("license_key = ? AND status = ?",
@@ -675,249 +1375,744 @@ func (s *LicenseService) validateUnbindFileWithDB(db *gorm.DB, unbindFile *Unbin
 		return nil, errors.WrapError(err, 50001, "查找授权记录失败")
 	}
 
-	// 验证机器ID是否匹配
-	if license.MachineID != unbindFile.MachineID {
-		return nil, errors.NewAppError(41004, "机器ID不匹配")
+	// 验证机器ID是否匹配
+	if license.MachineID != unbindFile.MachineID {
+		return nil, errors.NewAppError(41004, "机器ID不匹配")
+	}
+
+	// 构造需要验证签名的数据
+	signData := client.UnbindProofPayload(unbindFile.LicenseKey, unbindFile.MachineID,
+		unbindFile.UnbindMetadata.UnbindTime, unbindFile.UnbindMetadata.Hostname)
+
+	// 使用一次性解绑公钥验证，按授权记录的密钥类型选择算法，旧授权均为RSA
+	err = crypto.VerifyUnbindProof(license.UnbindKeyType, license.UnbindPublicKey, signData, unbindFile.UnbindProof)
+	if err != nil {
+		return nil, errors.ErrInvalidSignature
+	}
+
+	return &license, nil
+}
+
+// generateLicenseFile 生成授权文件
+func (s *LicenseService) generateLicenseFile(auth *models.Authorization, bindFile *BindFile) (*LicenseFile, *models.License, error) {
+	expiresAt := auth.CalculateExpiryDate()
+	return s.generateLicenseFileWithExpiry(auth, bindFile, expiresAt)
+}
+
+// generateLicenseFileWithExpiry 生成带指定到期时间的授权文件
+func (s *LicenseService) generateLicenseFileWithExpiry(auth *models.Authorization, bindFile *BindFile, expiresAt time.Time) (*LicenseFile, *models.License, error) {
+	return s.generateLicenseFileWithExpiryAndDB(auth, bindFile, expiresAt, models.LicenseTypeFull, nil)
+}
+
+// generateLicenseFileWithExpiryAndDB 生成带指定到期时间的授权文件（支持事务）
+func (s *LicenseService) generateLicenseFileWithExpiryAndDB(auth *models.Authorization, bindFile *BindFile, expiresAt time.Time, licenseType string, db *gorm.DB) (*LicenseFile, *models.License, error) {
+	// 生成一次性解绑密钥对
+	unbindKeyPair, err := generateUnbindKeyPair()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 生成授权记录的唯一标识
+	now := time.Now()
+	licenseKey := s.generateLicenseKey(bindFile.MachineID, now)
+
+	// 创建授权数据
+	licenseData := LicenseData{
+		LicenseKey:       licenseKey,
+		MachineID:        bindFile.MachineID,
+		Hostname:         bindFile.Hostname,
+		IssuedAt:         now,
+		SignedAt:         &now,
+		ExpiresAt:        expiresAt,
+		LicenseType:      licenseType,
+		UnbindPrivateKey: unbindKeyPair.PrivateKeyPEM,
+	}
+	applyEntitlements(&licenseData, auth)
+
+	// 签名授权数据
+	licenseDataBytes, err := json.Marshal(licenseData)
+	if err != nil {
+		return nil, nil, errors.WrapError(err, 50002, "序列化授权数据失败")
+	}
+
+	// 如果提供了数据库连接（在事务中），使用该连接的RSA服务
+	var signature *DataSignature
+	if db != nil {
+		rsaServiceWithDB := s.rsaService.WithDB(db)
+		signature, err = rsaServiceWithDB.Sign(licenseDataBytes)
+	} else {
+		signature, err = s.rsaService.Sign(licenseDataBytes)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 创建授权文件
+	licenseFile := &LicenseFile{
+		LicenseData: licenseData,
+		Signature:   signature.Signature,
+		KeyID:       signature.KeyID,
+		Algorithm:   signature.Algorithm,
+		Certificate: signature.Certificate,
+	}
+
+	// 创建数据库记录
+	license := &models.License{
+		AuthorizationID:  auth.ID,
+		LicenseKey:       licenseKey,
+		MachineID:        bindFile.MachineID,
+		Hostname:         bindFile.Hostname,
+		ClientPublicKey:  bindFile.ClientPublicKey,
+		UnbindKeyType:    unbindKeyPair.Type,
+		UnbindPublicKey:  unbindKeyPair.PublicKeyPEM,
+		UnbindPrivateKey: unbindKeyPair.PrivateKeyPEM, // 同时保存私钥
+		IssuedAt:         now,
+		ExpiresAt:        expiresAt,
+		Status:           models.LicenseStatusActive,
+		LicenseType:      licenseType,
+		ActivatedAt:      now,
+	}
+
+	return licenseFile, license, nil
+}
+
+// generateUnbindKeyPair 按配置的密钥类型生成一次性解绑密钥对，未配置时使用兼容旧版客户端的RSA
+func generateUnbindKeyPair() (*crypto.UnbindKeyPair, error) {
+	keyType := crypto.UnbindKeyTypeRSA
+	if config.AppConfig != nil && config.AppConfig.System.UnbindKeyType != "" {
+		keyType = config.AppConfig.System.UnbindKeyType
+	}
+
+	keyPair, err := crypto.GenerateUnbindKeyPair(keyType)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "生成解绑密钥对失败")
+	}
+
+	return keyPair, nil
+}
+
+// applyEntitlements 将授权码当前的功能权益写入授权数据
+func applyEntitlements(licenseData *LicenseData, auth *models.Authorization) {
+	licenseData.Edition = auth.Edition
+	licenseData.Features = auth.Features
+	licenseData.Limits = auth.Limits
+}
+
+// generateLicenseKey 生成授权记录的唯一标识
+func (s *LicenseService) generateLicenseKey(machineID string, issuedAt time.Time) string {
+	data := fmt.Sprintf("%s:%s:%s", machineID, issuedAt.Format(time.RFC3339), uuid.New().String())
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+// RegenerateLicenseFile 根据数据库记录重新生成license文件
+func (s *LicenseService) RegenerateLicenseFile(licenseID uint, userID interface{}) ([]byte, string, error) {
+	// 查找license记录
+	var license models.License
+	err := s.db.Preload("Authorization").First(&license, licenseID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, "", errors.ErrLicenseNotFound
+		}
+		return nil, "", errors.WrapError(err, 50001, "获取授权记录失败")
+	}
+
+	// 验证用户权限（简单验证，实际可根据需要扩展）
+	// 这里假设任何已认证用户都可以下载自己有权限的license
+	// 更严格的权限控制可以在这里实现
+	if userID == nil {
+		return nil, "", errors.NewAppError(40100, "用户未认证")
+	}
+
+	licenseFile, err := s.reissueLicenseFileWithDB(s.db, &license, &license.Authorization)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 加密给设备：记录了客户端公钥时使用v2信封，否则使用基于机器ID的客户端AES密钥
+	encryptedLicenseFile, err := s.EncryptLicenseFileForDevice(*licenseFile, license.ClientPublicKey, crypto.GenerateClientAESKey(license.MachineID))
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 生成文件名
+	filename := fmt.Sprintf("%s.license", license.Hostname)
+	if filename == ".license" {
+		filename = fmt.Sprintf("license_%d.license", licenseID)
+	}
+
+	return []byte(encryptedLicenseFile.EncryptedContent), filename, nil
+}
+
+// TrialLicenseOptions 试用授权签发参数
+type TrialLicenseOptions struct {
+	DurationDays int  // 试用天数，0表示使用配置的默认天数
+	ConsumeSeat  bool // 是否占用授权码席位
+}
+
+// reissueLicenseFileWithDB 使用授权记录中保存的解绑密钥对重新签发授权文件，原license生成的解绑文件仍然有效
+func (s *LicenseService) reissueLicenseFileWithDB(db *gorm.DB, license *models.License, auth *models.Authorization) (*LicenseFile, error) {
+	// 检查license状态
+	if license.Status != models.LicenseStatusActive {
+		return nil, errors.NewAppError(41005, "授权已失效，无法下载")
+	}
+
+	// 检查是否过期
+	if license.IsExpired() {
+		return nil, errors.NewAppError(41006, "授权已过期，无法下载")
+	}
+
+	var unbindKeyPair *crypto.UnbindKeyPair
+
+	// 检查数据库中是否有原始私钥
+	if license.UnbindPrivateKey != "" {
+		// 使用原始私钥
+		unbindKeyPair = &crypto.UnbindKeyPair{
+			Type:          license.UnbindKeyType,
+			PrivateKeyPEM: license.UnbindPrivateKey,
+			PublicKeyPEM:  license.UnbindPublicKey,
+		}
+
+		logger.GetLogger().Info("使用原始解绑密钥重新生成license文件",
+			zap.Uint("license_id", license.ID),
+			zap.String("machine_id", license.MachineID),
+			zap.String("hostname", license.Hostname))
+	} else {
+		// 兼容旧数据：如果数据库中没有私钥，重新生成（会导致解绑文件失效）
+		var err error
+		unbindKeyPair, err = generateUnbindKeyPair()
+		if err != nil {
+			return nil, err
+		}
+
+		logger.GetLogger().Warn("数据库中无原始私钥，重新生成将导致解绑文件失效",
+			zap.Uint("license_id", license.ID),
+			zap.String("machine_id", license.MachineID),
+			zap.String("hostname", license.Hostname))
+	}
+
+	// 兼容旧数据：未记录授权类型的均为正式授权
+	licenseType := license.LicenseType
+	if licenseType == "" {
+		licenseType = models.LicenseTypeFull
+	}
+
+	// 创建license数据：签发时间保持不变，签名时间为本次签名时间，轮换后的签名密钥证书据此校验
+	signedAt := time.Now()
+	licenseData := LicenseData{
+		LicenseKey:       license.LicenseKey,
+		MachineID:        license.MachineID,
+		Hostname:         license.Hostname,
+		IssuedAt:         license.IssuedAt,
+		SignedAt:         &signedAt,
+		ExpiresAt:        license.ExpiresAt,
+		LicenseType:      licenseType,
+		UnbindPrivateKey: unbindKeyPair.PrivateKeyPEM,
+	}
+	applyEntitlements(&licenseData, auth)
+
+	// 签名license数据
+	licenseDataBytes, err := json.Marshal(licenseData)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "序列化授权数据失败")
+	}
+
+	signature, err := s.rsaService.WithDB(db).Sign(licenseDataBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 如果使用了新生成的密钥对，需要更新数据库
+	if license.UnbindPrivateKey == "" {
+		// 更新数据库中的解绑密钥对（仅当原来没有私钥时）
+		err = db.Model(license).Updates(map[string]interface{}{
+			"unbind_key_type":    unbindKeyPair.Type,
+			"unbind_public_key":  unbindKeyPair.PublicKeyPEM,
+			"unbind_private_key": unbindKeyPair.PrivateKeyPEM,
+		}).Error
+		if err != nil {
+			logger.GetLogger().Warn("更新解绑密钥对失败",
+				zap.Uint("license_id", license.ID),
+				zap.Error(err))
+			// 不影响文件下载，只记录警告
+		}
+	}
+
+	return &LicenseFile{
+		LicenseData: licenseData,
+		Signature:   signature.Signature,
+		KeyID:       signature.KeyID,
+		Algorithm:   signature.Algorithm,
+		Certificate: signature.Certificate,
+	}, nil
+}
+
+// IssueTrialLicenseEncrypted 管理员为授权码签发试用授权（使用加密绑定文件）
+func (s *LicenseService) IssueTrialLicenseEncrypted(authID uint, encryptedBindFile string, opts TrialLicenseOptions) (*EncryptedFileResponse, *models.License, error) {
+	bindFiles, clientAESKeys, err := s.DecryptBindFilesAndExtractAESKeys([]string{encryptedBindFile})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	licenseFile, license, err := s.IssueTrialLicense(authID, bindFiles[0], opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encryptedFile, err := s.EncryptLicenseFileForDevice(*licenseFile, bindFiles[0].ClientPublicKey, clientAESKeys[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encryptedFile, license, nil
+}
+
+// IssueTrialLicense 管理员为授权码签发试用授权，每台设备只能试用一次
+func (s *LicenseService) IssueTrialLicense(authID uint, bindFile BindFile, opts TrialLicenseOptions) (*LicenseFile, *models.License, error) {
+	durationDays, err := trialDurationDays(opts.DurationDays)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.validateBindFile(&bindFile); err != nil {
+		return nil, nil, err
 	}
 
-	// 构造需要验证签名的数据
-	signData := fmt.Sprintf("%s:%s:%s:%s",
-		unbindFile.LicenseKey,
-		unbindFile.MachineID,
-		unbindFile.UnbindMetadata.UnbindTime.Format(time.RFC3339),
-		unbindFile.UnbindMetadata.Hostname)
+	var licenseFile *LicenseFile
+	var license *models.License
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var auth models.Authorization
+		if err := tx.First(&auth, authID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrAuthCodeNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权码失败")
+		}
+		if !auth.IsActive() {
+			return errors.ErrAuthCodeDisabled
+		}
+
+		// 检查机器是否已经激活
+		var activeCount int64
+		err := tx.Model(&models.License{}).Where("machine_id = ? AND status = ?",
+			bindFile.MachineID, models.LicenseStatusActive).Count(&activeCount).Error
+		if err != nil {
+			return errors.WrapError(err, 50001, "检查机器状态失败")
+		}
+		if activeCount > 0 {
+			return errors.ErrDuplicateMachine
+		}
+
+		// 每台设备只能试用一次（不区分授权码和授权状态，已转为正式授权的试用同样计入）
+		var trialCount int64
+		err = tx.Model(&models.License{}).Where("machine_id = ? AND (license_type = ? OR trial_issued_at IS NOT NULL)",
+			bindFile.MachineID, models.LicenseTypeTrial).Count(&trialCount).Error
+		if err != nil {
+			return errors.WrapError(err, 50001, "检查试用记录失败")
+		}
+		if trialCount > 0 {
+			return errors.ErrTrialAlreadyUsed
+		}
+
+		expiresAt := time.Now().AddDate(0, 0, durationDays)
+		licenseFile, license, err = s.generateLicenseFileWithExpiryAndDB(&auth, &bindFile, expiresAt, models.LicenseTypeTrial, tx)
+		if err != nil {
+			return err
+		}
+		license.SeatExempt = !opts.ConsumeSeat
+		license.TrialIssuedAt = &license.IssuedAt
+
+		if err := tx.Create(license).Error; err != nil {
+			return errors.WrapError(err, 50001, "保存试用授权记录失败")
+		}
+
+		if opts.ConsumeSeat {
+			return s.authService.ConsumeSeatsWithDB(tx, auth.ID, 1, SeatChange{
+				Reason:    models.SeatReasonTrialIssue,
+				LicenseID: &license.ID,
+				Remark:    bindFile.Hostname,
+			})
+		}
 
-	// 使用一次性解绑公钥验证
-	unbindPublicKey, err := crypto.LoadPublicKeyFromPEM(license.UnbindPublicKey)
+		return nil
+	})
 	if err != nil {
-		return nil, errors.WrapError(err, 50002, "解析解绑公钥失败")
+		return nil, nil, err
 	}
 
-	err = crypto.VerifySignature(unbindPublicKey, []byte(signData), unbindFile.UnbindProof)
+	return licenseFile, license, nil
+}
+
+// ConvertTrialLicense 将试用授权转为正式授权，沿用原授权标识和解绑密钥，设备无需重新绑定
+// 转换后到期时间按授权码规则重新计算，免席位的试用授权在转换时占用一个席位
+func (s *LicenseService) ConvertTrialLicense(licenseID uint) (*models.License, error) {
+	var license models.License
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Authorization").First(&license, licenseID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrLicenseNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权记录失败")
+		}
+
+		if !license.IsTrial() || license.Status != models.LicenseStatusActive {
+			return errors.ErrNotTrialLicense
+		}
+		if !license.Authorization.IsActive() {
+			return errors.ErrAuthCodeDisabled
+		}
+
+		if !license.ConsumesSeat() {
+			err := s.authService.ConsumeSeatsWithDB(tx, license.AuthorizationID, 1, SeatChange{
+				Reason:    models.SeatReasonTrialConvert,
+				LicenseID: &license.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		license.LicenseType = models.LicenseTypeFull
+		license.SeatExempt = false
+		license.ExpiresAt = license.Authorization.CalculateExpiryDate()
+
+		err := tx.Model(&license).Updates(map[string]interface{}{
+			"license_type": license.LicenseType,
+			"seat_exempt":  license.SeatExempt,
+			"expires_at":   license.ExpiresAt,
+		}).Error
+		if err != nil {
+			return errors.WrapError(err, 50001, "更新授权记录失败")
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, errors.ErrInvalidSignature
+		return nil, err
 	}
 
 	return &license, nil
 }
 
-// generateLicenseFile 生成授权文件
-func (s *LicenseService) generateLicenseFile(auth *models.Authorization, bindFile *BindFile) (*LicenseFile, *models.License, error) {
-	expiresAt := auth.CalculateExpiryDate()
-	return s.generateLicenseFileWithExpiry(auth, bindFile, expiresAt)
+// trialDurationDays 计算试用天数，未指定时使用配置的默认值
+func trialDurationDays(days int) (int, error) {
+	defaultDays, maxDays := 30, 90
+	if config.AppConfig != nil {
+		if config.AppConfig.System.TrialDays > 0 {
+			defaultDays = config.AppConfig.System.TrialDays
+		}
+		if config.AppConfig.System.MaxTrialDays > 0 {
+			maxDays = config.AppConfig.System.MaxTrialDays
+		}
+	}
+
+	if days == 0 {
+		return defaultDays, nil
+	}
+	if days < 0 || days > maxDays {
+		return 0, errors.NewAppError(40000, fmt.Sprintf("试用天数必须在1到%d天之间", maxDays))
+	}
+
+	return days, nil
 }
 
-// generateLicenseFileWithExpiry 生成带指定到期时间的授权文件
-func (s *LicenseService) generateLicenseFileWithExpiry(auth *models.Authorization, bindFile *BindFile, expiresAt time.Time) (*LicenseFile, *models.License, error) {
-	return s.generateLicenseFileWithExpiryAndDB(auth, bindFile, expiresAt, nil)
+// RenewedLicense 续期结果，包含更新后的授权记录和加密的续期文件
+type RenewedLicense struct {
+	License     models.License
+	RenewalFile *EncryptedFileResponse
 }
 
-// generateLicenseFileWithExpiryAndDB 生成带指定到期时间的授权文件（支持事务）
-func (s *LicenseService) generateLicenseFileWithExpiryAndDB(auth *models.Authorization, bindFile *BindFile, expiresAt time.Time, db *gorm.DB) (*LicenseFile, *models.License, error) {
-	// 生成一次性解绑密钥对
-	unbindKeyPair, err := crypto.GenerateRSAKeyPair(2048)
+// RenewLicense 延长单个授权的有效期，返回设备离线应用的加密续期文件
+func (s *LicenseService) RenewLicense(licenseID uint, expiresAt time.Time) (*RenewedLicense, error) {
+	if err := validateRenewalExpiry(expiresAt); err != nil {
+		return nil, err
+	}
+
+	var (
+		license     models.License
+		renewalFile *EncryptedFileResponse
+	)
+	// 续期文件在事务中生成，签名或加密失败时到期时间一并回滚
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&license, licenseID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrLicenseNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权记录失败")
+		}
+
+		if license.Status != models.LicenseStatusActive {
+			return errors.NewAppError(41005, "授权已失效，无法续期")
+		}
+		// 试用授权的有效期受最长试用天数限制，需转为正式授权后再续期
+		if license.IsTrial() {
+			return errors.ErrTrialNotAllowed
+		}
+		if !expiresAt.After(license.ExpiresAt) {
+			return errors.NewAppError(40000, "续期后的到期时间必须晚于当前到期时间")
+		}
+
+		var auth models.Authorization
+		if err := tx.First(&auth, license.AuthorizationID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrAuthCodeNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权码失败")
+		}
+		if !auth.IsActive() {
+			return errors.ErrAuthCodeDisabled
+		}
+
+		if err := s.extendLicenseExpiry(tx, &license, expiresAt); err != nil {
+			return err
+		}
+
+		var err error
+		renewalFile, err = s.generateRenewalFileWithDB(tx, &license)
+		return err
+	})
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "生成解绑密钥对失败")
+		return nil, err
 	}
 
-	unbindPrivateKeyPEM, err := unbindKeyPair.PrivateKeyToPEM()
+	return &RenewedLicense{License: license, RenewalFile: renewalFile}, nil
+}
+
+// RenewAuthorizationLicenses 批量延长授权码下所有有效正式授权的有效期
+// 到期时间已不早于目标时间的设备和试用授权保持不变，不生成续期文件
+func (s *LicenseService) RenewAuthorizationLicenses(authID uint, expiresAt time.Time) ([]RenewedLicense, error) {
+	if err := validateRenewalExpiry(expiresAt); err != nil {
+		return nil, err
+	}
+
+	var results []RenewedLicense
+	// 续期文件在事务中生成，任一设备签名或加密失败时整批回滚
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var auth models.Authorization
+		if err := tx.First(&auth, authID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrAuthCodeNotFound
+			}
+			return errors.WrapError(err, 50001, "获取授权码失败")
+		}
+		if !auth.IsActive() {
+			return errors.ErrAuthCodeDisabled
+		}
+
+		var licenses []models.License
+		err := tx.Where("authorization_id = ? AND status = ? AND license_type = ? AND expires_at < ?",
+			authID, models.LicenseStatusActive, models.LicenseTypeFull, expiresAt).Find(&licenses).Error
+		if err != nil {
+			return errors.WrapError(err, 50001, "获取授权列表失败")
+		}
+		if len(licenses) == 0 {
+			return errors.NewAppError(40000, "没有需要续期的设备")
+		}
+
+		results = make([]RenewedLicense, 0, len(licenses))
+		for i := range licenses {
+			if err := s.extendLicenseExpiry(tx, &licenses[i], expiresAt); err != nil {
+				return err
+			}
+
+			renewalFile, err := s.generateRenewalFileWithDB(tx, &licenses[i])
+			if err != nil {
+				return err
+			}
+			results = append(results, RenewedLicense{License: licenses[i], RenewalFile: renewalFile})
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "转换解绑私钥失败")
+		return nil, err
 	}
 
-	unbindPublicKeyPEM, err := unbindKeyPair.PublicKeyToPEM()
+	return results, nil
+}
+
+// extendLicenseExpiry 更新授权记录的到期时间
+func (s *LicenseService) extendLicenseExpiry(tx *gorm.DB, license *models.License, expiresAt time.Time) error {
+	err := tx.Model(license).Update("expires_at", expiresAt).Error
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "转换解绑公钥失败")
+		return errors.WrapError(err, 50001, "更新授权到期时间失败")
 	}
 
-	// 生成授权记录的唯一标识
-	now := time.Now()
-	licenseKey := s.generateLicenseKey(bindFile.MachineID, now)
+	logger.GetLogger().Info("授权已续期",
+		zap.Uint("license_id", license.ID),
+		zap.String("machine_id", license.MachineID),
+		zap.Time("expires_at", expiresAt))
 
-	// 创建授权数据
-	licenseData := LicenseData{
-		LicenseKey:       licenseKey,
-		MachineID:        bindFile.MachineID,
-		Hostname:         bindFile.Hostname,
-		IssuedAt:         now,
-		ExpiresAt:        expiresAt,
-		LicenseType:      "FULL",
-		UnbindPrivateKey: unbindPrivateKeyPEM,
+	return nil
+}
+
+// generateRenewalFileWithDB 根据授权记录生成签名并加密的续期文件，使用设备机器ID派生的AES密钥加密
+func (s *LicenseService) generateRenewalFileWithDB(db *gorm.DB, license *models.License) (*EncryptedFileResponse, error) {
+	renewalData := RenewalData{
+		LicenseKey: license.LicenseKey,
+		MachineID:  license.MachineID,
+		IssuedAt:   time.Now(),
+		ExpiresAt:  license.ExpiresAt,
 	}
 
-	// 签名授权数据
-	licenseDataBytes, err := json.Marshal(licenseData)
+	renewalDataBytes, err := json.Marshal(renewalData)
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "序列化授权数据失败")
+		return nil, errors.WrapError(err, 50002, "序列化续期数据失败")
 	}
 
-	// 如果提供了数据库连接（在事务中），使用该连接的RSA服务
-	var signature string
-	if db != nil {
-		rsaServiceWithDB := s.rsaService.WithDB(db)
-		signature, err = rsaServiceWithDB.SignData(licenseDataBytes)
-	} else {
-		signature, err = s.rsaService.SignData(licenseDataBytes)
-	}
+	signature, err := s.rsaService.WithDB(db).Sign(renewalDataBytes)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	// 创建授权文件
-	licenseFile := &LicenseFile{
-		LicenseData: licenseData,
-		Signature:   signature,
+	jsonData, err := json.Marshal(RenewalFile{
+		RenewalData: renewalData,
+		Signature:   signature.Signature,
+		KeyID:       signature.KeyID,
+		Algorithm:   signature.Algorithm,
+		Certificate: signature.Certificate,
+	})
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "序列化续期文件失败")
 	}
 
-	// 创建数据库记录
-	license := &models.License{
-		AuthorizationID:  auth.ID,
-		LicenseKey:       licenseKey,
-		MachineID:        bindFile.MachineID,
-		Hostname:         bindFile.Hostname,
-		UnbindPublicKey:  unbindPublicKeyPEM,
-		UnbindPrivateKey: unbindPrivateKeyPEM, // 同时保存私钥
-		IssuedAt:         now,
-		ExpiresAt:        expiresAt,
-		Status:           models.LicenseStatusActive,
-		ActivatedAt:      now,
+	encryptedContent, err := s.encryptForDeviceWithDB(db, jsonData, crypto.FileTypeRenewal, license.ClientPublicKey, crypto.GenerateClientAESKey(license.MachineID))
+	if err != nil {
+		return nil, err
 	}
 
-	return licenseFile, license, nil
+	return &EncryptedFileResponse{
+		EncryptedContent: encryptedContent,
+		FileType:         "renew",
+	}, nil
 }
 
-// generateLicenseKey 生成授权记录的唯一标识
-func (s *LicenseService) generateLicenseKey(machineID string, issuedAt time.Time) string {
-	data := fmt.Sprintf("%s:%s:%s", machineID, issuedAt.Format(time.RFC3339), uuid.New().String())
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:])
+// validateRenewalExpiry 检查续期目标时间
+func validateRenewalExpiry(expiresAt time.Time) error {
+	if !expiresAt.After(time.Now()) {
+		return errors.NewAppError(40000, "续期后的到期时间必须晚于当前时间")
+	}
+	return nil
 }
 
-// RegenerateLicenseFile 根据数据库记录重新生成license文件
-func (s *LicenseService) RegenerateLicenseFile(licenseID uint, userID interface{}) ([]byte, string, error) {
-	// 查找license记录
-	var license models.License
-	err := s.db.Preload("Authorization").First(&license, licenseID).Error
+// GenerateRevocationList 根据授权状态生成签名的吊销列表，authID为0时包含所有授权码
+// 已过期的授权在客户端本身就会失效，不再列入；版本取全局的吊销列表版本号，所有范围的列表共用并且只增不减
+func (s *LicenseService) GenerateRevocationList(authID uint) (*RevocationList, error) {
+	// 先读取版本号再查询授权，并发解绑时列表只会多包含条目，不会出现版本号已递增而条目缺失
+	version, err := revocationVersionWithDB(s.db)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, "", errors.ErrLicenseNotFound
-		}
-		return nil, "", errors.WrapError(err, 50001, "获取授权记录失败")
-	}
-
-	// 验证用户权限（简单验证，实际可根据需要扩展）
-	// 这里假设任何已认证用户都可以下载自己有权限的license
-	// 更严格的权限控制可以在这里实现
-	if userID == nil {
-		return nil, "", errors.NewAppError(40100, "用户未认证")
+		return nil, err
 	}
 
-	// 检查license状态
-	if license.Status != models.LicenseStatusActive {
-		return nil, "", errors.NewAppError(41005, "授权已失效，无法下载")
+	query := s.db.Where("status IN ?", []string{models.LicenseStatusUnbound, models.LicenseStatusForceUnbound})
+	if authID != 0 {
+		query = query.Where("authorization_id = ?", authID)
 	}
 
-	// 检查是否过期
-	if license.IsExpired() {
-		return nil, "", errors.NewAppError(41006, "授权已过期，无法下载")
+	var licenses []models.License
+	if err := query.Order("id ASC").Find(&licenses).Error; err != nil {
+		return nil, errors.WrapError(err, 50001, "获取已解绑授权失败")
 	}
 
-	// 使用数据库中保存的原始解绑密钥对
-	// 这样可以保证重新下载的license文件与原来的完全兼容
-	// 原license生成的解绑文件仍然有效
-
-	var unbindPrivateKeyPEM, unbindPublicKeyPEM string
-
-	// 检查数据库中是否有原始私钥
-	if license.UnbindPrivateKey != "" {
-		// 使用原始私钥
-		unbindPrivateKeyPEM = license.UnbindPrivateKey
-		unbindPublicKeyPEM = license.UnbindPublicKey
-
-		logger.GetLogger().Info("使用原始解绑密钥重新生成license文件",
-			zap.Uint("license_id", licenseID),
-			zap.String("machine_id", license.MachineID),
-			zap.String("hostname", license.Hostname))
-	} else {
-		// 兼容旧数据：如果数据库中没有私钥，重新生成（会导致解绑文件失效）
-		unbindKeyPair, err := crypto.GenerateRSAKeyPair(2048)
-		if err != nil {
-			return nil, "", errors.WrapError(err, 50002, "生成解绑密钥对失败")
-		}
-
-		unbindPrivateKeyPEM, err = unbindKeyPair.PrivateKeyToPEM()
-		if err != nil {
-			return nil, "", errors.WrapError(err, 50002, "转换解绑私钥失败")
+	now := time.Now()
+	revocationData := RevocationData{
+		Version:         version,
+		AuthorizationID: authID,
+		IssuedAt:        now,
+		Entries:         make([]RevocationItem, 0, len(licenses)),
+	}
+	for _, license := range licenses {
+		if license.ExpiresAt.Before(now) {
+			continue
 		}
 
-		unbindPublicKeyPEM, err = unbindKeyPair.PublicKeyToPEM()
-		if err != nil {
-			return nil, "", errors.WrapError(err, 50002, "转换解绑公钥失败")
+		revokedAt := license.UpdatedAt
+		if license.UnboundAt != nil {
+			revokedAt = *license.UnboundAt
 		}
-
-		logger.GetLogger().Warn("数据库中无原始私钥，重新生成将导致解绑文件失效",
-			zap.Uint("license_id", licenseID),
-			zap.String("machine_id", license.MachineID),
-			zap.String("hostname", license.Hostname))
+		revocationData.Entries = append(revocationData.Entries, RevocationItem{
+			LicenseKey: license.LicenseKey,
+			RevokedAt:  revokedAt,
+			Reason:     license.Status,
+		})
 	}
 
-	// 创建license数据
-	licenseData := LicenseData{
-		LicenseKey:       license.LicenseKey,
-		MachineID:        license.MachineID,
-		Hostname:         license.Hostname,
-		IssuedAt:         license.IssuedAt,
-		ExpiresAt:        license.ExpiresAt,
-		LicenseType:      "FULL",
-		UnbindPrivateKey: unbindPrivateKeyPEM,
+	revocationDataBytes, err := json.Marshal(revocationData)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "序列化吊销列表失败")
 	}
 
-	// 签名license数据
-	licenseDataBytes, err := json.Marshal(licenseData)
+	signature, err := s.rsaService.Sign(revocationDataBytes)
 	if err != nil {
-		return nil, "", errors.WrapError(err, 50002, "序列化授权数据失败")
+		return nil, err
 	}
 
-	signature, err := s.rsaService.SignData(licenseDataBytes)
+	return &RevocationList{
+		RevocationData: revocationData,
+		Signature:      signature.Signature,
+		KeyID:          signature.KeyID,
+		Algorithm:      signature.Algorithm,
+		Certificate:    signature.Certificate,
+	}, nil
+}
+
+// revocationVersionWithDB 获取吊销列表版本号，从未解绑过授权时为0
+func revocationVersionWithDB(db *gorm.DB) (int64, error) {
+	var setting models.SystemConfig
+	err := db.Where("config_key = ?", models.ConfigRevocationVersion).Limit(1).Find(&setting).Error
 	if err != nil {
-		return nil, "", err
+		return 0, errors.WrapError(err, 50001, "获取吊销列表版本失败")
+	}
+	if setting.ConfigValue == "" {
+		return 0, nil
 	}
 
-	// 创建license文件
-	licenseFile := LicenseFile{
-		LicenseData: licenseData,
-		Signature:   signature,
+	version, err := strconv.ParseInt(setting.ConfigValue, 10, 64)
+	if err != nil {
+		return 0, errors.WrapError(err, 50001, "吊销列表版本格式错误")
 	}
 
-	// 生成客户端AES密钥（基于机器ID）
-	clientAESKey := crypto.GenerateClientAESKey(license.MachineID)
+	return version, nil
+}
 
-	// 使用客户端AES密钥加密license文件
-	encryptedLicenseFile, err := s.EncryptLicenseFileWithClientAES(licenseFile, clientAESKey)
+// bumpRevocationVersionWithDB 递增吊销列表版本号，需要在解绑授权的事务中调用
+// 先锁定配置记录再递增，并发解绑时版本号不会重复或回退
+func bumpRevocationVersionWithDB(db *gorm.DB) error {
+	setting := models.SystemConfig{
+		ConfigKey:   models.ConfigRevocationVersion,
+		ConfigValue: "0",
+		Description: "吊销列表版本，每次解绑授权时递增",
+		UpdatedAt:   time.Now(),
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "config_key"}},
+		DoNothing: true,
+	}).Create(&setting).Error
 	if err != nil {
-		return nil, "", err
+		return errors.WrapError(err, 50001, "更新吊销列表版本失败")
 	}
 
-	// 如果使用了新生成的密钥对，需要更新数据库
-	if license.UnbindPrivateKey == "" {
-		// 更新数据库中的解绑密钥对（仅当原来没有私钥时）
-		err = s.db.Model(&license).Updates(map[string]interface{}{
-			"unbind_public_key":  unbindPublicKeyPEM,
-			"unbind_private_key": unbindPrivateKeyPEM,
-		}).Error
-		if err != nil {
-			logger.GetLogger().Warn("更新解绑密钥对失败",
-				zap.Uint("license_id", licenseID),
-				zap.Error(err))
-			// 不影响文件下载，只记录警告
-		}
+	var current models.SystemConfig
+	err = db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("config_key = ?", models.ConfigRevocationVersion).First(&current).Error
+	if err != nil {
+		return errors.WrapError(err, 50001, "更新吊销列表版本失败")
 	}
 
-	// 生成文件名
-	filename := fmt.Sprintf("%s.license", license.Hostname)
-	if filename == ".license" {
-		filename = fmt.Sprintf("license_%d.license", licenseID)
+	version, err := strconv.ParseInt(current.ConfigValue, 10, 64)
+	if err != nil {
+		return errors.WrapError(err, 50001, "吊销列表版本格式错误")
 	}
 
-	return []byte(encryptedLicenseFile.EncryptedContent), filename, nil
+	err = db.Model(&current).Updates(map[string]interface{}{
+		"config_value": strconv.FormatInt(version+1, 10),
+		"updated_at":   time.Now(),
+	}).Error
+	if err != nil {
+		return errors.WrapError(err, 50001, "更新吊销列表版本失败")
+	}
+
+	return nil
 }
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"os"
+	"sync"
+
+	"github.com/lyenrowe/LicenseCenter/internal/config"
+	"github.com/lyenrowe/LicenseCenter/internal/models"
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+	"github.com/lyenrowe/LicenseCenter/pkg/errors"
+	"github.com/lyenrowe/LicenseCenter/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// defaultMasterKeyEnv 未配置时读取主密钥的环境变量
+const defaultMasterKeyEnv = "LICENSE_MASTER_KEY"
+
+// customKeyWrapper 通过SetKeyWrapper注册的主密钥提供者（如KMS），优先于配置的本地主密钥
+var (
+	customKeyWrapperMu sync.RWMutex
+	customKeyWrapper   crypto.KeyWrapper
+)
+
+// SetKeyWrapper 注册主密钥提供者，传入nil时恢复使用配置的本地主密钥
+func SetKeyWrapper(wrapper crypto.KeyWrapper) {
+	customKeyWrapperMu.Lock()
+	defer customKeyWrapperMu.Unlock()
+	customKeyWrapper = wrapper
+}
+
+// masterKeyWrapper 获取当前主密钥提供者，依次使用注册的提供者、主密钥文件和环境变量
+// 均未配置时返回nil，私钥以明文保存
+func masterKeyWrapper() (crypto.KeyWrapper, error) {
+	customKeyWrapperMu.RLock()
+	wrapper := customKeyWrapper
+	customKeyWrapperMu.RUnlock()
+	if wrapper != nil {
+		return wrapper, nil
+	}
+
+	if config.AppConfig == nil {
+		return nil, nil
+	}
+	security := config.AppConfig.Security
+
+	var encoded string
+	if security.MasterKeyFile != "" {
+		data, err := os.ReadFile(security.MasterKeyFile)
+		if err != nil {
+			return nil, errors.WrapError(err, 50002, "读取主密钥文件失败")
+		}
+		encoded = string(data)
+	} else {
+		env := security.MasterKeyEnv
+		if env == "" {
+			env = defaultMasterKeyEnv
+		}
+		encoded = os.Getenv(env)
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+
+	return LoadLocalKeyWrapper(encoded)
+}
+
+// LoadLocalKeyWrapper 解析Base64编码的主密钥，创建本地主密钥提供者
+func LoadLocalKeyWrapper(encoded string) (crypto.KeyWrapper, error) {
+	masterKey, err := crypto.ParseMasterKey(encoded)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "主密钥无效")
+	}
+
+	wrapper, err := crypto.NewLocalKeyWrapper(masterKey)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "主密钥无效")
+	}
+
+	return wrapper, nil
+}
+
+// sealPrivateKey 配置了主密钥时加密私钥后保存，否则保存明文
+func sealPrivateKey(privateKeyPEM string) (string, error) {
+	wrapper, err := masterKeyWrapper()
+	if err != nil {
+		return "", err
+	}
+	if wrapper == nil {
+		return privateKeyPEM, nil
+	}
+
+	sealed, err := crypto.SealPrivateKey(wrapper, privateKeyPEM)
+	if err != nil {
+		return "", errors.WrapError(err, 50002, "加密私钥失败")
+	}
+
+	return sealed, nil
+}
+
+// openPrivateKey 获取密钥记录中的私钥PEM，已加密的私钥使用主密钥解密
+func openPrivateKey(rsaKey *models.RSAKey) (string, error) {
+	if !crypto.IsSealedPrivateKey(rsaKey.PrivateKey) {
+		return rsaKey.PrivateKey, nil
+	}
+
+	wrapper, err := masterKeyWrapper()
+	if err != nil {
+		return "", err
+	}
+	if wrapper == nil {
+		return "", errors.NewAppError(50002, "私钥已加密，但未配置主密钥")
+	}
+
+	privateKeyPEM, err := crypto.OpenPrivateKey(rsaKey.PrivateKey, wrapper)
+	if err != nil {
+		return "", errors.WrapError(err, 50002, "解密私钥失败")
+	}
+
+	return privateKeyPEM, nil
+}
+
+// RewrapPrivateKeys 使用当前主密钥重新加密所有服务端私钥
+// 明文私钥被加密；用旧主密钥加密的私钥需通过previous传入旧主密钥，解密后用当前主密钥重新加密
+// 返回重新加密的密钥数量，已使用当前主密钥加密的私钥不做修改
+func (s *RSAService) RewrapPrivateKeys(previous ...crypto.KeyWrapper) (int, error) {
+	wrapper, err := masterKeyWrapper()
+	if err != nil {
+		return 0, err
+	}
+	if wrapper == nil {
+		return 0, errors.NewAppError(40000, "未配置主密钥")
+	}
+
+	var records []models.RSAKey
+	if err := s.db.Order("id").Find(&records).Error; err != nil {
+		return 0, errors.WrapError(err, 50001, "获取密钥列表失败")
+	}
+
+	wrappers := append([]crypto.KeyWrapper{wrapper}, previous...)
+	rewrapped := 0
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range records {
+			record := &records[i]
+			if crypto.SealedKeyID(record.PrivateKey) == wrapper.KeyID() {
+				continue
+			}
+
+			privateKeyPEM, err := crypto.OpenPrivateKey(record.PrivateKey, wrappers...)
+			if err != nil {
+				return errors.WrapError(err, 50002, "解密私钥失败: "+record.KeyID)
+			}
+			sealed, err := crypto.SealPrivateKey(wrapper, privateKeyPEM)
+			if err != nil {
+				return errors.WrapError(err, 50002, "加密私钥失败: "+record.KeyID)
+			}
+
+			if err := tx.Model(record).Update("private_key", sealed).Error; err != nil {
+				return errors.WrapError(err, 50001, "保存加密私钥失败")
+			}
+			rewrapped++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logger.GetLogger().Info("已使用主密钥重新加密服务端私钥",
+		zap.String("master_key_id", wrapper.KeyID()),
+		zap.Int("count", rewrapped))
+
+	return rewrapped, nil
+}
@@ -2,14 +2,24 @@ package services
 
 import (
 	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"time"
 
+	"github.com/lyenrowe/LicenseCenter/internal/config"
 	"github.com/lyenrowe/LicenseCenter/internal/database"
 	"github.com/lyenrowe/LicenseCenter/internal/models"
 	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
 	"github.com/lyenrowe/LicenseCenter/pkg/errors"
+	"github.com/lyenrowe/LicenseCenter/pkg/logger"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// defaultKeyGraceDays 未配置时旧密钥的解密宽限天数
+const defaultKeyGraceDays = 90
+
 // RSAService RSA密钥管理服务
 type RSAService struct {
 	db *gorm.DB
@@ -29,93 +39,217 @@ func (s *RSAService) WithDB(db *gorm.DB) *RSAService {
 	}
 }
 
-// GetActiveKeyPair 获取当前活跃的RSA密钥对
+// GetActiveKeyPair 获取当前活跃的RSA密钥对（来自密钥环缓存）
 func (s *RSAService) GetActiveKeyPair() (*rsa.PrivateKey, *rsa.PublicKey, error) {
+	kr, err := s.loadKeyring()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if kr.active == nil {
+		// 如果没有活跃密钥，创建一个新的
+		return s.GenerateAndSaveKeyPair()
+	}
+
+	return kr.active.privateKey, kr.active.publicKey, nil
+}
+
+// GetActiveKey 获取当前活跃的密钥记录，不存在时返回gorm.ErrRecordNotFound
+func (s *RSAService) GetActiveKey() (*models.RSAKey, error) {
+	kr, err := s.loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	if kr.active == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	rsaKey := kr.active.record
+	return &rsaKey, nil
+}
+
+// GetKeyPairByKeyID 根据密钥ID获取仍可用于解密的密钥对
+func (s *RSAService) GetKeyPairByKeyID(keyID string) (*rsa.PrivateKey, *rsa.PublicKey, error) {
+	kr, err := s.loadKeyring()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key, ok := kr.keys[keyID]; ok && key.record.CanDecrypt() {
+		return key.privateKey, key.publicKey, nil
+	}
+
+	// 缓存中没有时查询数据库，可能是其他实例刚轮换的密钥，或需要返回准确的错误原因
 	var rsaKey models.RSAKey
-	err := s.db.Where("is_active = ?", true).First(&rsaKey).Error
+	err = s.db.Where("key_id = ?", keyID).First(&rsaKey).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			// 如果没有活跃密钥，创建一个新的
-			return s.GenerateAndSaveKeyPair()
+			return nil, nil, errors.NewAppError(41003, fmt.Sprintf("未知的密钥ID: %s", keyID))
 		}
 		return nil, nil, errors.WrapError(err, 50001, "获取RSA密钥失败")
 	}
 
-	// 解析私钥
-	privateKey, err := crypto.LoadPrivateKeyFromPEM(rsaKey.PrivateKey)
+	if !rsaKey.IsRSA() {
+		return nil, nil, errors.NewAppError(41003, fmt.Sprintf("密钥 %s 是签名密钥，不能用于加密文件", keyID))
+	}
+	if !rsaKey.CanDecrypt() {
+		return nil, nil, errors.NewAppError(41003, fmt.Sprintf("密钥 %s 已退役，请使用最新公钥重新生成文件", keyID))
+	}
+
+	keyringCache.invalidate()
+	return parseKeyPair(&rsaKey)
+}
+
+// DecryptFile 解密Base64编码的加密文件，返回明文和文件中的AES密钥
+// 信封中带密钥ID时使用对应的历史密钥；旧版无密钥ID的文件依次尝试活跃密钥和宽限期内的密钥
+func (s *RSAService) DecryptFile(base64Data string) ([]byte, []byte, error) {
+	return s.DecryptFileAs(base64Data, crypto.FileTypeUnknown)
+}
+
+// DecryptFileAs 解密指定类型的加密文件，v3信封头部中的文件类型与期望不符时拒绝解密，旧版文件不检查类型
+func (s *RSAService) DecryptFileAs(base64Data string, fileType crypto.FileType) ([]byte, []byte, error) {
+	encryptedData, err := base64.StdEncoding.DecodeString(base64Data)
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "解析RSA私钥失败")
+		return nil, nil, fmt.Errorf("Base64解码失败: %w", err)
 	}
 
-	// 解析公钥
-	publicKey, err := crypto.LoadPublicKeyFromPEM(rsaKey.PublicKey)
+	envelope, err := crypto.ParseEnvelope(encryptedData)
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "解析RSA公钥失败")
+		return nil, nil, err
+	}
+	if err := envelope.CheckFileType(fileType); err != nil {
+		return nil, nil, errors.WrapError(err, 41003, "加密文件类型不匹配")
 	}
 
-	return privateKey, publicKey, nil
+	if envelope.KeyID != "" {
+		privateKey, _, err := s.GetKeyPairByKeyID(envelope.KeyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return envelope.Open(privateKey)
+	}
+
+	// 确保至少存在一个活跃密钥
+	if _, _, err := s.GetActiveKeyPair(); err != nil {
+		return nil, nil, err
+	}
+
+	kr, err := s.loadKeyring()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var lastErr error
+	for _, key := range kr.decrypt {
+		if !key.record.CanDecrypt() {
+			continue
+		}
+
+		jsonData, aesKey, err := envelope.Open(key.privateKey)
+		if err == nil {
+			return jsonData, aesKey, nil
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("没有可用于解密的密钥")
+	}
+	return nil, nil, lastErr
 }
 
-// GenerateAndSaveKeyPair 生成并保存新的RSA密钥对
+// GenerateAndSaveKeyPair 生成并保存新的RSA密钥对，作为新的加密密钥
+// 配置的签名算法为RSA算法时新密钥使用该算法签名，否则记为RS256
 func (s *RSAService) GenerateAndSaveKeyPair() (*rsa.PrivateKey, *rsa.PublicKey, error) {
-	// 生成新的RSA密钥对
-	keyPair, err := crypto.GenerateRSAKeyPair(2048)
-	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "生成RSA密钥对失败")
+	algorithm := signingAlgorithm()
+	if !crypto.IsRSAAlgorithm(algorithm) {
+		algorithm = crypto.SignatureRS256
 	}
 
-	// 转换为PEM格式
-	privateKeyPEM, err := keyPair.PrivateKeyToPEM()
+	rsaKey, err := s.GenerateSigningKey(algorithm)
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "转换私钥为PEM格式失败")
+		return nil, nil, err
 	}
 
-	publicKeyPEM, err := keyPair.PublicKeyToPEM()
+	return parseKeyPair(rsaKey)
+}
+
+// GenerateSigningKey 生成并保存指定算法的新密钥，同类的现有活跃密钥进入宽限期
+// RSA密钥替换当前的加密密钥；ECDSA和Ed25519密钥只用于签名，替换当前的签名专用密钥
+func (s *RSAService) GenerateSigningKey(algorithm string) (*models.RSAKey, error) {
+	keyPair, err := crypto.GenerateSigningKeyPair(algorithm, 2048)
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50002, "转换公钥为PEM格式失败")
+		return nil, errors.WrapError(err, 50002, "生成密钥对失败")
 	}
 
-	// 开始数据库事务
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	publicKey, err := crypto.ParsePublicKeyPEM(keyPair.PublicKeyPEM)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "解析公钥失败")
+	}
 
-	// 将现有的活跃密钥设为非活跃
-	err = tx.Model(&models.RSAKey{}).Where("is_active = ?", true).Update("is_active", false).Error
+	keyID, err := crypto.KeyIDFromPublicKey(publicKey)
 	if err != nil {
-		tx.Rollback()
-		return nil, nil, errors.WrapError(err, 50001, "更新旧密钥状态失败")
+		return nil, errors.WrapError(err, 50002, "计算密钥ID失败")
 	}
 
-	// 保存新密钥
-	newKey := models.RSAKey{
-		PrivateKey: privateKeyPEM,
-		PublicKey:  publicKeyPEM,
-		IsActive:   true,
+	// 同类密钥：RSA密钥（包括旧版未记录算法的密钥）或签名专用密钥
+	sameKind := []string{crypto.SignatureES256, crypto.SignatureEdDSA}
+	kindQuery := "algorithm IN ?"
+	if crypto.IsRSAAlgorithm(algorithm) {
+		sameKind = []string{crypto.SignatureRS256, crypto.SignaturePS256}
+		kindQuery = "(algorithm IN ? OR algorithm = '' OR algorithm IS NULL)"
 	}
 
-	err = tx.Create(&newKey).Error
+	// 配置了主密钥时私钥加密后保存
+	privateKey, err := sealPrivateKey(keyPair.PrivateKeyPEM)
 	if err != nil {
-		tx.Rollback()
-		return nil, nil, errors.WrapError(err, 50001, "保存新RSA密钥失败")
+		return nil, err
 	}
 
-	// 提交事务
-	err = tx.Commit().Error
+	newKey := models.RSAKey{
+		KeyID:      keyID,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		PublicKey:  keyPair.PublicKeyPEM,
+		Status:     models.RSAKeyStatusActive,
+	}
+
+	// 在事务中切换活跃密钥（已处于事务中时使用保存点）
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 将现有的同类活跃密钥转入宽限期，宽限期内仍可解密用旧公钥生成的文件
+		now := time.Now()
+		graceUntil := now.AddDate(0, 0, keyGraceDays())
+		err := tx.Model(&models.RSAKey{}).
+			Where("status = ?", models.RSAKeyStatusActive).
+			Where(kindQuery, sameKind).
+			Updates(map[string]interface{}{
+				"status":      models.RSAKeyStatusRetiring,
+				"retired_at":  now,
+				"grace_until": graceUntil,
+			}).Error
+		if err != nil {
+			return errors.WrapError(err, 50001, "更新旧密钥状态失败")
+		}
+
+		// 保存新密钥
+		if err := tx.Create(&newKey).Error; err != nil {
+			return errors.WrapError(err, 50001, "保存新密钥失败")
+		}
+
+		return bumpKeyringVersionWithDB(tx)
+	})
 	if err != nil {
-		return nil, nil, errors.WrapError(err, 50001, "提交事务失败")
+		return nil, err
 	}
+	keyringCache.invalidate()
 
-	return keyPair.PrivateKey, keyPair.PublicKey, nil
+	return &newKey, nil
 }
 
 // GetPublicKeyPEM 获取当前活跃的公钥PEM格式
 func (s *RSAService) GetPublicKeyPEM() (string, error) {
-	var rsaKey models.RSAKey
-	err := s.db.Where("is_active = ?", true).First(&rsaKey).Error
+	rsaKey, err := s.GetActiveKey()
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return "", errors.ErrCryptoError
@@ -126,31 +260,194 @@ func (s *RSAService) GetPublicKeyPEM() (string, error) {
 	return rsaKey.PublicKey, nil
 }
 
-// SignData 使用当前活跃的私钥签名数据
+// DataSignature 签名结果
+type DataSignature struct {
+	Signature   string
+	KeyID       string
+	Algorithm   string
+	Certificate string // 签名密钥证书，未导入或已过期时为空
+}
+
+// SignData 使用当前活跃的签名密钥签名数据
 func (s *RSAService) SignData(data []byte) (string, error) {
-	privateKey, _, err := s.GetActiveKeyPair()
+	signature, err := s.Sign(data)
 	if err != nil {
 		return "", err
 	}
+	return signature.Signature, nil
+}
+
+// SignDataWithKeyID 使用当前活跃的签名密钥签名数据，同时返回签名密钥的ID
+func (s *RSAService) SignDataWithKeyID(data []byte) (string, string, error) {
+	signature, err := s.Sign(data)
+	if err != nil {
+		return "", "", err
+	}
+	return signature.Signature, signature.KeyID, nil
+}
+
+// Sign 使用配置的签名算法对应的活跃密钥签名数据，返回签名、密钥ID和签名算法
+func (s *RSAService) Sign(data []byte) (*DataSignature, error) {
+	key, err := s.activeSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := key.signer.Sign(data)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "签名失败")
+	}
+
+	result := &DataSignature{
+		Signature: signature,
+		KeyID:     key.record.KeyID,
+		Algorithm: key.signer.Algorithm(),
+	}
 
-	signature, err := crypto.SignData(privateKey, data)
+	// 附带签名密钥证书，客户端只需内置根公钥即可验证轮换后的签名密钥
+	if cert := key.certificate; cert != nil {
+		now := time.Now()
+		if now.Before(cert.Data.NotBefore) || now.After(cert.Data.NotAfter) {
+			logger.GetLogger().Warn("签名密钥证书不在有效期内，签发的文件将不附带证书",
+				zap.String("key_id", key.record.KeyID),
+				zap.Time("not_after", cert.Data.NotAfter))
+		} else {
+			result.Certificate = key.record.Certificate
+		}
+	}
+
+	return result, nil
+}
+
+// GetActiveSigningKey 获取当前用于签名的密钥记录，签名算法为RSA算法时与加密密钥相同
+func (s *RSAService) GetActiveSigningKey() (*models.RSAKey, error) {
+	key, err := s.activeSigningKey()
 	if err != nil {
-		return "", errors.WrapError(err, 50002, "RSA签名失败")
+		return nil, err
 	}
 
-	return signature, nil
+	rsaKey := key.record
+	return &rsaKey, nil
 }
 
-// VerifySignature 使用公钥验证签名
+// activeSigningKey 获取配置的签名算法对应的活跃密钥，不存在时生成新密钥
+func (s *RSAService) activeSigningKey() (*keyringKey, error) {
+	algorithm := signingAlgorithm()
+	if !crypto.IsSupportedSignatureAlgorithm(algorithm) {
+		return nil, errors.NewAppError(50002, fmt.Sprintf("不支持的签名算法: %s", algorithm))
+	}
+
+	kr, err := s.loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := kr.signers[algorithm]; ok {
+		return key, nil
+	}
+
+	// 首次使用该签名算法（或修改了签名算法）时生成对应的密钥
+	if _, err := s.GenerateSigningKey(algorithm); err != nil {
+		return nil, err
+	}
+
+	kr, err = s.loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := kr.signers[algorithm]
+	if !ok {
+		return nil, errors.NewAppError(50002, "获取签名密钥失败")
+	}
+	return key, nil
+}
+
+// GetCertificateRequest 获取待根密钥签发证书的签名密钥信息，keyID为空时使用当前签名密钥
+func (s *RSAService) GetCertificateRequest(keyID string) (*crypto.CertificateData, error) {
+	var rsaKey *models.RSAKey
+	if keyID == "" {
+		var err error
+		rsaKey, err = s.GetActiveSigningKey()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rsaKey = &models.RSAKey{}
+		if err := s.db.Where("key_id = ?", keyID).First(rsaKey).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, errors.ErrKeyNotFound
+			}
+			return nil, errors.WrapError(err, 50001, "获取密钥失败")
+		}
+	}
+
+	return &crypto.CertificateData{
+		KeyID:     rsaKey.KeyID,
+		Algorithm: rsaKey.SignatureAlgorithm(),
+		PublicKey: rsaKey.PublicKey,
+	}, nil
+}
+
+// ImportCertificate 导入离线根密钥签发的签名密钥证书
+// 必须配置根公钥并验证证书签名；证书必须与密钥的公钥和算法一致，且尚未过期
+func (s *RSAService) ImportCertificate(keyID, encoded string) error {
+	var rsaKey models.RSAKey
+	if err := s.db.Where("key_id = ?", keyID).First(&rsaKey).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errors.ErrKeyNotFound
+		}
+		return errors.WrapError(err, 50001, "获取密钥失败")
+	}
+
+	cert, err := crypto.ParseCertificate(encoded)
+	if err != nil {
+		return errors.WrapError(err, 40035, errors.ErrInvalidCertificate.Message)
+	}
+	if cert.Data.KeyID != rsaKey.KeyID || cert.Data.Algorithm != rsaKey.SignatureAlgorithm() {
+		return errors.NewAppError(40035, "证书与签名密钥不一致")
+	}
+	if time.Now().After(cert.Data.NotAfter) {
+		return errors.NewAppError(40035, "证书已过期")
+	}
+
+	root, err := rootVerifier()
+	if err != nil {
+		return err
+	}
+	if root == nil {
+		return errors.NewAppError(40035, "未配置根公钥（security.root_public_key_file），无法验证签名密钥证书")
+	}
+	if _, err := cert.Verify(root, cert.Data.NotBefore); err != nil {
+		return errors.WrapError(err, 40035, errors.ErrInvalidCertificate.Message)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&rsaKey).Update("certificate", encoded).Error; err != nil {
+			return errors.WrapError(err, 50001, "保存签名密钥证书失败")
+		}
+		return bumpKeyringVersionWithDB(tx)
+	})
+	if err != nil {
+		return err
+	}
+	keyringCache.invalidate()
+
+	return nil
+}
+
+// VerifySignature 使用当前签名密钥的公钥验证签名
 func (s *RSAService) VerifySignature(data []byte, signature string) error {
-	_, publicKey, err := s.GetActiveKeyPair()
+	key, err := s.activeSigningKey()
 	if err != nil {
 		return err
 	}
 
-	err = crypto.VerifySignature(publicKey, data, signature)
+	verifier, err := crypto.NewVerifier(key.signer.Algorithm(), key.signer.Public())
 	if err != nil {
-		return errors.WrapError(err, 50002, "RSA签名验证失败")
+		return errors.WrapError(err, 50002, "创建验签器失败")
+	}
+
+	if err := verifier.Verify(data, signature); err != nil {
+		return errors.WrapError(err, 50002, "签名验证失败")
 	}
 
 	return nil
@@ -167,8 +464,147 @@ func (s *RSAService) ListKeys() ([]models.RSAKey, error) {
 	return keys, nil
 }
 
-// RotateKeys 轮换密钥（生成新密钥并设为活跃）
+// RotateKeys 轮换密钥（生成新密钥并设为活跃，旧密钥进入宽限期）
+// 签名算法不是RSA算法时同时轮换签名专用密钥
 func (s *RSAService) RotateKeys() error {
-	_, _, err := s.GenerateAndSaveKeyPair()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		service := s.WithDB(tx)
+		if _, _, err := service.GenerateAndSaveKeyPair(); err != nil {
+			return err
+		}
+
+		if algorithm := signingAlgorithm(); !crypto.IsRSAAlgorithm(algorithm) {
+			if _, err := service.GenerateSigningKey(algorithm); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	keyringCache.invalidate()
+
 	return err
 }
+
+// RetireKey 立即退役指定密钥，不再用于解密
+func (s *RSAService) RetireKey(keyID string) error {
+	var rsaKey models.RSAKey
+	err := s.db.Where("key_id = ?", keyID).First(&rsaKey).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errors.ErrKeyNotFound
+		}
+		return errors.WrapError(err, 50001, "获取RSA密钥失败")
+	}
+
+	if rsaKey.IsActive() {
+		return errors.ErrRetireActiveKey
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      models.RSAKeyStatusRetired,
+		"grace_until": now,
+	}
+	if rsaKey.RetiredAt == nil {
+		updates["retired_at"] = now
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&rsaKey).Updates(updates).Error; err != nil {
+			return errors.WrapError(err, 50001, "更新密钥状态失败")
+		}
+		return bumpKeyringVersionWithDB(tx)
+	})
+	if err != nil {
+		return err
+	}
+	keyringCache.invalidate()
+
+	return nil
+}
+
+// parseKeyPair 解析密钥记录中的PEM密钥对
+func parseKeyPair(rsaKey *models.RSAKey) (*rsa.PrivateKey, *rsa.PublicKey, error) {
+	privateKeyPEM, err := openPrivateKey(rsaKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 解析私钥
+	privateKey, err := crypto.LoadPrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return nil, nil, errors.WrapError(err, 50002, "解析RSA私钥失败")
+	}
+
+	// 解析公钥
+	publicKey, err := crypto.LoadPublicKeyFromPEM(rsaKey.PublicKey)
+	if err != nil {
+		return nil, nil, errors.WrapError(err, 50002, "解析RSA公钥失败")
+	}
+
+	return privateKey, publicKey, nil
+}
+
+// parseSigner 解析密钥记录中的私钥，创建记录算法对应的签名器
+func parseSigner(rsaKey *models.RSAKey) (crypto.Signer, error) {
+	privateKeyPEM, err := openPrivateKey(rsaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := crypto.ParsePrivateKeyPEM(privateKeyPEM)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "解析私钥失败")
+	}
+
+	signer, err := crypto.NewSigner(rsaKey.SignatureAlgorithm(), privateKey)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "创建签名器失败")
+	}
+
+	return signer, nil
+}
+
+// rootVerifier 加载配置的离线根公钥，未配置时返回nil
+func rootVerifier() (crypto.Verifier, error) {
+	if config.AppConfig == nil || config.AppConfig.Security.RootPublicKeyFile == "" {
+		return nil, nil
+	}
+
+	publicKeyPEM, err := os.ReadFile(config.AppConfig.Security.RootPublicKeyFile)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "读取根公钥文件失败")
+	}
+	publicKey, err := crypto.ParsePublicKeyPEM(string(publicKeyPEM))
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "解析根公钥失败")
+	}
+
+	algorithm := config.AppConfig.Security.RootKeyAlgorithm
+	if algorithm == "" {
+		algorithm = crypto.SignatureEdDSA
+	}
+	verifier, err := crypto.NewVerifier(algorithm, publicKey)
+	if err != nil {
+		return nil, errors.WrapError(err, 50002, "根公钥与根密钥算法不匹配")
+	}
+
+	return verifier, nil
+}
+
+// signingAlgorithm 获取新签发文件使用的签名算法
+func signingAlgorithm() string {
+	if config.AppConfig != nil && config.AppConfig.Security.SigningAlgorithm != "" {
+		return config.AppConfig.Security.SigningAlgorithm
+	}
+	return crypto.SignatureRS256
+}
+
+// keyGraceDays 获取密钥轮换宽限天数
+func keyGraceDays() int {
+	if config.AppConfig != nil && config.AppConfig.Security.KeyGraceDays > 0 {
+		return config.AppConfig.Security.KeyGraceDays
+	}
+	return defaultKeyGraceDays
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// anchorKeyPrefix 时间锚点密钥的派生前缀，与授权文件的AES密钥区分
+const anchorKeyPrefix = "LicenseCenter:ANCHOR:"
+
+// AnchorStore 时间锚点存储，记录上一次成功运行的时间
+type AnchorStore interface {
+	// Load 读取上次运行时间，从未保存过（或已被删除）时返回零值时间
+	Load() (time.Time, error)
+	// Save 保存本次运行时间
+	Save(t time.Time) error
+}
+
+// anchorRecord 时间锚点记录
+type anchorRecord struct {
+	LastRun   time.Time `json:"last_run"`
+	MachineID string    `json:"machine_id"`
+}
+
+// FileAnchorStore 基于文件的时间锚点存储，内容使用机器ID派生的密钥加密，复制到其他机器或被修改均无法解密
+type FileAnchorStore struct {
+	path      string
+	machineID string
+	key       []byte
+}
+
+// NewFileAnchorStore 创建文件时间锚点存储
+func NewFileAnchorStore(path, machineID string) *FileAnchorStore {
+	return &FileAnchorStore{
+		path:      path,
+		machineID: machineID,
+		key:       deriveSealKey(anchorKeyPrefix, machineID),
+	}
+}
+
+// Load 读取并解密上次运行时间
+func (s *FileAnchorStore) Load() (time.Time, error) {
+	jsonData, err := readSealedFile(s.path, s.key)
+	if err != nil {
+		return time.Time{}, newVerifyError(ErrAnchorInvalid, err)
+	}
+	if jsonData == nil {
+		return time.Time{}, nil
+	}
+
+	var record anchorRecord
+	if err := json.Unmarshal(jsonData, &record); err != nil {
+		return time.Time{}, newVerifyError(ErrAnchorInvalid, err)
+	}
+	if record.MachineID != s.machineID {
+		return time.Time{}, newVerifyError(ErrAnchorInvalid, fmt.Errorf("时间锚点不属于当前机器"))
+	}
+
+	return record.LastRun, nil
+}
+
+// Save 加密保存本次运行时间
+func (s *FileAnchorStore) Save(t time.Time) error {
+	jsonData, err := json.Marshal(anchorRecord{LastRun: t.UTC(), MachineID: s.machineID})
+	if err != nil {
+		return fmt.Errorf("序列化时间锚点失败: %w", err)
+	}
+
+	if err := writeSealedFile(s.path, s.key, jsonData); err != nil {
+		return fmt.Errorf("保存时间锚点失败: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"time"
+
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+)
+
+// NewBindFile 生成v2绑定请求，返回绑定文件和Base64编码的客户端私钥
+// 客户端私钥需保存在本机，验证授权时通过WithClientPrivateKey传入，用于解密服务端返回的授权文件
+func NewBindFile(hostname, machineID string) (*BindFile, string, error) {
+	clientKey, err := crypto.GenerateClientKeyPair()
+	if err != nil {
+		return nil, "", err
+	}
+
+	bindFile := &BindFile{
+		Hostname:        hostname,
+		MachineID:       machineID,
+		RequestTime:     time.Now().UTC(),
+		ClientPublicKey: crypto.EncodeClientPublicKey(clientKey.PublicKey()),
+	}
+
+	return bindFile, crypto.EncodeClientPrivateKey(clientKey), nil
+}
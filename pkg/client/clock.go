@@ -0,0 +1,24 @@
+package client
+
+import "time"
+
+// Clock 时间来源，测试中可替换为固定时间
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc 将函数适配为Clock
+type ClockFunc func() time.Time
+
+// Now 返回当前时间
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// systemClock 系统时钟
+type systemClock struct{}
+
+// Now 返回系统当前时间
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
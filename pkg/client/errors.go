@@ -0,0 +1,77 @@
+package client
+
+import "fmt"
+
+// Reason 授权验证失败原因
+type Reason string
+
+// 授权验证失败原因常量
+const (
+	ReasonMalformed       Reason = "malformed"           // 文件格式错误
+	ReasonDecryptFailed   Reason = "decrypt_failed"      // 解密失败（通常是文件不属于本机）
+	ReasonUnknownKey      Reason = "unknown_key"         // 签名密钥未被信任
+	ReasonInvalidSign     Reason = "invalid_signature"   // 签名验证失败，文件被篡改
+	ReasonMachineMismatch Reason = "machine_mismatch"    // 授权不属于当前机器
+	ReasonExpired         Reason = "expired"             // 授权已过期
+	ReasonMachineID       Reason = "machine_id"          // 无法获取当前机器ID
+	ReasonClockRollback   Reason = "clock_rollback"      // 系统时间被回拨
+	ReasonAnchorInvalid   Reason = "anchor_invalid"      // 时间锚点损坏或被篡改
+	ReasonRenewalMismatch Reason = "renewal_mismatch"    // 续期文件不属于当前授权
+	ReasonRevoked         Reason = "revoked"             // 授权已被吊销
+	ReasonCRLOutdated     Reason = "crl_outdated"        // 吊销列表版本低于已加载的版本
+	ReasonCRLStateInvalid Reason = "crl_state_invalid"   // 吊销列表版本记录损坏或被篡改
+	ReasonInvalidCert     Reason = "invalid_certificate" // 签名密钥证书无效、过期或不是可信根密钥签发
+)
+
+// VerifyError 授权验证错误，可通过errors.Is与预定义错误比较原因
+type VerifyError struct {
+	Reason  Reason
+	Message string
+	Err     error
+}
+
+// Error 实现error接口
+func (e *VerifyError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap 返回底层错误
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Is 按失败原因比较错误
+func (e *VerifyError) Is(target error) bool {
+	t, ok := target.(*VerifyError)
+	return ok && t.Reason == e.Reason
+}
+
+// newVerifyError 基于预定义错误创建带底层错误的验证错误
+func newVerifyError(base *VerifyError, err error) *VerifyError {
+	return &VerifyError{
+		Reason:  base.Reason,
+		Message: base.Message,
+		Err:     err,
+	}
+}
+
+// 预定义的验证错误
+var (
+	ErrMalformed          = &VerifyError{Reason: ReasonMalformed, Message: "授权文件格式错误"}
+	ErrDecryptFailed      = &VerifyError{Reason: ReasonDecryptFailed, Message: "授权文件解密失败"}
+	ErrUnknownKey         = &VerifyError{Reason: ReasonUnknownKey, Message: "授权文件的签名密钥不受信任"}
+	ErrInvalidSignature   = &VerifyError{Reason: ReasonInvalidSign, Message: "授权文件签名验证失败"}
+	ErrMachineMismatch    = &VerifyError{Reason: ReasonMachineMismatch, Message: "授权文件不属于当前机器"}
+	ErrExpired            = &VerifyError{Reason: ReasonExpired, Message: "授权已过期"}
+	ErrMachineID          = &VerifyError{Reason: ReasonMachineID, Message: "获取当前机器ID失败"}
+	ErrClockRollback      = &VerifyError{Reason: ReasonClockRollback, Message: "检测到系统时间被回拨，请修正系统时间后重试"}
+	ErrAnchorInvalid      = &VerifyError{Reason: ReasonAnchorInvalid, Message: "时间锚点损坏或被篡改"}
+	ErrRenewalMismatch    = &VerifyError{Reason: ReasonRenewalMismatch, Message: "续期文件不属于当前授权"}
+	ErrRevoked            = &VerifyError{Reason: ReasonRevoked, Message: "授权已被吊销"}
+	ErrCRLOutdated        = &VerifyError{Reason: ReasonCRLOutdated, Message: "吊销列表版本过旧"}
+	ErrCRLStateInvalid    = &VerifyError{Reason: ReasonCRLStateInvalid, Message: "吊销列表版本记录损坏或被篡改"}
+	ErrInvalidCertificate = &VerifyError{Reason: ReasonInvalidCert, Message: "签名密钥证书无效"}
+)
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+)
+
+// VerifyRenewalFile 读取并验证续期文件
+func (v *Verifier) VerifyRenewalFile(filePath string) (*RenewalFile, error) {
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	return v.VerifyRenewal(fileData)
+}
+
+// VerifyRenewal 解密续期文件并验证签名和机器ID，不检查是否属于某个授权
+func (v *Verifier) VerifyRenewal(fileData []byte) (*RenewalFile, error) {
+	machineID, err := v.currentMachineID()
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := decryptDeviceFile(fileData, crypto.FileTypeRenewal, machineID, v.clientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var signed struct {
+		RenewalData json.RawMessage `json:"renewal_data"`
+		signedFields
+	}
+	if err := json.Unmarshal(jsonData, &signed); err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	if _, err := v.verifyPayload(signed.RenewalData, signed.signedFields); err != nil {
+		return nil, err
+	}
+
+	renewalFile := &RenewalFile{
+		Signature:   signed.Signature,
+		KeyID:       signed.KeyID,
+		Algorithm:   signed.Algorithm,
+		Certificate: signed.Certificate,
+	}
+	if err := json.Unmarshal(signed.RenewalData, &renewalFile.RenewalData); err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	if renewalFile.RenewalData.MachineID != machineID {
+		return nil, ErrMachineMismatch
+	}
+
+	return renewalFile, nil
+}
+
+// applyRenewal 验证续期文件并应用到授权验证结果
+// 早于授权文件原到期时间的续期文件（如旧的续期文件）被忽略，不会缩短有效期
+func (v *Verifier) applyRenewal(result *Result, renewalData []byte) error {
+	renewalFile, err := v.VerifyRenewal(renewalData)
+	if err != nil {
+		return err
+	}
+
+	if renewalFile.RenewalData.LicenseKey != result.License.LicenseData.LicenseKey {
+		return newVerifyError(ErrRenewalMismatch, fmt.Errorf("续期文件授权标识: %s", renewalFile.RenewalData.LicenseKey))
+	}
+
+	if err := v.checkIssuedAt(result.VerifiedAt, renewalFile.RenewalData.IssuedAt); err != nil {
+		return err
+	}
+
+	if renewalFile.RenewalData.ExpiresAt.After(result.License.LicenseData.ExpiresAt) {
+		result.Renewal = &renewalFile.RenewalData
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// revocationKeyPrefix 吊销列表版本记录密钥的派生前缀，与时间锚点和授权文件的AES密钥区分
+const revocationKeyPrefix = "LicenseCenter:CRL:"
+
+// RevocationStore 吊销列表版本存储，按授权码范围记录已接受的最高版本，程序重启后仍拒绝导入旧列表
+type RevocationStore interface {
+	// Load 读取各范围已接受的版本，从未保存过时返回空
+	Load() (map[uint]int64, error)
+	// Save 保存各范围已接受的版本
+	Save(versions map[uint]int64) error
+}
+
+// revocationRecord 吊销列表版本记录
+type revocationRecord struct {
+	Versions  map[uint]int64 `json:"versions"`
+	MachineID string         `json:"machine_id"`
+}
+
+// FileRevocationStore 基于文件的吊销列表版本存储，内容使用机器ID派生的密钥加密，复制到其他机器或被修改均无法解密
+type FileRevocationStore struct {
+	path      string
+	machineID string
+	key       []byte
+}
+
+// NewFileRevocationStore 创建文件吊销列表版本存储
+func NewFileRevocationStore(path, machineID string) *FileRevocationStore {
+	return &FileRevocationStore{
+		path:      path,
+		machineID: machineID,
+		key:       deriveSealKey(revocationKeyPrefix, machineID),
+	}
+}
+
+// Load 读取并解密各范围已接受的版本
+func (s *FileRevocationStore) Load() (map[uint]int64, error) {
+	jsonData, err := readSealedFile(s.path, s.key)
+	if err != nil {
+		return nil, newVerifyError(ErrCRLStateInvalid, err)
+	}
+	if jsonData == nil {
+		return nil, nil
+	}
+
+	var record revocationRecord
+	if err := json.Unmarshal(jsonData, &record); err != nil {
+		return nil, newVerifyError(ErrCRLStateInvalid, err)
+	}
+	if record.MachineID != s.machineID {
+		return nil, newVerifyError(ErrCRLStateInvalid, fmt.Errorf("吊销列表版本记录不属于当前机器"))
+	}
+
+	return record.Versions, nil
+}
+
+// Save 加密保存各范围已接受的版本
+func (s *FileRevocationStore) Save(versions map[uint]int64) error {
+	jsonData, err := json.Marshal(revocationRecord{Versions: versions, MachineID: s.machineID})
+	if err != nil {
+		return fmt.Errorf("序列化吊销列表版本失败: %w", err)
+	}
+
+	if err := writeSealedFile(s.path, s.key, jsonData); err != nil {
+		return fmt.Errorf("保存吊销列表版本失败: %w", err)
+	}
+
+	return nil
+}
+
+// revocationScope 某个授权码范围已加载的吊销列表
+type revocationScope struct {
+	version int64
+	revoked map[string]RevocationItem // 按授权标识索引
+}
+
+// WithRevocationList 创建验证器时加载吊销列表
+func WithRevocationList(data []byte) Option {
+	return func(v *Verifier) error {
+		v.pendingCRL = data
+		return nil
+	}
+}
+
+// WithRevocationStore 持久化各范围已接受的吊销列表版本，程序重启后仍拒绝导入旧列表
+// 记录文件在程序启动前被删除时无法识别，此时只能与本次运行中已加载的列表比较
+func WithRevocationStore(store RevocationStore) Option {
+	return func(v *Verifier) error {
+		v.revocationStore = store
+		return nil
+	}
+}
+
+// LoadRevocationListFile 读取并加载吊销列表文件
+func (v *Verifier) LoadRevocationListFile(filePath string) (*RevocationList, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	return v.LoadRevocationList(data)
+}
+
+// LoadRevocationList 验证并加载吊销列表，只替换同一授权码范围之前加载的列表，其他范围的条目保留
+// 版本低于该范围已接受的版本时返回ErrCRLOutdated，防止用旧列表恢复已吊销的授权
+func (v *Verifier) LoadRevocationList(data []byte) (*RevocationList, error) {
+	list, err := v.VerifyRevocationList(data)
+	if err != nil {
+		return nil, err
+	}
+
+	scopeID := list.RevocationData.AuthorizationID
+	scope := &revocationScope{
+		version: list.RevocationData.Version,
+		revoked: make(map[string]RevocationItem, len(list.RevocationData.Entries)),
+	}
+	for _, item := range list.RevocationData.Entries {
+		scope.revoked[item.LicenseKey] = item
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	versions, err := v.acceptedRevocationVersions()
+	if err != nil {
+		return nil, err
+	}
+	if current, ok := versions[scopeID]; ok && scope.version < current {
+		return nil, newVerifyError(ErrCRLOutdated, fmt.Errorf("授权码范围 %d 当前版本 %d，导入版本 %d",
+			scopeID, current, scope.version))
+	}
+
+	versions[scopeID] = scope.version
+	if v.revocationStore != nil {
+		if err := v.revocationStore.Save(versions); err != nil {
+			return nil, newVerifyError(ErrCRLStateInvalid, err)
+		}
+	}
+	v.revocations[scopeID] = scope
+
+	return list, nil
+}
+
+// acceptedRevocationVersions 合并已加载列表和持久化记录中各范围的版本，取较大值
+func (v *Verifier) acceptedRevocationVersions() (map[uint]int64, error) {
+	versions := make(map[uint]int64, len(v.revocations))
+	for scopeID, scope := range v.revocations {
+		versions[scopeID] = scope.version
+	}
+
+	if v.revocationStore == nil {
+		return versions, nil
+	}
+
+	stored, err := v.revocationStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	for scopeID, version := range stored {
+		if current, ok := versions[scopeID]; !ok || version > current {
+			versions[scopeID] = version
+		}
+	}
+
+	return versions, nil
+}
+
+// VerifyRevocationList 验证吊销列表签名，不改变验证器状态
+func (v *Verifier) VerifyRevocationList(data []byte) (*RevocationList, error) {
+	var signed struct {
+		RevocationData json.RawMessage `json:"revocation_data"`
+		signedFields
+	}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	if _, err := v.verifyPayload(signed.RevocationData, signed.signedFields); err != nil {
+		return nil, err
+	}
+
+	list := &RevocationList{
+		Signature:   signed.Signature,
+		KeyID:       signed.KeyID,
+		Algorithm:   signed.Algorithm,
+		Certificate: signed.Certificate,
+	}
+	if err := json.Unmarshal(signed.RevocationData, &list.RevocationData); err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	return list, nil
+}
+
+// RevocationVersion 指定授权码范围已加载吊销列表的版本，authorizationID为0表示包含所有授权码的列表，未加载时返回0
+func (v *Verifier) RevocationVersion(authorizationID uint) int64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	scope, ok := v.revocations[authorizationID]
+	if !ok {
+		return 0
+	}
+	return scope.version
+}
+
+// checkRevoked 检查授权是否在任一已加载的吊销列表中
+func (v *Verifier) checkRevoked(licenseKey string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, scope := range v.revocations {
+		if item, ok := scope.revoked[licenseKey]; ok {
+			return newVerifyError(ErrRevoked, fmt.Errorf("吊销时间 %s", item.RevokedAt.Format("2006-01-02 15:04:05")))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+)
+
+// deriveSealKey 根据前缀和机器ID派生本地状态文件的加密密钥，不同用途使用不同前缀
+func deriveSealKey(prefix, machineID string) []byte {
+	hash := sha256.Sum256([]byte(prefix + machineID))
+	return hash[:]
+}
+
+// readSealedFile 读取并解密本地状态文件，文件不存在时返回nil
+func readSealedFile(path string, key []byte) ([]byte, error) {
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	encryptedData, err := base64.StdEncoding.DecodeString(string(fileData))
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.AESGCMDecrypt(encryptedData, key)
+}
+
+// writeSealedFile 加密写入本地状态文件，先写临时文件再替换，避免中断导致文件损坏
+func writeSealedFile(path string, key, data []byte) error {
+	encryptedData, err := crypto.AESGCMEncrypt(data, key)
+	if err != nil {
+		return fmt.Errorf("加密失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(base64.StdEncoding.EncodeToString(encryptedData)), 0600); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
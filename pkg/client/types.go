@@ -0,0 +1,120 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// BindFile 绑定请求文件结构
+type BindFile struct {
+	Hostname        string    `json:"hostname"`
+	MachineID       string    `json:"machine_id"`
+	RequestTime     time.Time `json:"request_time"`
+	ClientPublicKey string    `json:"client_public_key,omitempty"` // v2：Base64编码的客户端X25519公钥，服务端将授权文件加密给该公钥
+}
+
+// LicenseFile 授权文件结构
+type LicenseFile struct {
+	LicenseData LicenseData `json:"license_data"`
+	Signature   string      `json:"signature"`
+	KeyID       string      `json:"key_id,omitempty"`      // 签名所用服务端密钥的ID，用于客户端选择验签公钥
+	Algorithm   string      `json:"alg,omitempty"`         // 签名算法，旧版授权文件为空（RS256）
+	Certificate string      `json:"certificate,omitempty"` // 根密钥签发的签名密钥证书，客户端内置根公钥时据此信任轮换后的签名密钥
+}
+
+// LicenseData 授权数据结构
+type LicenseData struct {
+	LicenseKey       string           `json:"license_key"`
+	MachineID        string           `json:"machine_id"`
+	Hostname         string           `json:"hostname"`
+	IssuedAt         time.Time        `json:"issued_at"`           // 首次签发时间，重新签发时保持不变
+	SignedAt         *time.Time       `json:"signed_at,omitempty"` // 本次签名时间，重新签发时更新，按此时间校验签名密钥证书
+	ExpiresAt        time.Time        `json:"expires_at"`
+	LicenseType      string           `json:"license_type"`
+	UnbindPrivateKey string           `json:"unbind_private_key"`
+	Edition          string           `json:"edition,omitempty"`  // 产品版本
+	Features         []string         `json:"features,omitempty"` // 启用的功能模块
+	Limits           map[string]int64 `json:"limits,omitempty"`   // 数值限制
+}
+
+// HasFeature 检查授权是否包含指定功能模块
+func (d *LicenseData) HasFeature(name string) bool {
+	for _, feature := range d.Features {
+		if feature == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit 获取指定数值限制，未设置时返回false
+func (d *LicenseData) Limit(name string) (int64, bool) {
+	value, ok := d.Limits[name]
+	return value, ok
+}
+
+// RenewalFile 续期文件结构，离线设备无需重新绑定即可延长已安装授权的有效期
+type RenewalFile struct {
+	RenewalData RenewalData `json:"renewal_data"`
+	Signature   string      `json:"signature"`
+	KeyID       string      `json:"key_id,omitempty"`
+	Algorithm   string      `json:"alg,omitempty"`
+	Certificate string      `json:"certificate,omitempty"`
+}
+
+// RenewalData 续期数据结构
+type RenewalData struct {
+	LicenseKey string    `json:"license_key"`
+	MachineID  string    `json:"machine_id"`
+	IssuedAt   time.Time `json:"issued_at"`
+	ExpiresAt  time.Time `json:"expires_at"` // 续期后的到期时间
+}
+
+// RevocationList 授权吊销列表，离线环境定期导入后拒绝已被解绑或强制解绑的授权
+type RevocationList struct {
+	RevocationData RevocationData `json:"revocation_data"`
+	Signature      string         `json:"signature"`
+	KeyID          string         `json:"key_id,omitempty"`
+	Algorithm      string         `json:"alg,omitempty"`
+	Certificate    string         `json:"certificate,omitempty"`
+}
+
+// RevocationData 吊销列表数据结构
+type RevocationData struct {
+	Version         int64            `json:"version"`                    // 全局吊销列表版本号，每次解绑授权时递增，各范围的列表共用
+	AuthorizationID uint             `json:"authorization_id,omitempty"` // 列表范围，只包含该授权码的授权；为0时包含所有授权码
+	IssuedAt        time.Time        `json:"issued_at"`
+	Entries         []RevocationItem `json:"entries"`
+}
+
+// RevocationItem 吊销条目
+type RevocationItem struct {
+	LicenseKey string    `json:"license_key"`
+	RevokedAt  time.Time `json:"revoked_at"`
+	Reason     string    `json:"reason"` // 授权状态：unbound, force_unbound
+}
+
+// UnbindFile 解绑文件结构
+type UnbindFile struct {
+	LicenseKey     string         `json:"license_key"`
+	MachineID      string         `json:"machine_id"`
+	UnbindMetadata UnbindMetadata `json:"unbind_metadata"`
+	UnbindProof    string         `json:"unbind_proof"`
+}
+
+// UnbindMetadata 解绑元数据
+type UnbindMetadata struct {
+	UnbindTime    time.Time `json:"unbind_time"`
+	Hostname      string    `json:"hostname"`
+	ClientVersion string    `json:"client_version"`
+	UnbindReason  string    `json:"unbind_reason"`
+}
+
+// UnbindProofPayload 构造解绑证明的签名数据，客户端签名和服务端验签必须保持一致
+func UnbindProofPayload(licenseKey, machineID string, unbindTime time.Time, hostname string) []byte {
+	return []byte(fmt.Sprintf("%s:%s:%s:%s",
+		licenseKey,
+		machineID,
+		unbindTime.Format(time.RFC3339),
+		hostname))
+}
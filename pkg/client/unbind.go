@@ -0,0 +1,31 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+)
+
+// NewUnbindFile 使用授权文件中的一次性解绑私钥（RSA或Ed25519）生成解绑文件
+func NewUnbindFile(licenseFile *LicenseFile, hostname, clientVersion, reason string) (*UnbindFile, error) {
+	metadata := UnbindMetadata{
+		UnbindTime:    time.Now().UTC(),
+		Hostname:      hostname,
+		ClientVersion: clientVersion,
+		UnbindReason:  reason,
+	}
+
+	payload := UnbindProofPayload(licenseFile.LicenseData.LicenseKey, licenseFile.LicenseData.MachineID, metadata.UnbindTime, metadata.Hostname)
+	unbindProof, err := crypto.SignUnbindProof(licenseFile.LicenseData.UnbindPrivateKey, payload)
+	if err != nil {
+		return nil, fmt.Errorf("生成解绑证明失败: %w", err)
+	}
+
+	return &UnbindFile{
+		LicenseKey:     licenseFile.LicenseData.LicenseKey,
+		MachineID:      licenseFile.LicenseData.MachineID,
+		UnbindMetadata: metadata,
+		UnbindProof:    unbindProof,
+	}, nil
+}
@@ -0,0 +1,603 @@
+package client
+
+import (
+	"bytes"
+	gocrypto "crypto"
+	"crypto/ecdh"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+	"github.com/lyenrowe/LicenseCenter/pkg/utils"
+)
+
+// defaultRollbackTolerance 默认允许的时间回拨容差，避免NTP校时等正常调整被误判
+const defaultRollbackTolerance = 5 * time.Minute
+
+// defaultCertificateGrace 签名密钥证书过期后，其签名的文件仍被接受的默认时长
+const defaultCertificateGrace = 90 * 24 * time.Hour
+
+// Verifier 离线授权验证器
+//
+// 产品内嵌服务端公钥，加载加密的.license文件后依次完成：
+// 检查系统时间是否回拨、使用客户端私钥或本机派生的AES密钥解密、按密钥ID验证签名、校验机器ID、吊销状态和到期时间
+// 每个可信公钥绑定一种签名算法，文件中声明的算法与公钥不一致时拒绝验证
+// 配置根公钥后，未内置的签名密钥可通过文件附带的证书验证，服务端轮换签名密钥无需更新客户端
+type Verifier struct {
+	publicKeys        map[string]crypto.Verifier // 按密钥ID索引的可信公钥
+	roots             map[string]crypto.Verifier // 按密钥ID索引的可信根公钥
+	machineID         string                     // 为空时自动获取当前机器ID
+	clientKey         *ecdh.PrivateKey           // 生成.bind文件时的客户端私钥，用于解密v2授权文件
+	clock             Clock                      // 时间来源
+	anchor            AnchorStore                // 时间锚点存储，为空时只检查是否早于签发时间
+	rollbackTolerance time.Duration              // 允许的时间回拨容差
+	certificateGrace  time.Duration              // 签名密钥证书过期后仍接受其签名文件的时长
+	anchorSeen        atomic.Bool                // 已读取或保存过时间锚点，之后锚点丢失视为被删除
+
+	mu              sync.RWMutex              // 保护吊销列表，支持运行中定期导入
+	revocations     map[uint]*revocationScope // 按授权码范围索引的已加载吊销列表，0表示包含所有授权码的列表
+	revocationStore RevocationStore           // 吊销列表版本存储，为空时只与本次运行中已加载的列表比较
+	pendingCRL      []byte                    // 创建时待加载的吊销列表
+}
+
+// Option 验证器配置项
+type Option func(*Verifier) error
+
+// WithPublicKey 添加可信的服务端RSA公钥（RS256签名），keyID为空时根据公钥自动计算
+func WithPublicKey(keyID string, publicKey *rsa.PublicKey) Option {
+	return WithSigningKey(keyID, crypto.SignatureRS256, publicKey)
+}
+
+// WithPublicKeyPEM 添加PEM格式的可信服务端RSA公钥（RS256签名），keyID为空时根据公钥自动计算
+func WithPublicKeyPEM(keyID, publicKeyPEM string) Option {
+	return WithSigningKeyPEM(keyID, crypto.SignatureRS256, publicKeyPEM)
+}
+
+// WithSigningKey 添加指定签名算法的可信服务端公钥（RS256、PS256、ES256或EdDSA），keyID为空时根据公钥自动计算
+func WithSigningKey(keyID, algorithm string, publicKey gocrypto.PublicKey) Option {
+	return func(v *Verifier) error {
+		verifier, err := crypto.NewVerifier(algorithm, publicKey)
+		if err != nil {
+			return err
+		}
+		if keyID == "" {
+			keyID, err = crypto.KeyIDFromPublicKey(publicKey)
+			if err != nil {
+				return err
+			}
+		}
+		v.publicKeys[keyID] = verifier
+		return nil
+	}
+}
+
+// WithSigningKeyPEM 添加PEM格式、指定签名算法的可信服务端公钥，keyID为空时根据公钥自动计算
+func WithSigningKeyPEM(keyID, algorithm, publicKeyPEM string) Option {
+	return func(v *Verifier) error {
+		publicKey, err := crypto.ParsePublicKeyPEM(publicKeyPEM)
+		if err != nil {
+			return fmt.Errorf("解析服务端公钥失败: %w", err)
+		}
+		return WithSigningKey(keyID, algorithm, publicKey)(v)
+	}
+}
+
+// WithRootKey 添加可信的离线根公钥，由其签发证书的签名密钥均被信任
+func WithRootKey(algorithm string, publicKey gocrypto.PublicKey) Option {
+	return func(v *Verifier) error {
+		verifier, err := crypto.NewVerifier(algorithm, publicKey)
+		if err != nil {
+			return err
+		}
+		keyID, err := crypto.KeyIDFromPublicKey(publicKey)
+		if err != nil {
+			return err
+		}
+		v.roots[keyID] = verifier
+		return nil
+	}
+}
+
+// WithRootKeyPEM 添加PEM格式的可信离线根公钥
+func WithRootKeyPEM(algorithm, publicKeyPEM string) Option {
+	return func(v *Verifier) error {
+		publicKey, err := crypto.ParsePublicKeyPEM(publicKeyPEM)
+		if err != nil {
+			return fmt.Errorf("解析根公钥失败: %w", err)
+		}
+		return WithRootKey(algorithm, publicKey)(v)
+	}
+}
+
+// WithMachineID 指定当前机器ID，不指定时通过utils.GetMachineID获取
+func WithMachineID(machineID string) Option {
+	return func(v *Verifier) error {
+		v.machineID = machineID
+		return nil
+	}
+}
+
+// WithClientPrivateKey 指定生成.bind文件时保存的客户端私钥（Base64），用于解密v2加密的授权文件和续期文件
+func WithClientPrivateKey(encoded string) Option {
+	return func(v *Verifier) error {
+		privateKey, err := crypto.ParseClientPrivateKey(encoded)
+		if err != nil {
+			return err
+		}
+		v.clientKey = privateKey
+		return nil
+	}
+}
+
+// WithClock 指定时间来源，默认使用系统时钟
+func WithClock(clock Clock) Option {
+	return func(v *Verifier) error {
+		v.clock = clock
+		return nil
+	}
+}
+
+// WithTimeAnchor 启用时间回拨检测，每次验证成功后记录运行时间
+// 记录后锚点丢失返回ErrAnchorInvalid；程序重启前锚点已被删除时无法识别，此时仍不允许时间早于文件签发时间
+func WithTimeAnchor(store AnchorStore) Option {
+	return func(v *Verifier) error {
+		v.anchor = store
+		return nil
+	}
+}
+
+// WithRollbackTolerance 设置允许的时间回拨容差，默认5分钟
+func WithRollbackTolerance(tolerance time.Duration) Option {
+	return func(v *Verifier) error {
+		if tolerance < 0 {
+			return fmt.Errorf("时间回拨容差不能为负数")
+		}
+		v.rollbackTolerance = tolerance
+		return nil
+	}
+}
+
+// WithCertificateGrace 设置签名密钥证书过期后仍接受其签名文件的时长，默认90天
+// 签名时间由签名密钥自己写入，泄露的签名密钥可以倒填签名时间，因此还要求本机时间不晚于证书到期时间加宽限期
+func WithCertificateGrace(grace time.Duration) Option {
+	return func(v *Verifier) error {
+		if grace < 0 {
+			return fmt.Errorf("证书宽限期不能为负数")
+		}
+		v.certificateGrace = grace
+		return nil
+	}
+}
+
+// NewVerifier 创建授权验证器，至少需要一个可信公钥或根公钥
+func NewVerifier(opts ...Option) (*Verifier, error) {
+	v := &Verifier{
+		publicKeys:        make(map[string]crypto.Verifier),
+		roots:             make(map[string]crypto.Verifier),
+		revocations:       make(map[uint]*revocationScope),
+		clock:             systemClock{},
+		rollbackTolerance: defaultRollbackTolerance,
+		certificateGrace:  defaultCertificateGrace,
+	}
+
+	for _, opt := range opts {
+		if err := opt(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(v.publicKeys) == 0 && len(v.roots) == 0 {
+		return nil, fmt.Errorf("未配置可信的服务端公钥或根公钥")
+	}
+
+	if v.pendingCRL != nil {
+		if _, err := v.LoadRevocationList(v.pendingCRL); err != nil {
+			return nil, err
+		}
+		v.pendingCRL = nil
+	}
+
+	return v, nil
+}
+
+// Result 授权验证结果
+type Result struct {
+	License    *LicenseFile // 已验证签名的授权文件
+	Renewal    *RenewalData // 已应用的续期数据，未续期时为空
+	KeyID      string       // 验签所用公钥的ID
+	MachineID  string       // 当前机器ID
+	VerifiedAt time.Time    // 验证时间
+}
+
+// ExpiresAt 授权到期时间，已应用续期时返回续期后的到期时间
+func (r *Result) ExpiresAt() time.Time {
+	if r.Renewal != nil {
+		return r.Renewal.ExpiresAt
+	}
+	return r.License.LicenseData.ExpiresAt
+}
+
+// RemainingDays 剩余有效天数，已过期时返回0
+func (r *Result) RemainingDays() int {
+	remaining := r.ExpiresAt().Sub(r.VerifiedAt)
+	if remaining <= 0 {
+		return 0
+	}
+	return int(remaining.Hours() / 24)
+}
+
+// VerifyFile 读取并验证授权文件
+func (v *Verifier) VerifyFile(filePath string) (*Result, error) {
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	return v.Verify(fileData)
+}
+
+// Verify 验证授权文件内容（加密文件或明文JSON）
+// 签名有效但授权过期或不属于本机时，同时返回结果和错误，便于展示授权信息
+func (v *Verifier) Verify(fileData []byte) (*Result, error) {
+	return v.VerifyWithRenewal(fileData, nil)
+}
+
+// VerifyWithRenewal 验证授权文件并应用续期文件，到期时间以续期文件为准
+// renewalData为空时等同于Verify；续期文件不早于授权文件原到期时间时才生效
+func (v *Verifier) VerifyWithRenewal(fileData, renewalData []byte) (*Result, error) {
+	now := v.clock.Now()
+	if err := v.checkRollback(now); err != nil {
+		return nil, err
+	}
+
+	machineID, err := v.currentMachineID()
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := DecryptLicenseWithClientKey(fileData, machineID, v.clientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	licenseFile, keyID, err := v.verifySignature(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		License:    licenseFile,
+		KeyID:      keyID,
+		MachineID:  machineID,
+		VerifiedAt: now,
+	}
+
+	if licenseFile.LicenseData.MachineID != machineID {
+		return result, ErrMachineMismatch
+	}
+
+	// 删除时间锚点后只能回拨到签发时间，签发之前的时间必然是回拨
+	issuedAt := licenseFile.LicenseData.IssuedAt
+	if signedAt := licenseFile.LicenseData.SignedAt; signedAt != nil && signedAt.After(issuedAt) {
+		issuedAt = *signedAt
+	}
+	if err := v.checkIssuedAt(now, issuedAt); err != nil {
+		return result, err
+	}
+
+	if err := v.checkRevoked(licenseFile.LicenseData.LicenseKey); err != nil {
+		return result, err
+	}
+
+	if len(renewalData) > 0 {
+		if err := v.applyRenewal(result, renewalData); err != nil {
+			return result, err
+		}
+	}
+
+	if result.VerifiedAt.After(result.ExpiresAt()) {
+		return result, ErrExpired
+	}
+
+	if err := v.saveAnchor(now); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CheckClock 检查系统时间是否回拨并更新时间锚点，长时间运行的程序可定期调用
+func (v *Verifier) CheckClock() error {
+	now := v.clock.Now()
+	if err := v.checkRollback(now); err != nil {
+		return err
+	}
+
+	return v.saveAnchor(now)
+}
+
+// checkIssuedAt 当前时间早于文件签发时间（超出容差）时判定为时间回拨
+func (v *Verifier) checkIssuedAt(now, issuedAt time.Time) error {
+	if now.Add(v.rollbackTolerance).Before(issuedAt) {
+		return newVerifyError(ErrClockRollback, fmt.Errorf("当前时间 %s 早于文件签发时间 %s",
+			now.Format(time.RFC3339), issuedAt.Format(time.RFC3339)))
+	}
+
+	return nil
+}
+
+// checkRollback 当前时间早于上次运行时间（超出容差）时判定为时间回拨
+func (v *Verifier) checkRollback(now time.Time) error {
+	if v.anchor == nil {
+		return nil
+	}
+
+	lastRun, err := v.loadAnchor()
+	if err != nil {
+		return err
+	}
+
+	if !lastRun.IsZero() && now.Add(v.rollbackTolerance).Before(lastRun) {
+		return newVerifyError(ErrClockRollback, fmt.Errorf("当前时间 %s 早于上次运行时间 %s",
+			now.Format(time.RFC3339), lastRun.Format(time.RFC3339)))
+	}
+
+	return nil
+}
+
+// saveAnchor 记录本次运行时间，时间锚点只前进不后退
+func (v *Verifier) saveAnchor(now time.Time) error {
+	if v.anchor == nil {
+		return nil
+	}
+
+	lastRun, err := v.loadAnchor()
+	if err != nil {
+		return err
+	}
+	if now.Before(lastRun) {
+		return nil
+	}
+
+	if err := v.anchor.Save(now); err != nil {
+		return newVerifyError(ErrAnchorInvalid, err)
+	}
+	v.anchorSeen.Store(true)
+
+	return nil
+}
+
+// loadAnchor 读取时间锚点，本验证器已记录过锚点而锚点又丢失时判定为被删除
+func (v *Verifier) loadAnchor() (time.Time, error) {
+	lastRun, err := v.anchor.Load()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if lastRun.IsZero() {
+		if v.anchorSeen.Load() {
+			return time.Time{}, newVerifyError(ErrAnchorInvalid, fmt.Errorf("时间锚点在授权验证后丢失"))
+		}
+		return lastRun, nil
+	}
+	v.anchorSeen.Store(true)
+
+	return lastRun, nil
+}
+
+// currentMachineID 获取当前机器ID
+func (v *Verifier) currentMachineID() (string, error) {
+	if v.machineID != "" {
+		return v.machineID, nil
+	}
+
+	machineID, err := utils.GetMachineID()
+	if err != nil {
+		return "", newVerifyError(ErrMachineID, err)
+	}
+
+	return machineID, nil
+}
+
+// verifySignature 验证授权数据签名
+// 签名基于服务端序列化的license_data原文，因此直接对原始JSON验签，旧版SDK也能验证新增字段的授权文件
+func (v *Verifier) verifySignature(jsonData []byte) (*LicenseFile, string, error) {
+	var signed struct {
+		LicenseData json.RawMessage `json:"license_data"`
+		signedFields
+	}
+	if err := json.Unmarshal(jsonData, &signed); err != nil {
+		return nil, "", newVerifyError(ErrMalformed, err)
+	}
+	keyID, err := v.verifyPayload(signed.LicenseData, signed.signedFields)
+	if err != nil {
+		return nil, "", err
+	}
+
+	licenseFile := &LicenseFile{
+		Signature:   signed.Signature,
+		KeyID:       signed.KeyID,
+		Algorithm:   signed.Algorithm,
+		Certificate: signed.Certificate,
+	}
+	if err := json.Unmarshal(signed.LicenseData, &licenseFile.LicenseData); err != nil {
+		return nil, "", newVerifyError(ErrMalformed, err)
+	}
+
+	return licenseFile, keyID, nil
+}
+
+// signedFields 签名文件中与验签相关的字段
+type signedFields struct {
+	Signature   string `json:"signature"`
+	KeyID       string `json:"key_id"`
+	Algorithm   string `json:"alg"`
+	Certificate string `json:"certificate"`
+}
+
+// verifyPayload 对签名数据的原始JSON验签，返回验签成功的密钥ID
+// 签名密钥未内置但文件附带证书且配置了根公钥时，先验证证书链再验签
+func (v *Verifier) verifyPayload(payload json.RawMessage, fields signedFields) (string, error) {
+	if len(payload) == 0 || fields.Signature == "" {
+		return "", ErrMalformed
+	}
+
+	var signedData bytes.Buffer
+	if err := json.Compact(&signedData, payload); err != nil {
+		return "", newVerifyError(ErrMalformed, err)
+	}
+
+	if _, pinned := v.publicKeys[fields.KeyID]; !pinned && fields.KeyID != "" && fields.Certificate != "" && len(v.roots) > 0 {
+		return v.verifyWithCertificate(signedData.Bytes(), fields)
+	}
+
+	return v.verifyWithTrustedKeys(signedData.Bytes(), fields.Signature, fields.KeyID, fields.Algorithm)
+}
+
+// verifyWithCertificate 使用根公钥验证文件附带的签名密钥证书，再用证书中的公钥验签
+// 证书有效期按签名数据中的签名时间判断，签名密钥证书过期后，有效期内签名的文件在宽限期内仍然有效
+// 签名时间不可信（泄露的签名密钥可以倒填），因此同时按本机时间限制：签名时间不能晚于本机时间，本机时间不能晚于证书到期时间加宽限期
+func (v *Verifier) verifyWithCertificate(data []byte, fields signedFields) (string, error) {
+	cert, err := crypto.ParseCertificate(fields.Certificate)
+	if err != nil {
+		return "", newVerifyError(ErrInvalidCertificate, err)
+	}
+
+	root, ok := v.roots[cert.RootKeyID]
+	if !ok {
+		return "", newVerifyError(ErrUnknownKey, fmt.Errorf("根密钥ID: %s", cert.RootKeyID))
+	}
+	if cert.Data.KeyID != fields.KeyID {
+		return "", newVerifyError(ErrInvalidCertificate, fmt.Errorf("证书中的密钥ID与文件不一致"))
+	}
+
+	// 重新签发的授权文件保留首次签发时间，按签名时间校验证书；续期文件和吊销列表的签发时间即签名时间
+	var signed struct {
+		IssuedAt time.Time  `json:"issued_at"`
+		SignedAt *time.Time `json:"signed_at"`
+	}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return "", newVerifyError(ErrMalformed, err)
+	}
+	signedAt := signed.IssuedAt
+	if signed.SignedAt != nil {
+		signedAt = *signed.SignedAt
+	}
+
+	verifier, err := cert.Verify(root, signedAt)
+	if err != nil {
+		return "", newVerifyError(ErrInvalidCertificate, err)
+	}
+
+	now := v.clock.Now()
+	if signedAt.After(now.Add(v.rollbackTolerance)) {
+		return "", newVerifyError(ErrInvalidCertificate, fmt.Errorf("签名时间 %s 晚于当前时间 %s",
+			signedAt.Format(time.RFC3339), now.Format(time.RFC3339)))
+	}
+	if now.After(cert.Data.NotAfter.Add(v.certificateGrace)) {
+		return "", newVerifyError(ErrInvalidCertificate, fmt.Errorf("签名密钥证书已于 %s 过期，超过宽限期",
+			cert.Data.NotAfter.Format(time.RFC3339)))
+	}
+	if fields.Algorithm != "" && fields.Algorithm != verifier.Algorithm() {
+		return "", newVerifyError(ErrInvalidSignature, fmt.Errorf("签名算法%s与证书中的算法%s不一致", fields.Algorithm, verifier.Algorithm()))
+	}
+	if err := verifier.Verify(data, fields.Signature); err != nil {
+		return "", newVerifyError(ErrInvalidSignature, err)
+	}
+
+	return fields.KeyID, nil
+}
+
+// verifyWithTrustedKeys 使用可信公钥验签，返回验签成功的密钥ID
+// 带密钥ID的文件只使用对应公钥；旧版无密钥ID的文件依次尝试所有可信公钥
+// 签名算法以可信公钥绑定的算法为准，文件中声明的算法只用于一致性检查，防止算法替换
+func (v *Verifier) verifyWithTrustedKeys(data []byte, signature, keyID, algorithm string) (string, error) {
+	if keyID != "" {
+		verifier, ok := v.publicKeys[keyID]
+		if !ok {
+			return "", newVerifyError(ErrUnknownKey, fmt.Errorf("密钥ID: %s", keyID))
+		}
+		if algorithm != "" && algorithm != verifier.Algorithm() {
+			return "", newVerifyError(ErrInvalidSignature, fmt.Errorf("签名算法%s与可信公钥的算法%s不一致", algorithm, verifier.Algorithm()))
+		}
+		if err := verifier.Verify(data, signature); err != nil {
+			return "", newVerifyError(ErrInvalidSignature, err)
+		}
+		return keyID, nil
+	}
+
+	var lastErr error
+	for id, verifier := range v.publicKeys {
+		if algorithm != "" && algorithm != verifier.Algorithm() {
+			continue
+		}
+		if err := verifier.Verify(data, signature); err != nil {
+			lastErr = err
+			continue
+		}
+		return id, nil
+	}
+
+	return "", newVerifyError(ErrInvalidSignature, lastErr)
+}
+
+// DecryptLicense 使用机器ID派生的AES密钥解密授权文件，明文JSON文件原样返回
+func DecryptLicense(fileData []byte, machineID string) ([]byte, error) {
+	return DecryptLicenseWithClientKey(fileData, machineID, nil)
+}
+
+// DecryptLicenseWithClientKey 解密授权文件：加密给客户端公钥的文件使用客户端私钥，旧版文件使用机器ID派生的AES密钥
+func DecryptLicenseWithClientKey(fileData []byte, machineID string, clientKey *ecdh.PrivateKey) ([]byte, error) {
+	return decryptDeviceFile(fileData, crypto.FileTypeLicense, machineID, clientKey)
+}
+
+// decryptDeviceFile 解密服务端发给本机的文件，v3信封的文件类型必须与fileType一致，防止续期文件被当作授权文件使用
+func decryptDeviceFile(fileData []byte, fileType crypto.FileType, machineID string, clientKey *ecdh.PrivateKey) ([]byte, error) {
+	trimmed := bytes.TrimSpace(fileData)
+	if len(trimmed) == 0 {
+		return nil, ErrMalformed
+	}
+
+	// 明文授权文件
+	if trimmed[0] == '{' {
+		return trimmed, nil
+	}
+
+	encryptedData, err := base64.StdEncoding.DecodeString(string(trimmed))
+	if err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	envelope, err := crypto.ParseEnvelope(encryptedData)
+	if err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+	if err := envelope.CheckFileType(fileType); err != nil {
+		return nil, newVerifyError(ErrMalformed, err)
+	}
+
+	if envelope.UsesClientKey() {
+		if clientKey == nil {
+			return nil, newVerifyError(ErrDecryptFailed, fmt.Errorf("该文件需要客户端私钥解密"))
+		}
+		jsonData, err := envelope.OpenWithClientKey(clientKey)
+		if err != nil {
+			return nil, newVerifyError(ErrDecryptFailed, err)
+		}
+		return jsonData, nil
+	}
+
+	// 客户端不需要解密RSA部分，AES密钥由本机机器ID派生
+	jsonData, err := envelope.OpenWithAESKey(crypto.GenerateClientAESKey(machineID))
+	if err != nil {
+		return nil, newVerifyError(ErrDecryptFailed, err)
+	}
+
+	return jsonData, nil
+}
@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CertificateData 签名密钥证书内容：离线根密钥证明该签名公钥在有效期内可用于签发授权文件
+type CertificateData struct {
+	KeyID     string    `json:"key_id"`     // 签名密钥ID
+	Algorithm string    `json:"alg"`        // 签名密钥的签名算法
+	PublicKey string    `json:"public_key"` // 签名公钥（PEM格式）
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// Certificate 签名密钥证书
+type Certificate struct {
+	Data          CertificateData
+	Signature     string // 根密钥对证书内容的签名
+	RootKeyID     string // 根密钥ID
+	RootAlgorithm string // 根密钥的签名算法
+
+	raw []byte // 签名所基于的证书内容原文
+}
+
+// certificateJSON 证书的JSON结构，证书内容保留原文以便按签名时的字节验签
+type certificateJSON struct {
+	CertificateData json.RawMessage `json:"certificate_data"`
+	Signature       string          `json:"signature"`
+	RootKeyID       string          `json:"root_key_id"`
+	RootAlgorithm   string          `json:"root_alg"`
+}
+
+// IssueCertificate 使用根密钥为签名公钥签发证书，返回Base64编码的证书
+func IssueCertificate(root Signer, data CertificateData) (string, error) {
+	if !data.NotAfter.After(data.NotBefore) {
+		return "", fmt.Errorf("证书有效期无效")
+	}
+
+	publicKey, err := ParsePublicKeyPEM(data.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	if _, err := NewVerifier(data.Algorithm, publicKey); err != nil {
+		return "", err
+	}
+	keyID, err := KeyIDFromPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	if data.KeyID != keyID {
+		return "", fmt.Errorf("密钥ID与签名公钥不一致")
+	}
+
+	rootKeyID, err := KeyIDFromPublicKey(root.Public())
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("序列化证书内容失败: %w", err)
+	}
+
+	signature, err := root.Sign(raw)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(certificateJSON{
+		CertificateData: raw,
+		Signature:       signature,
+		RootKeyID:       rootKeyID,
+		RootAlgorithm:   root.Algorithm(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("序列化证书失败: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// ParseCertificate 解析Base64编码的签名密钥证书，不验证签名
+func ParseCertificate(encoded string) (*Certificate, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("解码证书失败: %w", err)
+	}
+
+	var parsed certificateJSON
+	if err := json.Unmarshal(decoded, &parsed); err != nil {
+		return nil, fmt.Errorf("解析证书失败: %w", err)
+	}
+	if len(parsed.CertificateData) == 0 || parsed.Signature == "" {
+		return nil, fmt.Errorf("证书格式错误")
+	}
+
+	var raw bytes.Buffer
+	if err := json.Compact(&raw, parsed.CertificateData); err != nil {
+		return nil, fmt.Errorf("解析证书内容失败: %w", err)
+	}
+
+	cert := &Certificate{
+		Signature:     parsed.Signature,
+		RootKeyID:     parsed.RootKeyID,
+		RootAlgorithm: parsed.RootAlgorithm,
+		raw:           raw.Bytes(),
+	}
+	if err := json.Unmarshal(cert.raw, &cert.Data); err != nil {
+		return nil, fmt.Errorf("解析证书内容失败: %w", err)
+	}
+
+	return cert, nil
+}
+
+// Verify 使用根密钥验证证书，signedAt为被验证文件的签名时间，必须在证书有效期内
+// 以签名时间而非当前时间判断，签名密钥证书过期后，有效期内签名的授权文件仍然有效
+func (c *Certificate) Verify(root Verifier, signedAt time.Time) (Verifier, error) {
+	if c.RootAlgorithm != root.Algorithm() {
+		return nil, fmt.Errorf("证书的根密钥算法%s与可信根密钥的算法%s不一致", c.RootAlgorithm, root.Algorithm())
+	}
+	if err := root.Verify(c.raw, c.Signature); err != nil {
+		return nil, fmt.Errorf("证书签名无效: %w", err)
+	}
+
+	if signedAt.Before(c.Data.NotBefore) || signedAt.After(c.Data.NotAfter) {
+		return nil, fmt.Errorf("签发时间不在签名密钥证书有效期内")
+	}
+
+	publicKey, err := ParsePublicKeyPEM(c.Data.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := KeyIDFromPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if keyID != c.Data.KeyID {
+		return nil, fmt.Errorf("证书中的密钥ID与签名公钥不一致")
+	}
+
+	return NewVerifier(c.Data.Algorithm, publicKey)
+}
@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// x25519KeySize X25519公钥长度
+const x25519KeySize = 32
+
+// clientKeyInfo HKDF派生客户端信封AES密钥时使用的上下文信息
+const clientKeyInfo = "LicenseCenter:envelope:v2"
+
+// GenerateClientKeyPair 生成客户端X25519密钥对，公钥写入.bind文件，私钥保存在本机用于解密授权文件
+func GenerateClientKeyPair() (*ecdh.PrivateKey, error) {
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成X25519密钥失败: %w", err)
+	}
+
+	return privateKey, nil
+}
+
+// EncodeClientPublicKey 将客户端公钥编码为Base64
+func EncodeClientPublicKey(publicKey *ecdh.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(publicKey.Bytes())
+}
+
+// ParseClientPublicKey 解析Base64编码的客户端X25519公钥
+func ParseClientPublicKey(encoded string) (*ecdh.PublicKey, error) {
+	keyBytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("解码客户端公钥失败: %w", err)
+	}
+
+	publicKey, err := ecdh.X25519().NewPublicKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析客户端公钥失败: %w", err)
+	}
+
+	return publicKey, nil
+}
+
+// EncodeClientPrivateKey 将客户端私钥编码为Base64，便于保存到本机
+func EncodeClientPrivateKey(privateKey *ecdh.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(privateKey.Bytes())
+}
+
+// ParseClientPrivateKey 解析Base64编码的客户端X25519私钥
+func ParseClientPrivateKey(encoded string) (*ecdh.PrivateKey, error) {
+	keyBytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("解码客户端私钥失败: %w", err)
+	}
+
+	privateKey, err := ecdh.X25519().NewPrivateKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析客户端私钥失败: %w", err)
+	}
+
+	return privateKey, nil
+}
+
+// SealToClient 使用客户端公钥加密数据，生成X25519算法套件的v3信封
+// 服务端每次生成临时X25519密钥，与客户端公钥协商出AES密钥，只有持有客户端私钥的设备能解密
+func SealToClient(clientPublicKey *ecdh.PublicKey, fileType FileType, data []byte) ([]byte, error) {
+	ephemeralKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成临时X25519密钥失败: %w", err)
+	}
+
+	aesKey, err := deriveClientAESKey(ephemeralKey, clientPublicKey, ephemeralKey.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := &Envelope{
+		Version:            EnvelopeVersionTyped,
+		FileType:           fileType,
+		Suite:              SuiteX25519HKDFAESGCM,
+		EphemeralPublicKey: ephemeralKey.PublicKey().Bytes(),
+	}
+
+	envelope.EncryptedData, err = aesGCMEncryptWithAD(data, aesKey, envelope.header())
+	if err != nil {
+		return nil, fmt.Errorf("AES加密数据失败: %w", err)
+	}
+
+	return envelope.Marshal(), nil
+}
+
+// SealToClientBase64 使用Base64编码的客户端公钥加密数据，并将信封转换为Base64
+func SealToClientBase64(clientPublicKey string, fileType FileType, data []byte) (string, error) {
+	publicKey, err := ParseClientPublicKey(clientPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := SealToClient(publicKey, fileType, data)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenWithClientKey 使用客户端私钥打开v2信封或X25519算法套件的v3信封
+func (e *Envelope) OpenWithClientKey(privateKey *ecdh.PrivateKey) ([]byte, error) {
+	if !e.UsesClientKey() {
+		return nil, fmt.Errorf("该信封不是加密给客户端公钥的，无法使用客户端私钥解密")
+	}
+
+	ephemeralPublicKey, err := ecdh.X25519().NewPublicKey(e.EphemeralPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析临时公钥失败: %w", err)
+	}
+
+	aesKey, err := deriveClientAESKey(privateKey, ephemeralPublicKey, ephemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := aesGCMDecryptWithAD(e.EncryptedData, aesKey, e.associatedData())
+	if err != nil {
+		return nil, fmt.Errorf("AES解密数据失败: %w", err)
+	}
+
+	return jsonData, nil
+}
+
+// deriveClientAESKey 通过ECDH协商共享密钥，再用HKDF-SHA256派生AES-256密钥
+// 盐值为服务端临时公钥，双方计算结果一致
+func deriveClientAESKey(privateKey *ecdh.PrivateKey, peerPublicKey, ephemeralPublicKey *ecdh.PublicKey) ([]byte, error) {
+	sharedSecret, err := privateKey.ECDH(peerPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("X25519密钥协商失败: %w", err)
+	}
+
+	aesKey := make([]byte, 32)
+	reader := hkdf.New(sha256.New, sharedSecret, ephemeralPublicKey.Bytes(), []byte(clientKeyInfo))
+	if _, err := io.ReadFull(reader, aesKey); err != nil {
+		return nil, fmt.Errorf("派生AES密钥失败: %w", err)
+	}
+
+	return aesKey, nil
+}
@@ -0,0 +1,347 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// envelopeMagic 信封格式的魔数，旧版文件没有任何头部，以4字节密钥长度开头
+var envelopeMagic = []byte("LCE")
+
+// 信封版本
+const (
+	EnvelopeVersionKeyID  byte = 1 // 带密钥ID的信封
+	EnvelopeVersionX25519 byte = 2 // 加密给客户端X25519公钥的信封
+	EnvelopeVersionTyped  byte = 3 // 自描述信封：带文件类型和算法套件，头部作为AES-GCM附加认证数据
+)
+
+// FileType 加密文件类型，写入v3信封头部，防止不同类型的文件互相替换
+type FileType byte
+
+// 文件类型
+const (
+	FileTypeUnknown FileType = 0 // 旧版信封没有文件类型
+	FileTypeBind    FileType = 1
+	FileTypeUnbind  FileType = 2
+	FileTypeLicense FileType = 3
+	FileTypeRenewal FileType = 4
+)
+
+// String 返回文件类型名称
+func (t FileType) String() string {
+	switch t {
+	case FileTypeBind:
+		return "bind"
+	case FileTypeUnbind:
+		return "unbind"
+	case FileTypeLicense:
+		return "license"
+	case FileTypeRenewal:
+		return "renew"
+	default:
+		return "unknown"
+	}
+}
+
+// 算法套件
+const (
+	SuiteRSAOAEPAESGCM    byte = 1 // RSA-OAEP(SHA-256)加密AES-256密钥，AES-256-GCM加密数据
+	SuiteX25519HKDFAESGCM byte = 2 // X25519密钥协商，HKDF-SHA256派生AES-256密钥，AES-256-GCM加密数据
+)
+
+// Envelope 混合加密文件信封
+//
+// v1格式：["LCE"][1字节版本][1字节密钥ID长度][密钥ID][4字节AES密钥长度][RSA加密的AES密钥][AES-GCM加密的数据]
+// v2格式：["LCE"][1字节版本][32字节服务端临时X25519公钥][AES-GCM加密的数据]，AES密钥由ECDH协商得出
+// v3格式：["LCE"][1字节版本][1字节文件类型][1字节算法套件][1字节密钥ID长度][密钥ID][密钥材料][AES-GCM加密的数据]
+// 密钥材料按算法套件区分：RSA为[2字节长度][RSA加密的AES密钥]，X25519为32字节服务端临时公钥；
+// v3在AES-GCM加密时将整个头部作为附加认证数据，篡改文件类型或算法标识都会导致解密失败
+// 旧版格式没有头部：[4字节AES密钥长度][RSA加密的AES密钥][AES-GCM加密的数据]，解析后KeyID为空
+type Envelope struct {
+	Version            byte     // 信封版本，旧版格式为0
+	FileType           FileType // v3：文件类型
+	Suite              byte     // v3：算法套件
+	KeyID              string   // 加密AES密钥所用服务端公钥的ID
+	EncryptedAESKey    []byte   // RSA加密的AES密钥
+	EphemeralPublicKey []byte   // v2/v3：服务端临时X25519公钥
+	EncryptedData      []byte   // AES加密的JSON数据
+}
+
+// KeyIDFromPublicKey 根据公钥计算密钥ID（PKIX DER编码的SHA-256前8字节），支持RSA、ECDSA和Ed25519公钥
+func KeyIDFromPublicKey(publicKey crypto.PublicKey) (string, error) {
+	if rsaKey, ok := publicKey.(*rsa.PublicKey); publicKey == nil || (ok && (rsaKey == nil || rsaKey.N == nil)) {
+		return "", fmt.Errorf("无效的公钥")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("序列化公钥失败: %w", err)
+	}
+
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:8]), nil
+}
+
+// Marshal 序列化信封
+func (e *Envelope) Marshal() []byte {
+	var buf bytes.Buffer
+
+	switch e.Version {
+	case EnvelopeVersionTyped:
+		buf.Write(e.header())
+		buf.Write(e.EncryptedData)
+		return buf.Bytes()
+
+	case EnvelopeVersionX25519:
+		buf.Write(envelopeMagic)
+		buf.WriteByte(EnvelopeVersionX25519)
+		buf.Write(e.EphemeralPublicKey)
+		buf.Write(e.EncryptedData)
+		return buf.Bytes()
+	}
+
+	// 没有密钥ID时按旧版格式输出，保证与旧客户端兼容
+	if e.KeyID != "" {
+		buf.Write(envelopeMagic)
+		buf.WriteByte(EnvelopeVersionKeyID)
+		buf.WriteByte(byte(len(e.KeyID)))
+		buf.WriteString(e.KeyID)
+	}
+
+	keyLen := make([]byte, 4)
+	binary.BigEndian.PutUint32(keyLen, uint32(len(e.EncryptedAESKey)))
+	buf.Write(keyLen)
+	buf.Write(e.EncryptedAESKey)
+	buf.Write(e.EncryptedData)
+
+	return buf.Bytes()
+}
+
+// header 构造v3信封头部，同时作为AES-GCM的附加认证数据
+func (e *Envelope) header() []byte {
+	var buf bytes.Buffer
+
+	buf.Write(envelopeMagic)
+	buf.WriteByte(EnvelopeVersionTyped)
+	buf.WriteByte(byte(e.FileType))
+	buf.WriteByte(e.Suite)
+	buf.WriteByte(byte(len(e.KeyID)))
+	buf.WriteString(e.KeyID)
+
+	switch e.Suite {
+	case SuiteRSAOAEPAESGCM:
+		keyLen := make([]byte, 2)
+		binary.BigEndian.PutUint16(keyLen, uint16(len(e.EncryptedAESKey)))
+		buf.Write(keyLen)
+		buf.Write(e.EncryptedAESKey)
+	case SuiteX25519HKDFAESGCM:
+		buf.Write(e.EphemeralPublicKey)
+	}
+
+	return buf.Bytes()
+}
+
+// associatedData 返回AES-GCM的附加认证数据，v3之前的信封没有
+func (e *Envelope) associatedData() []byte {
+	if e.Version != EnvelopeVersionTyped {
+		return nil
+	}
+	return e.header()
+}
+
+// ParseEnvelope 解析信封，兼容旧版无头格式
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	envelope := &Envelope{}
+
+	if bytes.HasPrefix(data, envelopeMagic) {
+		rest := data[len(envelopeMagic):]
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("加密数据格式错误：信封头不完整")
+		}
+
+		envelope.Version = rest[0]
+		switch envelope.Version {
+		case EnvelopeVersionTyped:
+			return parseTypedEnvelope(envelope, rest[1:])
+		case EnvelopeVersionX25519:
+			rest = rest[1:]
+			if len(rest) < x25519KeySize {
+				return nil, fmt.Errorf("加密数据格式错误：临时公钥不完整")
+			}
+			envelope.EphemeralPublicKey = rest[:x25519KeySize]
+			envelope.EncryptedData = rest[x25519KeySize:]
+			return envelope, nil
+		case EnvelopeVersionKeyID:
+		default:
+			return nil, fmt.Errorf("加密数据格式错误：不支持的信封版本 %d", envelope.Version)
+		}
+
+		keyIDLen := int(rest[1])
+		rest = rest[2:]
+		if len(rest) < keyIDLen {
+			return nil, fmt.Errorf("加密数据格式错误：密钥ID不完整")
+		}
+		envelope.KeyID = string(rest[:keyIDLen])
+		data = rest[keyIDLen:]
+	}
+
+	if len(data) < 4 {
+		return nil, fmt.Errorf("加密数据格式错误：数据太短")
+	}
+
+	// 读取AES密钥长度
+	keyLen := binary.BigEndian.Uint32(data[0:4])
+	if uint64(len(data)) < 4+uint64(keyLen) {
+		return nil, fmt.Errorf("加密数据格式错误：AES密钥数据不完整")
+	}
+
+	envelope.EncryptedAESKey = data[4 : 4+keyLen]
+	envelope.EncryptedData = data[4+keyLen:]
+
+	return envelope, nil
+}
+
+// parseTypedEnvelope 解析v3信封版本号之后的部分
+func parseTypedEnvelope(envelope *Envelope, rest []byte) (*Envelope, error) {
+	if len(rest) < 3 {
+		return nil, fmt.Errorf("加密数据格式错误：信封头不完整")
+	}
+
+	envelope.FileType = FileType(rest[0])
+	envelope.Suite = rest[1]
+	keyIDLen := int(rest[2])
+	rest = rest[3:]
+	if len(rest) < keyIDLen {
+		return nil, fmt.Errorf("加密数据格式错误：密钥ID不完整")
+	}
+	envelope.KeyID = string(rest[:keyIDLen])
+	rest = rest[keyIDLen:]
+
+	switch envelope.Suite {
+	case SuiteRSAOAEPAESGCM:
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("加密数据格式错误：数据太短")
+		}
+		keyLen := int(binary.BigEndian.Uint16(rest[0:2]))
+		if len(rest) < 2+keyLen {
+			return nil, fmt.Errorf("加密数据格式错误：AES密钥数据不完整")
+		}
+		envelope.EncryptedAESKey = rest[2 : 2+keyLen]
+		envelope.EncryptedData = rest[2+keyLen:]
+	case SuiteX25519HKDFAESGCM:
+		if len(rest) < x25519KeySize {
+			return nil, fmt.Errorf("加密数据格式错误：临时公钥不完整")
+		}
+		envelope.EphemeralPublicKey = rest[:x25519KeySize]
+		envelope.EncryptedData = rest[x25519KeySize:]
+	default:
+		return nil, fmt.Errorf("加密数据格式错误：不支持的算法套件 %d", envelope.Suite)
+	}
+
+	return envelope, nil
+}
+
+// CheckFileType 检查v3信封的文件类型，旧版信封没有文件类型，不做检查；expected为FileTypeUnknown时不限制类型
+func (e *Envelope) CheckFileType(expected FileType) error {
+	if expected == FileTypeUnknown || e.Version != EnvelopeVersionTyped {
+		return nil
+	}
+	if e.FileType != expected {
+		return fmt.Errorf("文件类型不匹配：期望%s文件，实际为%s文件", expected, e.FileType)
+	}
+	return nil
+}
+
+// UsesClientKey 检查信封是否只能用客户端X25519私钥打开（v2信封和X25519算法套件的v3信封）
+func (e *Envelope) UsesClientKey() bool {
+	return e.Version == EnvelopeVersionX25519 ||
+		(e.Version == EnvelopeVersionTyped && e.Suite == SuiteX25519HKDFAESGCM)
+}
+
+// Open 使用RSA私钥打开信封，返回明文数据和AES密钥
+func (e *Envelope) Open(privateKey *rsa.PrivateKey) ([]byte, []byte, error) {
+	if e.UsesClientKey() {
+		return nil, nil, fmt.Errorf("该信封只能使用客户端私钥解密")
+	}
+
+	// 使用RSA-OAEP解密AES密钥
+	aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, e.EncryptedAESKey, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("RSA解密AES密钥失败: %w", err)
+	}
+
+	// 使用AES-GCM解密数据
+	jsonData, err := aesGCMDecryptWithAD(e.EncryptedData, aesKey, e.associatedData())
+	if err != nil {
+		return nil, nil, fmt.Errorf("AES解密数据失败: %w", err)
+	}
+
+	return jsonData, aesKey, nil
+}
+
+// OpenWithAESKey 使用已知的AES密钥打开信封（客户端解密授权文件时使用）
+func (e *Envelope) OpenWithAESKey(aesKey []byte) ([]byte, error) {
+	if e.UsesClientKey() {
+		return nil, fmt.Errorf("该信封只能使用客户端私钥解密")
+	}
+
+	jsonData, err := aesGCMDecryptWithAD(e.EncryptedData, aesKey, e.associatedData())
+	if err != nil {
+		return nil, fmt.Errorf("AES解密数据失败: %w", err)
+	}
+
+	return jsonData, nil
+}
+
+// SealFile 使用服务端RSA公钥生成v3信封，aesKey为空时随机生成
+func SealFile(publicKey *rsa.PublicKey, fileType FileType, data []byte, aesKey []byte) ([]byte, error) {
+	if aesKey == nil {
+		aesKey = make([]byte, 32)
+		if _, err := rand.Read(aesKey); err != nil {
+			return nil, fmt.Errorf("生成AES密钥失败: %w", err)
+		}
+	}
+
+	keyID, err := KeyIDFromPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedAESKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, aesKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("RSA加密AES密钥失败: %w", err)
+	}
+
+	envelope := &Envelope{
+		Version:         EnvelopeVersionTyped,
+		FileType:        fileType,
+		Suite:           SuiteRSAOAEPAESGCM,
+		KeyID:           keyID,
+		EncryptedAESKey: encryptedAESKey,
+	}
+
+	envelope.EncryptedData, err = aesGCMEncryptWithAD(data, aesKey, envelope.header())
+	if err != nil {
+		return nil, fmt.Errorf("AES加密数据失败: %w", err)
+	}
+
+	return envelope.Marshal(), nil
+}
+
+// SealFileToBase64 使用服务端RSA公钥生成v3信封并转换为Base64
+func SealFileToBase64(publicKey *rsa.PublicKey, fileType FileType, data []byte, aesKey []byte) (string, error) {
+	sealed, err := SealFile(publicKey, fileType, data, aesKey)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
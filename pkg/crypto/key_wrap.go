@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// SealedKeyPrefix 主密钥加密后的私钥前缀，格式为 enc:v1:<主密钥ID>:<加密的数据密钥>:<加密的私钥>
+const SealedKeyPrefix = "enc:v1:"
+
+// MasterKeySize 本地主密钥长度（AES-256）
+const MasterKeySize = 32
+
+// KeyWrapper 主密钥提供者，负责加解密保护私钥的数据密钥
+// 本地主密钥由LocalKeyWrapper实现，接入KMS时实现该接口即可，主密钥不离开KMS
+type KeyWrapper interface {
+	KeyID() string                            // 主密钥ID，记录在密文中，用于选择解密的主密钥
+	WrapKey(dataKey []byte) ([]byte, error)   // 加密数据密钥
+	UnwrapKey(wrapped []byte) ([]byte, error) // 解密数据密钥
+}
+
+// LocalKeyWrapper 使用本地AES-256主密钥加密数据密钥
+type LocalKeyWrapper struct {
+	keyID string
+	key   []byte
+}
+
+// NewLocalKeyWrapper 创建本地主密钥提供者，主密钥必须为32字节
+func NewLocalKeyWrapper(masterKey []byte) (*LocalKeyWrapper, error) {
+	if len(masterKey) != MasterKeySize {
+		return nil, fmt.Errorf("主密钥长度必须为%d字节", MasterKeySize)
+	}
+
+	sum := sha256.Sum256(masterKey)
+	key := make([]byte, MasterKeySize)
+	copy(key, masterKey)
+
+	return &LocalKeyWrapper{keyID: "local-" + hex.EncodeToString(sum[:8]), key: key}, nil
+}
+
+// ParseMasterKey 解析Base64编码的主密钥（来自文件或环境变量）
+func ParseMasterKey(encoded string) ([]byte, error) {
+	masterKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("解码主密钥失败: %w", err)
+	}
+	if len(masterKey) != MasterKeySize {
+		return nil, fmt.Errorf("主密钥长度必须为%d字节", MasterKeySize)
+	}
+
+	return masterKey, nil
+}
+
+// GenerateMasterKey 生成Base64编码的随机主密钥
+func GenerateMasterKey() (string, error) {
+	masterKey := make([]byte, MasterKeySize)
+	if _, err := rand.Read(masterKey); err != nil {
+		return "", fmt.Errorf("生成主密钥失败: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(masterKey), nil
+}
+
+// KeyID 返回主密钥ID（主密钥SHA-256摘要的前8字节）
+func (w *LocalKeyWrapper) KeyID() string {
+	return w.keyID
+}
+
+// WrapKey 使用主密钥加密数据密钥
+func (w *LocalKeyWrapper) WrapKey(dataKey []byte) ([]byte, error) {
+	return aesGCMEncryptWithAD(dataKey, w.key, []byte(w.keyID))
+}
+
+// UnwrapKey 使用主密钥解密数据密钥
+func (w *LocalKeyWrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	return aesGCMDecryptWithAD(wrapped, w.key, []byte(w.keyID))
+}
+
+// IsSealedPrivateKey 检查私钥是否已用主密钥加密
+func IsSealedPrivateKey(stored string) bool {
+	return strings.HasPrefix(stored, SealedKeyPrefix)
+}
+
+// SealedKeyID 返回加密私钥所用的主密钥ID，未加密时返回空
+func SealedKeyID(stored string) string {
+	if !IsSealedPrivateKey(stored) {
+		return ""
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(stored, SealedKeyPrefix), ":", 3)
+	return parts[0]
+}
+
+// SealPrivateKey 使用信封加密保护私钥：随机数据密钥加密私钥，主密钥加密数据密钥
+func SealPrivateKey(wrapper KeyWrapper, privateKeyPEM string) (string, error) {
+	if strings.Contains(wrapper.KeyID(), ":") {
+		return "", fmt.Errorf("主密钥ID不能包含冒号")
+	}
+
+	dataKey := make([]byte, MasterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+
+	wrapped, err := wrapper.WrapKey(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("加密数据密钥失败: %w", err)
+	}
+
+	header := SealedKeyPrefix + wrapper.KeyID()
+	ciphertext, err := aesGCMEncryptWithAD([]byte(privateKeyPEM), dataKey, []byte(header))
+	if err != nil {
+		return "", fmt.Errorf("加密私钥失败: %w", err)
+	}
+
+	return header + ":" + base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// OpenPrivateKey 解密私钥，未加密的私钥原样返回
+// 按密文记录的主密钥ID从wrappers中选择主密钥，主密钥轮换期间可同时传入新旧主密钥
+func OpenPrivateKey(stored string, wrappers ...KeyWrapper) (string, error) {
+	if !IsSealedPrivateKey(stored) {
+		return stored, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(stored, SealedKeyPrefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("加密私钥格式错误")
+	}
+	keyID := parts[0]
+
+	var wrapper KeyWrapper
+	for _, w := range wrappers {
+		if w != nil && w.KeyID() == keyID {
+			wrapper = w
+			break
+		}
+	}
+	if wrapper == nil {
+		return "", fmt.Errorf("未找到主密钥: %s", keyID)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("解码数据密钥失败: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("解码加密私钥失败: %w", err)
+	}
+
+	dataKey, err := wrapper.UnwrapKey(wrapped)
+	if err != nil {
+		return "", fmt.Errorf("解密数据密钥失败: %w", err)
+	}
+
+	plaintext, err := aesGCMDecryptWithAD(ciphertext, dataKey, []byte(SealedKeyPrefix+keyID))
+	if err != nil {
+		return "", fmt.Errorf("解密私钥失败: %w", err)
+	}
+
+	return string(plaintext), nil
+}
@@ -9,7 +9,6 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/pem"
 	"fmt"
 )
@@ -144,76 +143,35 @@ func VerifySignature(publicKey *rsa.PublicKey, data []byte, signature string) er
 //   - data: 需要加密的JSON数据
 //
 // 返回：
-//   - []byte: 加密后的数据（格式：[4字节AES密钥长度][RSA加密的AES密钥][AES加密的数据]）
+//   - []byte: 加密后的数据（信封格式，见 Envelope）
 //   - error: 错误信息
 func HybridEncrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
-	// 1. 生成随机AES密钥（32字节，AES-256）
+	// 生成随机AES密钥（32字节，AES-256）
 	aesKey := make([]byte, 32)
 	if _, err := rand.Read(aesKey); err != nil {
 		return nil, fmt.Errorf("生成AES密钥失败: %w", err)
 	}
 
-	// 2. 使用AES-GCM加密数据
-	encryptedData, err := aesGCMEncrypt(data, aesKey)
-	if err != nil {
-		return nil, fmt.Errorf("AES加密数据失败: %w", err)
-	}
-
-	// 3. 使用RSA-OAEP加密AES密钥
-	encryptedAESKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, aesKey, nil)
-	if err != nil {
-		return nil, fmt.Errorf("RSA加密AES密钥失败: %w", err)
-	}
-
-	// 4. 组合数据：[4字节AES密钥长度][RSA加密的AES密钥][AES加密的数据]
-	keyLen := len(encryptedAESKey)
-	result := make([]byte, 4+keyLen+len(encryptedData))
-
-	// 写入AES密钥长度（大端序）
-	binary.BigEndian.PutUint32(result[0:4], uint32(keyLen))
-
-	// 写入加密的AES密钥
-	copy(result[4:4+keyLen], encryptedAESKey)
-
-	// 写入加密的数据
-	copy(result[4+keyLen:], encryptedData)
-
-	return result, nil
+	return HybridEncryptWithClientKey(publicKey, data, aesKey)
 }
 
 // HybridDecrypt 混合解密：使用RSA解密AES密钥，使用AES解密数据
 // 参数：
 //   - privateKey: RSA私钥，用于解密AES密钥
-//   - encryptedData: 加密的数据
+//   - encryptedData: 加密的数据（支持v3自描述信封、带密钥ID的信封和旧版无头格式）
 //
 // 返回：
 //   - []byte: 解密后的JSON数据
 //   - error: 错误信息
 func HybridDecrypt(privateKey *rsa.PrivateKey, encryptedData []byte) ([]byte, error) {
-	if len(encryptedData) < 4 {
-		return nil, fmt.Errorf("加密数据格式错误：数据太短")
-	}
-
-	// 1. 解析数据格式：读取AES密钥长度
-	keyLen := binary.BigEndian.Uint32(encryptedData[0:4])
-	if len(encryptedData) < int(4+keyLen) {
-		return nil, fmt.Errorf("加密数据格式错误：AES密钥数据不完整")
-	}
-
-	// 2. 提取RSA加密的AES密钥和AES加密的数据
-	encryptedAESKey := encryptedData[4 : 4+keyLen]
-	aesEncryptedData := encryptedData[4+keyLen:]
-
-	// 3. 使用RSA-OAEP解密AES密钥
-	aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, encryptedAESKey, nil)
+	envelope, err := ParseEnvelope(encryptedData)
 	if err != nil {
-		return nil, fmt.Errorf("RSA解密AES密钥失败: %w", err)
+		return nil, err
 	}
 
-	// 4. 使用AES-GCM解密数据
-	jsonData, err := aesGCMDecrypt(aesEncryptedData, aesKey)
+	jsonData, _, err := envelope.Open(privateKey)
 	if err != nil {
-		return nil, fmt.Errorf("AES解密数据失败: %w", err)
+		return nil, err
 	}
 
 	return jsonData, nil
@@ -221,6 +179,11 @@ func HybridDecrypt(privateKey *rsa.PrivateKey, encryptedData []byte) ([]byte, er
 
 // aesGCMEncrypt 使用AES-GCM加密数据
 func aesGCMEncrypt(data []byte, key []byte) ([]byte, error) {
+	return aesGCMEncryptWithAD(data, key, nil)
+}
+
+// aesGCMEncryptWithAD 使用AES-GCM加密数据，additionalData参与认证但不加密
+func aesGCMEncryptWithAD(data []byte, key []byte, additionalData []byte) ([]byte, error) {
 	// 创建AES cipher
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -240,13 +203,18 @@ func aesGCMEncrypt(data []byte, key []byte) ([]byte, error) {
 	}
 
 	// 加密数据（nonce会被自动添加到密文前面）
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
+	ciphertext := gcm.Seal(nonce, nonce, data, additionalData)
 
 	return ciphertext, nil
 }
 
 // aesGCMDecrypt 使用AES-GCM解密数据
 func aesGCMDecrypt(encryptedData []byte, key []byte) ([]byte, error) {
+	return aesGCMDecryptWithAD(encryptedData, key, nil)
+}
+
+// aesGCMDecryptWithAD 使用AES-GCM解密数据，additionalData必须与加密时一致
+func aesGCMDecryptWithAD(encryptedData []byte, key []byte, additionalData []byte) ([]byte, error) {
 	// 创建AES cipher
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -269,7 +237,7 @@ func aesGCMDecrypt(encryptedData []byte, key []byte) ([]byte, error) {
 	nonce, ciphertext := encryptedData[:nonceSize], encryptedData[nonceSize:]
 
 	// 解密数据
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, fmt.Errorf("GCM解密失败: %w", err)
 	}
@@ -297,6 +265,29 @@ func DecryptFileFromBase64(privateKey *rsa.PrivateKey, base64Data string) ([]byt
 	return HybridDecrypt(privateKey, encryptedData)
 }
 
+// DecryptTypedFileFromBase64 从Base64字符串解密得到JSON数据，v3信封的文件类型必须与fileType一致
+func DecryptTypedFileFromBase64(privateKey *rsa.PrivateKey, fileType FileType, base64Data string) ([]byte, error) {
+	encryptedData, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return nil, fmt.Errorf("Base64解码失败: %w", err)
+	}
+
+	envelope, err := ParseEnvelope(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	if err := envelope.CheckFileType(fileType); err != nil {
+		return nil, err
+	}
+
+	jsonData, _, err := envelope.Open(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonData, nil
+}
+
 // AESGCMEncrypt 使用AES-GCM加密数据（导出版本）
 func AESGCMEncrypt(data []byte, key []byte) ([]byte, error) {
 	return aesGCMEncrypt(data, key)
@@ -309,32 +300,31 @@ func AESGCMDecrypt(encryptedData []byte, key []byte) ([]byte, error) {
 
 // HybridEncryptWithClientKey 混合加密：使用客户端固定AES密钥
 func HybridEncryptWithClientKey(publicKey *rsa.PublicKey, data []byte, clientAESKey []byte) ([]byte, error) {
-	// 1. 使用客户端提供的AES密钥加密数据
+	// 1. 计算服务端公钥的密钥ID，写入信封以便解密时定位密钥
+	keyID, err := KeyIDFromPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 使用客户端提供的AES密钥加密数据
 	encryptedData, err := aesGCMEncrypt(data, clientAESKey)
 	if err != nil {
 		return nil, fmt.Errorf("AES加密数据失败: %w", err)
 	}
 
-	// 2. 使用RSA-OAEP加密客户端AES密钥
+	// 3. 使用RSA-OAEP加密客户端AES密钥
 	encryptedAESKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, clientAESKey, nil)
 	if err != nil {
 		return nil, fmt.Errorf("RSA加密AES密钥失败: %w", err)
 	}
 
-	// 3. 组合数据：[4字节AES密钥长度][RSA加密的AES密钥][AES加密的数据]
-	keyLen := len(encryptedAESKey)
-	result := make([]byte, 4+keyLen+len(encryptedData))
-
-	// 写入AES密钥长度（大端序）
-	binary.BigEndian.PutUint32(result[0:4], uint32(keyLen))
-
-	// 写入加密的AES密钥
-	copy(result[4:4+keyLen], encryptedAESKey)
-
-	// 写入加密的数据
-	copy(result[4+keyLen:], encryptedData)
+	envelope := &Envelope{
+		KeyID:           keyID,
+		EncryptedAESKey: encryptedAESKey,
+		EncryptedData:   encryptedData,
+	}
 
-	return result, nil
+	return envelope.Marshal(), nil
 }
 
 // EncryptFileToBase64WithClientKey 使用客户端AES密钥的混合加密并转换为Base64
@@ -0,0 +1,268 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// 授权文件签名算法（名称与JWA一致）
+const (
+	SignatureRS256 = "RS256" // RSA PKCS#1 v1.5 + SHA-256，旧版授权文件使用
+	SignaturePS256 = "PS256" // RSA-PSS + SHA-256
+	SignatureES256 = "ES256" // ECDSA P-256 + SHA-256，签名为64字节r||s
+	SignatureEdDSA = "EdDSA" // Ed25519，签名和公钥最短，适合嵌入式产品
+)
+
+// es256CoordSize P-256曲线坐标长度，ES256签名由定长的r和s拼接而成
+const es256CoordSize = 32
+
+// Signer 签名器，签名结果为Base64编码
+type Signer interface {
+	Algorithm() string
+	Public() crypto.PublicKey
+	Sign(data []byte) (string, error)
+}
+
+// Verifier 验签器，签名为Base64编码
+type Verifier interface {
+	Algorithm() string
+	Verify(data []byte, signature string) error
+}
+
+// SigningKeyPair 签名密钥对（PEM格式）
+type SigningKeyPair struct {
+	Algorithm     string
+	PrivateKeyPEM string
+	PublicKeyPEM  string
+}
+
+// IsRSAAlgorithm 检查签名算法是否使用RSA密钥，RSA密钥同时可用于加密文件信封
+func IsRSAAlgorithm(algorithm string) bool {
+	return algorithm == SignatureRS256 || algorithm == SignaturePS256
+}
+
+// IsSupportedSignatureAlgorithm 检查是否为支持的签名算法
+func IsSupportedSignatureAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case SignatureRS256, SignaturePS256, SignatureES256, SignatureEdDSA:
+		return true
+	default:
+		return false
+	}
+}
+
+// GenerateSigningKeyPair 生成指定签名算法的密钥对，RSA算法使用rsaKeySize位密钥
+func GenerateSigningKeyPair(algorithm string, rsaKeySize int) (*SigningKeyPair, error) {
+	var privateKey crypto.Signer
+	switch algorithm {
+	case SignatureRS256, SignaturePS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+		if err != nil {
+			return nil, fmt.Errorf("生成RSA私钥失败: %w", err)
+		}
+		privateKey = key
+	case SignatureES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("生成ECDSA私钥失败: %w", err)
+		}
+		privateKey = key
+	case SignatureEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("生成Ed25519私钥失败: %w", err)
+		}
+		privateKey = key
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", algorithm)
+	}
+
+	privateKeyPEM, err := marshalPrivateKeyPEM(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyPEM, err := marshalPublicKeyPEM(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKeyPair{Algorithm: algorithm, PrivateKeyPEM: privateKeyPEM, PublicKeyPEM: publicKeyPEM}, nil
+}
+
+// ParsePrivateKeyPEM 解析PKCS#8 PEM格式的私钥（RSA、ECDSA或Ed25519）
+func ParsePrivateKeyPEM(pemData string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, fmt.Errorf("无效的PEM数据")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("不支持的私钥类型")
+	}
+
+	return signer, nil
+}
+
+// ParsePublicKeyPEM 解析PKIX PEM格式的公钥（RSA、ECDSA或Ed25519）
+func ParsePublicKeyPEM(pemData string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, fmt.Errorf("无效的PEM数据")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析公钥失败: %w", err)
+	}
+
+	return publicKey, nil
+}
+
+// NewSigner 创建指定算法的签名器，私钥类型必须与算法一致
+func NewSigner(algorithm string, privateKey crypto.Signer) (Signer, error) {
+	if err := checkKeyAlgorithm(algorithm, privateKey.Public()); err != nil {
+		return nil, err
+	}
+
+	return &keySigner{algorithm: algorithm, key: privateKey}, nil
+}
+
+// NewVerifier 创建指定算法的验签器，公钥类型必须与算法一致
+func NewVerifier(algorithm string, publicKey crypto.PublicKey) (Verifier, error) {
+	if err := checkKeyAlgorithm(algorithm, publicKey); err != nil {
+		return nil, err
+	}
+
+	return &keyVerifier{algorithm: algorithm, key: publicKey}, nil
+}
+
+// checkKeyAlgorithm 检查密钥类型与签名算法是否匹配，防止用错误的算法解释签名
+func checkKeyAlgorithm(algorithm string, publicKey crypto.PublicKey) error {
+	var ok bool
+	switch algorithm {
+	case SignatureRS256, SignaturePS256:
+		_, ok = publicKey.(*rsa.PublicKey)
+	case SignatureES256:
+		var key *ecdsa.PublicKey
+		key, ok = publicKey.(*ecdsa.PublicKey)
+		ok = ok && key.Curve == elliptic.P256()
+	case SignatureEdDSA:
+		_, ok = publicKey.(ed25519.PublicKey)
+	default:
+		return fmt.Errorf("不支持的签名算法: %s", algorithm)
+	}
+
+	if !ok {
+		return fmt.Errorf("密钥类型与签名算法%s不匹配", algorithm)
+	}
+	return nil
+}
+
+// keySigner 基于标准库crypto.Signer的签名器
+type keySigner struct {
+	algorithm string
+	key       crypto.Signer
+}
+
+// Algorithm 返回签名算法
+func (s *keySigner) Algorithm() string {
+	return s.algorithm
+}
+
+// Public 返回签名公钥
+func (s *keySigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+// Sign 签名数据
+func (s *keySigner) Sign(data []byte) (string, error) {
+	var signature []byte
+	var err error
+
+	switch s.algorithm {
+	case SignatureRS256:
+		hash := sha256.Sum256(data)
+		signature, err = s.key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	case SignaturePS256:
+		hash := sha256.Sum256(data)
+		signature, err = s.key.Sign(rand.Reader, hash[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	case SignatureES256:
+		hash := sha256.Sum256(data)
+		var r, sv *big.Int
+		r, sv, err = ecdsa.Sign(rand.Reader, s.key.(*ecdsa.PrivateKey), hash[:])
+		if err == nil {
+			signature = make([]byte, 2*es256CoordSize)
+			r.FillBytes(signature[:es256CoordSize])
+			sv.FillBytes(signature[es256CoordSize:])
+		}
+	case SignatureEdDSA:
+		signature, err = s.key.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s签名失败: %w", s.algorithm, err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// keyVerifier 按算法验证签名
+type keyVerifier struct {
+	algorithm string
+	key       crypto.PublicKey
+}
+
+// Algorithm 返回签名算法
+func (v *keyVerifier) Algorithm() string {
+	return v.algorithm
+}
+
+// Verify 验证签名
+func (v *keyVerifier) Verify(data []byte, signature string) error {
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("解码签名失败: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	switch v.algorithm {
+	case SignatureRS256:
+		err = rsa.VerifyPKCS1v15(v.key.(*rsa.PublicKey), crypto.SHA256, hash[:], signatureBytes)
+	case SignaturePS256:
+		err = rsa.VerifyPSS(v.key.(*rsa.PublicKey), crypto.SHA256, hash[:], signatureBytes,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	case SignatureES256:
+		if len(signatureBytes) != 2*es256CoordSize {
+			return fmt.Errorf("签名验证失败: ES256签名长度错误")
+		}
+		r := new(big.Int).SetBytes(signatureBytes[:es256CoordSize])
+		s := new(big.Int).SetBytes(signatureBytes[es256CoordSize:])
+		if !ecdsa.Verify(v.key.(*ecdsa.PublicKey), hash[:], r, s) {
+			err = fmt.Errorf("ECDSA签名不匹配")
+		}
+	case SignatureEdDSA:
+		if !ed25519.Verify(v.key.(ed25519.PublicKey), data, signatureBytes) {
+			err = fmt.Errorf("Ed25519签名不匹配")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("签名验证失败: %w", err)
+	}
+
+	return nil
+}
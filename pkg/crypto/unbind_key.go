@@ -0,0 +1,143 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+)
+
+// 一次性解绑密钥类型
+const (
+	UnbindKeyTypeRSA     = "rsa"     // RSA-2048，PKCS#1 v1.5签名，旧版授权使用
+	UnbindKeyTypeEd25519 = "ed25519" // Ed25519，生成速度远快于RSA
+)
+
+// UnbindKeyPair 一次性解绑密钥对（PEM格式）
+type UnbindKeyPair struct {
+	Type          string
+	PrivateKeyPEM string
+	PublicKeyPEM  string
+}
+
+// GenerateUnbindKeyPair 生成指定类型的一次性解绑密钥对，类型为空时使用RSA
+func GenerateUnbindKeyPair(keyType string) (*UnbindKeyPair, error) {
+	switch keyType {
+	case "", UnbindKeyTypeRSA:
+		keyPair, err := GenerateRSAKeyPair(2048)
+		if err != nil {
+			return nil, err
+		}
+		privateKeyPEM, err := keyPair.PrivateKeyToPEM()
+		if err != nil {
+			return nil, err
+		}
+		publicKeyPEM, err := keyPair.PublicKeyToPEM()
+		if err != nil {
+			return nil, err
+		}
+		return &UnbindKeyPair{Type: UnbindKeyTypeRSA, PrivateKeyPEM: privateKeyPEM, PublicKeyPEM: publicKeyPEM}, nil
+
+	case UnbindKeyTypeEd25519:
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("生成Ed25519私钥失败: %w", err)
+		}
+		privateKeyPEM, err := marshalPrivateKeyPEM(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		publicKeyPEM, err := marshalPublicKeyPEM(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return &UnbindKeyPair{Type: UnbindKeyTypeEd25519, PrivateKeyPEM: privateKeyPEM, PublicKeyPEM: publicKeyPEM}, nil
+
+	default:
+		return nil, fmt.Errorf("不支持的解绑密钥类型: %s", keyType)
+	}
+}
+
+// SignUnbindProof 使用一次性解绑私钥签名，根据PEM中的密钥类型选择签名算法
+func SignUnbindProof(privateKeyPEM string, data []byte) (string, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return "", fmt.Errorf("无效的PEM数据")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("解析私钥失败: %w", err)
+	}
+
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return SignData(key, data)
+	case ed25519.PrivateKey:
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)), nil
+	default:
+		return "", fmt.Errorf("不支持的解绑私钥类型")
+	}
+}
+
+// VerifyUnbindProof 使用一次性解绑公钥验证签名，公钥类型必须与授权记录的密钥类型一致（为空时视为RSA）
+func VerifyUnbindProof(keyType, publicKeyPEM string, data []byte, signature string) error {
+	if keyType == "" {
+		keyType = UnbindKeyTypeRSA
+	}
+
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return fmt.Errorf("无效的PEM数据")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("解析公钥失败: %w", err)
+	}
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if keyType != UnbindKeyTypeRSA {
+			return fmt.Errorf("解绑公钥类型与记录不一致")
+		}
+		return VerifySignature(key, data, signature)
+	case ed25519.PublicKey:
+		if keyType != UnbindKeyTypeEd25519 {
+			return fmt.Errorf("解绑公钥类型与记录不一致")
+		}
+		signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+		if err != nil {
+			return fmt.Errorf("解码签名失败: %w", err)
+		}
+		if !ed25519.Verify(key, data, signatureBytes) {
+			return fmt.Errorf("签名验证失败")
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的解绑公钥类型")
+	}
+}
+
+// marshalPrivateKeyPEM 将私钥编码为PKCS#8 PEM
+func marshalPrivateKeyPEM(privateKey interface{}) (string, error) {
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("序列化私钥失败: %w", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes})), nil
+}
+
+// marshalPublicKeyPEM 将公钥编码为PKIX PEM
+func marshalPublicKeyPEM(publicKey interface{}) (string, error) {
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("序列化公钥失败: %w", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes})), nil
+}
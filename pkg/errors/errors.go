@@ -68,13 +68,21 @@ var (
 	ErrTOTPKeyNotSet      = NewAppError(40010, "TOTP密钥未设置")
 
 	// 业务逻辑错误 (40xxx) - 应该返回400 Bad Request
-	ErrAuthCodeDisabled  = NewAppError(40011, "授权码已被禁用")
-	ErrInvalidBindFile   = NewAppError(40012, "无效的绑定文件")
-	ErrInvalidUnbindFile = NewAppError(40013, "无效的解绑文件")
-	ErrInvalidSignature  = NewAppError(40014, "签名验证失败")
-	ErrInsufficientSeats = NewAppError(40015, "可用席位不足")
-	ErrDuplicateMachine  = NewAppError(40016, "设备已被激活")
-	ErrLicenseNotFound   = NewAppError(40017, "授权记录不存在")
+	ErrAuthCodeDisabled   = NewAppError(40011, "授权码已被禁用")
+	ErrInvalidBindFile    = NewAppError(40012, "无效的绑定文件")
+	ErrInvalidUnbindFile  = NewAppError(40013, "无效的解绑文件")
+	ErrInvalidSignature   = NewAppError(40014, "签名验证失败")
+	ErrInsufficientSeats  = NewAppError(40015, "可用席位不足")
+	ErrDuplicateMachine   = NewAppError(40016, "设备已被激活")
+	ErrLicenseNotFound    = NewAppError(40017, "授权记录不存在")
+	ErrRetireActiveKey    = NewAppError(40018, "不能退役当前活跃密钥，请先轮换密钥")
+	ErrTrialAlreadyUsed   = NewAppError(40019, "该设备已申请过试用授权")
+	ErrNotTrialLicense    = NewAppError(40030, "仅有效的试用授权可以转为正式授权")
+	ErrTrialNotAllowed    = NewAppError(40031, "试用授权不支持此操作")
+	ErrTransferLimited    = NewAppError(40032, "设备转移次数已达上限")
+	ErrTransferCooldown   = NewAppError(40033, "距上次转移时间过短，暂不能转移")
+	ErrJobNotFinished     = NewAppError(40034, "激活任务尚未完成")
+	ErrInvalidCertificate = NewAppError(40035, "签名密钥证书无效")
 
 	// 验证码相关错误 (402xx)
 	ErrCaptchaFallbackInProduction = NewAppError(40020, "生产环境不允许使用降级验证码")
@@ -86,6 +94,8 @@ var (
 
 	// 资源不存在错误 (43xxx)
 	ErrAuthCodeNotFound = NewAppError(43001, "授权码不存在")
+	ErrKeyNotFound      = NewAppError(43002, "密钥不存在")
+	ErrJobNotFound      = NewAppError(43003, "激活任务不存在")
 
 	// 加密相关错误 (50xxx)
 	ErrCryptoError = NewAppError(50001, "加密操作失败")
@@ -3,60 +3,30 @@ package main
 import (
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/lyenrowe/LicenseCenter/pkg/client"
 	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
 	"github.com/lyenrowe/LicenseCenter/pkg/utils"
 )
 
-// BindFile 绑定请求文件结构
-type BindFile struct {
-	Hostname    string    `json:"hostname"`
-	MachineID   string    `json:"machine_id"`
-	RequestTime time.Time `json:"request_time"`
-}
-
-// LicenseFile 授权文件结构
-type LicenseFile struct {
-	LicenseData LicenseData `json:"license_data"`
-	Signature   string      `json:"signature"`
-}
-
-// LicenseData 授权数据结构
-type LicenseData struct {
-	LicenseKey       string    `json:"license_key"`
-	MachineID        string    `json:"machine_id"`
-	Hostname         string    `json:"hostname"`
-	IssuedAt         time.Time `json:"issued_at"`
-	ExpiresAt        time.Time `json:"expires_at"`
-	LicenseType      string    `json:"license_type"`
-	UnbindPrivateKey string    `json:"unbind_private_key"`
-}
-
-// UnbindFile 解绑文件结构
-type UnbindFile struct {
-	LicenseKey     string         `json:"license_key"`
-	MachineID      string         `json:"machine_id"`
-	UnbindMetadata UnbindMetadata `json:"unbind_metadata"`
-	UnbindProof    string         `json:"unbind_proof"`
-}
-
-// UnbindMetadata 解绑元数据
-type UnbindMetadata struct {
-	UnbindTime    time.Time `json:"unbind_time"`
-	Hostname      string    `json:"hostname"`
-	ClientVersion string    `json:"client_version"`
-	UnbindReason  string    `json:"unbind_reason"`
-}
+// 文件结构统一使用客户端SDK中的定义
+type (
+	BindFile    = client.BindFile
+	LicenseFile = client.LicenseFile
+)
 
 // PublicKeyResponse 公钥响应
 type PublicKeyResponse struct {
 	PublicKey string `json:"public_key"`
+	KeyID     string `json:"key_id"`
 }
 
 func main() {
@@ -67,7 +37,7 @@ func main() {
 		fmt.Println("  generate-bind-encrypted [server_url] - 生成加密绑定请求文件")
 		fmt.Println("  show-machine          - 显示当前机器信息")
 		fmt.Println("  decrypt-license <file> - 解密授权文件")
-		fmt.Println("  verify-license <file>  - 验证授权文件")
+		fmt.Println("  verify-license <file> [server_url] [renew_file] - 验证授权文件（从服务器获取验签公钥，可附带续期文件）")
 		fmt.Println("  generate-unbind <license_file> - 生成解绑文件")
 		return
 	}
@@ -96,7 +66,15 @@ func main() {
 			fmt.Println("请提供授权文件路径")
 			return
 		}
-		verifyLicenseFile(os.Args[2])
+		serverURL := "http://localhost:8080"
+		if len(os.Args) > 3 {
+			serverURL = os.Args[3]
+		}
+		renewalPath := ""
+		if len(os.Args) > 4 {
+			renewalPath = os.Args[4]
+		}
+		verifyLicenseFile(os.Args[2], serverURL, renewalPath)
 	case "generate-unbind":
 		if len(os.Args) < 3 {
 			fmt.Println("请提供授权文件路径")
@@ -158,7 +136,7 @@ func generateEncryptedBindFile(serverURL string) {
 	fmt.Printf("🔄 正在从服务器获取公钥: %s\n", serverURL)
 
 	// 1. 从服务器获取公钥
-	publicKey, err := getServerPublicKey(serverURL)
+	publicKey, _, err := getServerPublicKey(serverURL)
 	if err != nil {
 		fmt.Printf("❌ 获取服务器公钥失败: %v\n", err)
 		return
@@ -215,107 +193,157 @@ func generateEncryptedBindFile(serverURL string) {
 	fmt.Printf("🔒 文件已加密，内容为Base64编码的密文\n")
 }
 
-// getServerPublicKey 从服务器获取公钥
-func getServerPublicKey(serverURL string) (*rsa.PublicKey, error) {
+// getServerPublicKey 从服务器获取公钥及其密钥ID
+func getServerPublicKey(serverURL string) (*rsa.PublicKey, string, error) {
 	// 构建API URL
 	apiURL := strings.TrimSuffix(serverURL, "/") + "/api/public-key"
 
 	// 发送HTTP请求
 	resp, err := http.Get(apiURL)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+		return nil, "", fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("服务器返回错误状态: %d", resp.StatusCode)
+		return nil, "", fmt.Errorf("服务器返回错误状态: %d", resp.StatusCode)
 	}
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, "", fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	// 解析JSON响应
 	var response PublicKeyResponse
 	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+		return nil, "", fmt.Errorf("解析响应失败: %w", err)
 	}
 
 	// 解析公钥
 	publicKey, err := crypto.LoadPublicKeyFromPEM(response.PublicKey)
 	if err != nil {
-		return nil, fmt.Errorf("解析公钥失败: %w", err)
+		return nil, "", fmt.Errorf("解析公钥失败: %w", err)
 	}
 
-	return publicKey, nil
+	return publicKey, response.KeyID, nil
 }
 
-// decryptLicenseFile 解密授权文件
-func decryptLicenseFile(filePath string) {
-	fmt.Printf("🔄 正在解密授权文件: %s\n", filePath)
+// loadLicenseFile 读取授权文件，加密文件使用本机派生的AES密钥解密（不验证签名）
+func loadLicenseFile(filePath string) (*LicenseFile, error) {
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
 
-	// 1. 读取文件
-	encryptedData, err := os.ReadFile(filePath)
+	machineID, err := utils.GetMachineID()
 	if err != nil {
-		fmt.Printf("❌ 读取文件失败: %v\n", err)
-		return
+		return nil, fmt.Errorf("获取机器ID失败: %w", err)
+	}
+
+	jsonData, err := client.DecryptLicense(fileData, machineID)
+	if err != nil {
+		return nil, err
 	}
 
-	// 2. 尝试解析为JSON（检查是否为明文文件）
 	var licenseFile LicenseFile
-	if err := json.Unmarshal(encryptedData, &licenseFile); err == nil {
-		// 这是明文文件
-		fmt.Println("ℹ️  检测到明文授权文件")
-		displayLicenseInfo(licenseFile)
+	if err := json.Unmarshal(jsonData, &licenseFile); err != nil {
+		return nil, fmt.Errorf("解析授权文件失败: %w", err)
+	}
+
+	return &licenseFile, nil
+}
+
+// decryptLicenseFile 解密授权文件
+func decryptLicenseFile(filePath string) {
+	fmt.Printf("🔄 正在解密授权文件: %s\n", filePath)
+
+	licenseFile, err := loadLicenseFile(filePath)
+	if err != nil {
+		fmt.Printf("❌ 解密授权文件失败: %v\n", err)
 		return
 	}
 
-	// 3. 假设是加密文件，需要私钥解密（这里演示，实际应该由服务端处理）
-	fmt.Println("🔒 检测到加密文件，需要私钥解密")
-	fmt.Println("⚠️  客户端通常不应该持有解密所有文件的私钥")
-	fmt.Println("ℹ️  这是演示功能，实际环境中只有服务端能解密")
+	displayLicenseInfo(*licenseFile)
 }
 
 // verifyLicenseFile 验证授权文件
-func verifyLicenseFile(filePath string) {
+func verifyLicenseFile(filePath, serverURL, renewalPath string) {
 	fmt.Printf("🔄 正在验证授权文件: %s\n", filePath)
 
-	// 读取文件
-	fileData, err := os.ReadFile(filePath)
+	// 实际产品中公钥应内嵌在程序中，这里为演示从服务器获取
+	publicKey, keyID, err := getServerPublicKey(serverURL)
 	if err != nil {
-		fmt.Printf("❌ 读取文件失败: %v\n", err)
+		fmt.Printf("❌ 获取服务器公钥失败: %v\n", err)
 		return
 	}
 
-	// 尝试解析为JSON
-	var licenseFile LicenseFile
-	if err := json.Unmarshal(fileData, &licenseFile); err != nil {
-		fmt.Printf("❌ 解析授权文件失败（可能是加密文件）: %v\n", err)
+	machineID, err := utils.GetMachineID()
+	if err != nil {
+		fmt.Printf("❌ 获取机器ID失败: %v\n", err)
 		return
 	}
 
-	// 显示授权信息
-	displayLicenseInfo(licenseFile)
+	// 在本地加密保存上次运行时间，用于检测系统时间回拨
+	anchorStore := client.NewFileAnchorStore(".license_anchor", machineID)
 
-	// 检查是否过期
-	if time.Now().After(licenseFile.LicenseData.ExpiresAt) {
-		fmt.Printf("❌ 授权已过期\n")
-	} else {
-		fmt.Printf("✅ 授权有效，到期时间: %s\n", licenseFile.LicenseData.ExpiresAt.Format("2006-01-02 15:04:05"))
+	verifier, err := client.NewVerifier(
+		client.WithPublicKey(keyID, publicKey),
+		client.WithMachineID(machineID),
+		client.WithTimeAnchor(anchorStore),
+	)
+	if err != nil {
+		fmt.Printf("❌ 创建验证器失败: %v\n", err)
+		return
 	}
 
-	// 验证机器ID
-	currentMachineID, err := utils.GetMachineID()
+	// 授权文件旁存在吊销列表时一并加载，离线环境可定期替换该文件
+	crlPath := filepath.Join(filepath.Dir(filePath), "revocations.crl")
+	if _, err := os.Stat(crlPath); err == nil {
+		revocationList, err := verifier.LoadRevocationListFile(crlPath)
+		if err != nil {
+			fmt.Printf("❌ 加载吊销列表失败: %v\n", err)
+			return
+		}
+		fmt.Printf("📄 已加载吊销列表: 版本 %d，%d 条记录\n", revocationList.RevocationData.Version, len(revocationList.RevocationData.Entries))
+	}
+
+	licenseData, err := os.ReadFile(filePath)
 	if err != nil {
-		fmt.Printf("⚠️  无法获取当前机器ID: %v\n", err)
-	} else if licenseFile.LicenseData.MachineID == currentMachineID {
-		fmt.Printf("✅ 机器ID匹配\n")
-	} else {
+		fmt.Printf("❌ 读取授权文件失败: %v\n", err)
+		return
+	}
+
+	var renewalData []byte
+	if renewalPath != "" {
+		renewalData, err = os.ReadFile(renewalPath)
+		if err != nil {
+			fmt.Printf("❌ 读取续期文件失败: %v\n", err)
+			return
+		}
+	}
+
+	result, err := verifier.VerifyWithRenewal(licenseData, renewalData)
+	if result != nil {
+		displayLicenseInfo(*result.License)
+		if result.Renewal != nil {
+			fmt.Printf("续期后到期时间: %s\n", result.Renewal.ExpiresAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	switch {
+	case err == nil:
+		fmt.Printf("✅ 签名有效，机器ID匹配\n")
+		fmt.Printf("✅ 授权有效，到期时间: %s（剩余%d天）\n", result.ExpiresAt().Format("2006-01-02 15:04:05"), result.RemainingDays())
+	case errors.Is(err, client.ErrRevoked):
+		fmt.Printf("❌ 授权已被吊销，请联系管理员\n")
+	case errors.Is(err, client.ErrMachineMismatch):
 		fmt.Printf("❌ 机器ID不匹配\n")
-		fmt.Printf("   授权机器ID: %s\n", licenseFile.LicenseData.MachineID)
-		fmt.Printf("   当前机器ID: %s\n", currentMachineID)
+		fmt.Printf("   授权机器ID: %s\n", result.License.LicenseData.MachineID)
+		fmt.Printf("   当前机器ID: %s\n", result.MachineID)
+	default:
+		fmt.Printf("❌ %v\n", err)
 	}
 }
 
@@ -323,21 +351,14 @@ func verifyLicenseFile(filePath string) {
 func generateUnbindFile(licenseFilePath string) {
 	fmt.Printf("🔄 正在生成解绑文件: %s\n", licenseFilePath)
 
-	// 1. 读取授权文件
-	fileData, err := os.ReadFile(licenseFilePath)
+	// 1. 读取并解密授权文件
+	licenseFile, err := loadLicenseFile(licenseFilePath)
 	if err != nil {
 		fmt.Printf("❌ 读取授权文件失败: %v\n", err)
 		return
 	}
 
-	// 2. 解析授权文件
-	var licenseFile LicenseFile
-	if err := json.Unmarshal(fileData, &licenseFile); err != nil {
-		fmt.Printf("❌ 解析授权文件失败（可能是加密文件）: %v\n", err)
-		return
-	}
-
-	// 3. 验证机器ID
+	// 2. 验证机器ID
 	currentMachineID, err := utils.GetMachineID()
 	if err != nil {
 		fmt.Printf("❌ 获取当前机器ID失败: %v\n", err)
@@ -349,60 +370,30 @@ func generateUnbindFile(licenseFilePath string) {
 		return
 	}
 
-	// 4. 从授权数据中提取解绑私钥
-	unbindPrivateKey, err := crypto.LoadPrivateKeyFromPEM(licenseFile.LicenseData.UnbindPrivateKey)
-	if err != nil {
-		fmt.Printf("❌ 解析解绑私钥失败: %v\n", err)
-		return
-	}
-
-	// 5. 获取主机名
+	// 3. 获取主机名
 	hostname, _ := os.Hostname()
 	if hostname == "" {
 		hostname = "unknown"
 	}
 
-	// 6. 创建解绑元数据
-	unbindMetadata := UnbindMetadata{
-		UnbindTime:    time.Now().UTC(),
-		Hostname:      hostname,
-		ClientVersion: "1.0.0",
-		UnbindReason:  "user_initiated",
-	}
-
-	// 7. 构造需要签名的数据
-	signData := fmt.Sprintf("%s:%s:%s:%s",
-		licenseFile.LicenseData.LicenseKey,
-		licenseFile.LicenseData.MachineID,
-		unbindMetadata.UnbindTime.Format(time.RFC3339),
-		unbindMetadata.Hostname)
-
-	// 8. 生成解绑证明
-	unbindProof, err := crypto.SignData(unbindPrivateKey, []byte(signData))
+	// 4. 使用授权文件中的一次性解绑私钥生成解绑文件
+	unbindFile, err := client.NewUnbindFile(licenseFile, hostname, "1.0.0", "user_initiated")
 	if err != nil {
-		fmt.Printf("❌ 生成解绑证明失败: %v\n", err)
+		fmt.Printf("❌ %v\n", err)
 		return
 	}
 
-	// 9. 创建解绑文件
-	unbindFile := UnbindFile{
-		LicenseKey:     licenseFile.LicenseData.LicenseKey,
-		MachineID:      licenseFile.LicenseData.MachineID,
-		UnbindMetadata: unbindMetadata,
-		UnbindProof:    unbindProof,
-	}
-
-	// 10. 序列化解绑文件
+	// 5. 序列化解绑文件
 	unbindData, err := json.MarshalIndent(unbindFile, "", "  ")
 	if err != nil {
 		fmt.Printf("❌ 序列化解绑文件失败: %v\n", err)
 		return
 	}
 
-	// 11. 生成文件名
+	// 6. 生成文件名
 	fileName := fmt.Sprintf("%s.unbind", hostname)
 
-	// 12. 写入文件
+	// 7. 写入文件
 	err = os.WriteFile(fileName, unbindData, 0644)
 	if err != nil {
 		fmt.Printf("❌ 写入解绑文件失败: %v\n", err)
@@ -0,0 +1,569 @@
+package tests
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lyenrowe/LicenseCenter/internal/config"
+	"github.com/lyenrowe/LicenseCenter/internal/database"
+	"github.com/lyenrowe/LicenseCenter/internal/models"
+	"github.com/lyenrowe/LicenseCenter/internal/services"
+	"github.com/lyenrowe/LicenseCenter/pkg/client"
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+	"github.com/lyenrowe/LicenseCenter/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientVerifierTestSuite struct {
+	suite.Suite
+	licenseService *services.LicenseService
+	authService    *services.AuthorizationService
+	rsaService     *services.RSAService
+	machineID      string
+	licenseFile    services.LicenseFile
+	encrypted      string
+}
+
+func (suite *ClientVerifierTestSuite) SetupSuite() {
+	// 初始化测试配置
+	err := config.LoadConfig("../configs/app.yaml")
+	assert.NoError(suite.T(), err)
+
+	// 初始化日志
+	err = logger.InitLogger("debug", "../logs/test.log")
+	assert.NoError(suite.T(), err)
+
+	// 使用内存数据库进行测试
+	config.AppConfig.Database.Driver = "sqlite"
+	config.AppConfig.Database.DSN = ":memory:"
+
+	err = database.InitDatabase(&config.AppConfig.Database)
+	assert.NoError(suite.T(), err)
+
+	err = database.DB.AutoMigrate()
+	assert.NoError(suite.T(), err)
+
+	suite.licenseService = services.NewLicenseService()
+	suite.authService = services.NewAuthorizationService()
+	suite.rsaService = services.NewRSAService()
+
+	// 激活一台设备并生成加密授权文件
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "SDK测试客户",
+		AuthorizationCode: "TEST-SDK-001",
+		MaxSeats:          2,
+	})
+	assert.NoError(suite.T(), err)
+
+	suite.machineID = "c1d2e3f4a5b6c1d2e3f4a5b6c1d2e3f4"
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "sdk-host", MachineID: suite.machineID, RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	suite.licenseFile = licenseFiles[0]
+
+	encrypted, err := suite.licenseService.EncryptLicenseFileWithClientAES(suite.licenseFile, crypto.GenerateClientAESKey(suite.machineID))
+	assert.NoError(suite.T(), err)
+	suite.encrypted = encrypted.EncryptedContent
+}
+
+func (suite *ClientVerifierTestSuite) TearDownSuite() {
+	if database.DB != nil {
+		database.DB.Close()
+	}
+}
+
+// newVerifier 使用服务端当前公钥创建验证器
+func (suite *ClientVerifierTestSuite) newVerifier(machineID string) *client.Verifier {
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+
+	verifier, err := client.NewVerifier(client.WithPublicKeyPEM("", publicKeyPEM), client.WithMachineID(machineID))
+	assert.NoError(suite.T(), err)
+	return verifier
+}
+
+func (suite *ClientVerifierTestSuite) TestVerifyEncryptedLicense() {
+	// 通过文件路径验证
+	filePath := filepath.Join(suite.T().TempDir(), "device.license")
+	err := os.WriteFile(filePath, []byte(suite.encrypted), 0644)
+	assert.NoError(suite.T(), err)
+
+	result, err := suite.newVerifier(suite.machineID).VerifyFile(filePath)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.licenseFile.LicenseData.LicenseKey, result.License.LicenseData.LicenseKey)
+	assert.Equal(suite.T(), suite.licenseFile.KeyID, result.KeyID)
+	assert.True(suite.T(), result.ExpiresAt().Equal(suite.licenseFile.LicenseData.ExpiresAt))
+	assert.Greater(suite.T(), result.RemainingDays(), 0)
+}
+
+func (suite *ClientVerifierTestSuite) TestVerifyOnOtherMachine() {
+	// 其他机器派生的AES密钥无法解密
+	_, err := suite.newVerifier("ffffffffffffffffffffffffffffffff").Verify([]byte(suite.encrypted))
+	assert.True(suite.T(), errors.Is(err, client.ErrDecryptFailed))
+
+	// 明文文件拷贝到其他机器时提示机器不匹配
+	plaintext, err := json.Marshal(suite.licenseFile)
+	assert.NoError(suite.T(), err)
+
+	result, err := suite.newVerifier("ffffffffffffffffffffffffffffffff").Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrMachineMismatch))
+	assert.NotNil(suite.T(), result)
+}
+
+func (suite *ClientVerifierTestSuite) TestVerifyTamperedLicense() {
+	tampered := suite.licenseFile
+	tampered.LicenseData.ExpiresAt = tampered.LicenseData.ExpiresAt.AddDate(10, 0, 0)
+
+	plaintext, err := json.Marshal(tampered)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.newVerifier(suite.machineID).Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrInvalidSignature))
+}
+
+func (suite *ClientVerifierTestSuite) TestVerifyUnknownKey() {
+	// 使用其他公钥的验证器无法识别授权文件的密钥ID
+	keyPair, err := crypto.GenerateRSAKeyPair(2048)
+	assert.NoError(suite.T(), err)
+
+	verifier, err := client.NewVerifier(client.WithPublicKey("", keyPair.PublicKey), client.WithMachineID(suite.machineID))
+	assert.NoError(suite.T(), err)
+
+	_, err = verifier.Verify([]byte(suite.encrypted))
+	assert.True(suite.T(), errors.Is(err, client.ErrUnknownKey))
+}
+
+func (suite *ClientVerifierTestSuite) TestVerifyExpiredLicense() {
+	expired := suite.licenseFile
+	expired.LicenseData.ExpiresAt = time.Now().Add(-time.Hour)
+
+	licenseDataBytes, err := json.Marshal(expired.LicenseData)
+	assert.NoError(suite.T(), err)
+	expired.Signature, expired.KeyID, err = suite.rsaService.SignDataWithKeyID(licenseDataBytes)
+	assert.NoError(suite.T(), err)
+
+	plaintext, err := json.MarshalIndent(expired, "", "  ")
+	assert.NoError(suite.T(), err)
+
+	result, err := suite.newVerifier(suite.machineID).Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrExpired))
+	assert.NotNil(suite.T(), result)
+	assert.Equal(suite.T(), 0, result.RemainingDays())
+}
+
+func (suite *ClientVerifierTestSuite) TestUnbindFileAcceptedByServer() {
+	unbindFile, err := client.NewUnbindFile(&suite.licenseFile, "sdk-host", "1.0.0", "decommission")
+	assert.NoError(suite.T(), err)
+
+	// 服务端使用同样的签名数据格式验证解绑证明
+	newLicense, err := suite.licenseService.TransferLicense("TEST-SDK-001", *unbindFile, services.BindFile{
+		Hostname:    "sdk-host-new",
+		MachineID:   "d1d2e3f4a5b6c1d2e3f4a5b6c1d2e3f4",
+		RequestTime: time.Now(),
+	})
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), newLicense)
+}
+
+func (suite *ClientVerifierTestSuite) TestClockRollback() {
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+
+	anchorPath := filepath.Join(suite.T().TempDir(), "anchor")
+	store := client.NewFileAnchorStore(anchorPath, suite.machineID)
+
+	now := time.Now()
+	clock := client.ClockFunc(func() time.Time { return now })
+	verifier, err := client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(suite.machineID),
+		client.WithClock(clock),
+		client.WithTimeAnchor(store),
+		client.WithRollbackTolerance(time.Minute),
+	)
+	assert.NoError(suite.T(), err)
+
+	// 首次运行记录时间锚点
+	_, err = verifier.Verify([]byte(suite.encrypted))
+	assert.NoError(suite.T(), err)
+
+	lastRun, err := store.Load()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), lastRun.Equal(now.UTC()))
+
+	// 容差范围内的回拨允许运行，且锚点不后退
+	now = now.Add(-30 * time.Second)
+	_, err = verifier.Verify([]byte(suite.encrypted))
+	assert.NoError(suite.T(), err)
+
+	anchorAfter, err := store.Load()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), anchorAfter.Equal(lastRun))
+
+	// 超出容差的回拨被拒绝
+	now = now.Add(-time.Hour)
+	_, err = verifier.Verify([]byte(suite.encrypted))
+	assert.True(suite.T(), errors.Is(err, client.ErrClockRollback))
+	assert.True(suite.T(), errors.Is(verifier.CheckClock(), client.ErrClockRollback))
+
+	// 时间恢复后正常运行
+	now = time.Now().Add(time.Hour)
+	assert.NoError(suite.T(), verifier.CheckClock())
+
+	// 验证后删除时间锚点被识别为篡改
+	assert.NoError(suite.T(), os.Remove(anchorPath))
+	_, err = verifier.Verify([]byte(suite.encrypted))
+	assert.True(suite.T(), errors.Is(err, client.ErrAnchorInvalid))
+
+	// 重启后无法识别锚点被删除，但时间仍不能早于授权文件的签发时间
+	restarted, err := client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(suite.machineID),
+		client.WithClock(clock),
+		client.WithTimeAnchor(store),
+		client.WithRollbackTolerance(time.Minute),
+	)
+	assert.NoError(suite.T(), err)
+	now = suite.licenseFile.LicenseData.IssuedAt.Add(-time.Hour)
+	_, err = restarted.Verify([]byte(suite.encrypted))
+	assert.True(suite.T(), errors.Is(err, client.ErrClockRollback))
+	assert.NoFileExists(suite.T(), anchorPath)
+
+	now = suite.licenseFile.LicenseData.IssuedAt.Add(time.Minute)
+	_, err = restarted.Verify([]byte(suite.encrypted))
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *ClientVerifierTestSuite) TestTamperedTimeAnchor() {
+	anchorPath := filepath.Join(suite.T().TempDir(), "anchor")
+	err := client.NewFileAnchorStore(anchorPath, suite.machineID).Save(time.Now())
+	assert.NoError(suite.T(), err)
+
+	// 其他机器无法读取该锚点
+	_, err = client.NewFileAnchorStore(anchorPath, "ffffffffffffffffffffffffffffffff").Load()
+	assert.True(suite.T(), errors.Is(err, client.ErrAnchorInvalid))
+
+	// 被修改的锚点无法解密
+	err = os.WriteFile(anchorPath, []byte("dGFtcGVyZWQ="), 0600)
+	assert.NoError(suite.T(), err)
+	_, err = client.NewFileAnchorStore(anchorPath, suite.machineID).Load()
+	assert.True(suite.T(), errors.Is(err, client.ErrAnchorInvalid))
+}
+
+func (suite *ClientVerifierTestSuite) TestVerifyV2EncryptedLicense() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "SDK测试客户",
+		AuthorizationCode: "TEST-SDK-V2",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	machineID := "e9d2e3f4a5b6c1d2e3f4a5b6c1d2e3f9"
+	bindFile, clientPrivateKey, err := client.NewBindFile("sdk-v2-host", machineID)
+	assert.NoError(suite.T(), err)
+	encryptedBind, err := suite.licenseService.EncryptBindFile(*bindFile)
+	assert.NoError(suite.T(), err)
+
+	licenseFiles, err := suite.licenseService.ActivateLicensesEncrypted(auth.AuthorizationCode, []string{encryptedBind.EncryptedContent})
+	assert.NoError(suite.T(), err)
+
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+	verifier, err := client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithClientPrivateKey(clientPrivateKey),
+	)
+	assert.NoError(suite.T(), err)
+
+	result, err := verifier.Verify([]byte(licenseFiles[0].EncryptedContent))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), machineID, result.License.LicenseData.MachineID)
+
+	// 仅知道机器ID无法解密v2授权文件
+	_, err = suite.newVerifier(machineID).Verify([]byte(licenseFiles[0].EncryptedContent))
+	assert.True(suite.T(), errors.Is(err, client.ErrDecryptFailed))
+
+	// 控制台重新下载的授权文件同样加密给客户端公钥
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), bindFile.ClientPublicKey, licenses[0].ClientPublicKey)
+
+	downloaded, _, err := suite.licenseService.RegenerateLicenseFile(licenses[0].ID, auth.ID)
+	assert.NoError(suite.T(), err)
+	_, err = verifier.Verify(downloaded)
+	assert.NoError(suite.T(), err)
+	_, err = suite.newVerifier(machineID).Verify(downloaded)
+	assert.True(suite.T(), errors.Is(err, client.ErrDecryptFailed))
+
+	// 客户端公钥无效的绑定文件被拒绝
+	bindFile.ClientPublicKey = "invalid"
+	encryptedBind, err = suite.licenseService.EncryptBindFile(*bindFile)
+	assert.NoError(suite.T(), err)
+	_, err = suite.licenseService.ActivateLicensesEncrypted(auth.AuthorizationCode, []string{encryptedBind.EncryptedContent})
+	assert.Error(suite.T(), err)
+}
+
+func (suite *ClientVerifierTestSuite) TestEdDSASignedLicense() {
+	algorithm := config.AppConfig.Security.SigningAlgorithm
+	defer func() { config.AppConfig.Security.SigningAlgorithm = algorithm }()
+	config.AppConfig.Security.SigningAlgorithm = crypto.SignatureEdDSA
+
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "SDK测试客户",
+		AuthorizationCode: "TEST-SDK-EDDSA",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	machineID := "e9d2e3f4a5b6c1d2e3f4a5b6c1d2e3fa"
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "sdk-eddsa-host", MachineID: machineID, RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	licenseFile := licenseFiles[0]
+	assert.Equal(suite.T(), crypto.SignatureEdDSA, licenseFile.Algorithm)
+
+	signingKey, err := suite.rsaService.GetActiveSigningKey()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), signingKey.KeyID, licenseFile.KeyID)
+
+	plaintext, err := json.Marshal(licenseFile)
+	assert.NoError(suite.T(), err)
+
+	verifier, err := client.NewVerifier(
+		client.WithSigningKeyPEM("", crypto.SignatureEdDSA, signingKey.PublicKey),
+		client.WithMachineID(machineID),
+	)
+	assert.NoError(suite.T(), err)
+	result, err := verifier.Verify(plaintext)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), crypto.SignatureEdDSA, result.License.Algorithm)
+
+	// 只信任RSA公钥的验证器不认识Ed25519签名密钥
+	_, err = suite.newVerifier(machineID).Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrUnknownKey))
+
+	// 文件声明的算法与可信公钥不一致时拒绝
+	licenseFile.Algorithm = crypto.SignatureRS256
+	plaintext, err = json.Marshal(licenseFile)
+	assert.NoError(suite.T(), err)
+	_, err = verifier.Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrInvalidSignature))
+
+	// 公钥类型与算法不匹配时无法添加
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+	_, err = client.NewVerifier(client.WithSigningKeyPEM("", crypto.SignatureEdDSA, publicKeyPEM))
+	assert.Error(suite.T(), err)
+}
+
+func (suite *ClientVerifierTestSuite) TestCertifiedSigningKey() {
+	algorithm := config.AppConfig.Security.SigningAlgorithm
+	defer func() { config.AppConfig.Security.SigningAlgorithm = algorithm }()
+	config.AppConfig.Security.SigningAlgorithm = crypto.SignatureEdDSA
+
+	rootKey, err := crypto.GenerateSigningKeyPair(crypto.SignatureEdDSA, 0)
+	assert.NoError(suite.T(), err)
+	suite.useRootKey(rootKey)
+	privateKey, err := crypto.ParsePrivateKeyPEM(rootKey.PrivateKeyPEM)
+	assert.NoError(suite.T(), err)
+	root, err := crypto.NewSigner(crypto.SignatureEdDSA, privateKey)
+	assert.NoError(suite.T(), err)
+
+	// 客户端未内置签名密钥，只内置根公钥
+	request, err := suite.rsaService.GetCertificateRequest("")
+	assert.NoError(suite.T(), err)
+	request.NotBefore = time.Now().Add(-time.Hour)
+	request.NotAfter = time.Now().Add(24 * time.Hour)
+	certificate, err := crypto.IssueCertificate(root, *request)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.rsaService.ImportCertificate(request.KeyID, certificate))
+
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "SDK测试客户",
+		AuthorizationCode: "TEST-SDK-CERT",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	machineID := "f1d2e3f4a5b6c1d2e3f4a5b6c1d2e3fb"
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "sdk-cert-host", MachineID: machineID, RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	licenseFile := licenseFiles[0]
+	assert.Equal(suite.T(), request.KeyID, licenseFile.KeyID)
+	assert.Equal(suite.T(), certificate, licenseFile.Certificate)
+
+	plaintext, err := json.Marshal(licenseFile)
+	assert.NoError(suite.T(), err)
+
+	verifier, err := client.NewVerifier(
+		client.WithRootKeyPEM(crypto.SignatureEdDSA, rootKey.PublicKeyPEM),
+		client.WithMachineID(machineID),
+	)
+	assert.NoError(suite.T(), err)
+	result, err := verifier.Verify(plaintext)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), request.KeyID, result.KeyID)
+	assert.Equal(suite.T(), certificate, result.License.Certificate)
+
+	// 其他根密钥不信任该证书
+	otherRoot, err := crypto.GenerateSigningKeyPair(crypto.SignatureEdDSA, 0)
+	assert.NoError(suite.T(), err)
+	otherVerifier, err := client.NewVerifier(
+		client.WithRootKeyPEM(crypto.SignatureEdDSA, otherRoot.PublicKeyPEM),
+		client.WithMachineID(machineID),
+	)
+	assert.NoError(suite.T(), err)
+	_, err = otherVerifier.Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrUnknownKey))
+
+	// 伪造的证书签名无法通过根公钥验证
+	var forged map[string]interface{}
+	decoded, err := base64.StdEncoding.DecodeString(certificate)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), json.Unmarshal(decoded, &forged))
+	forged["signature"] = base64.StdEncoding.EncodeToString(make([]byte, 64))
+	decoded, err = json.Marshal(forged)
+	assert.NoError(suite.T(), err)
+	tampered := licenseFile
+	tampered.Certificate = base64.StdEncoding.EncodeToString(decoded)
+	plaintext, err = json.Marshal(tampered)
+	assert.NoError(suite.T(), err)
+	_, err = verifier.Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrInvalidCertificate))
+
+	// 授权签发时间不在证书有效期内
+	request.NotBefore = time.Now().Add(-48 * time.Hour)
+	request.NotAfter = time.Now().Add(-24 * time.Hour)
+	expired, err := crypto.IssueCertificate(root, *request)
+	assert.NoError(suite.T(), err)
+	tampered.Certificate = expired
+	plaintext, err = json.Marshal(tampered)
+	assert.NoError(suite.T(), err)
+	_, err = verifier.Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrInvalidCertificate))
+
+	// 本机时间超过证书到期时间加宽限期后，不再接受该签名密钥签名的文件（防止泄露的密钥倒填签名时间）
+	plaintext, err = json.Marshal(licenseFile)
+	assert.NoError(suite.T(), err)
+	lateVerifier, err := client.NewVerifier(
+		client.WithRootKeyPEM(crypto.SignatureEdDSA, rootKey.PublicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithCertificateGrace(time.Hour),
+		client.WithClock(client.ClockFunc(func() time.Time { return time.Now().Add(26 * time.Hour) })),
+	)
+	assert.NoError(suite.T(), err)
+	_, err = lateVerifier.Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrInvalidCertificate))
+
+	// 签名时间晚于本机时间
+	earlyVerifier, err := client.NewVerifier(
+		client.WithRootKeyPEM(crypto.SignatureEdDSA, rootKey.PublicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithClock(client.ClockFunc(func() time.Time { return time.Now().Add(-30 * time.Minute) })),
+	)
+	assert.NoError(suite.T(), err)
+	_, err = earlyVerifier.Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrInvalidCertificate))
+
+	// 未附带证书时无法验证未内置的签名密钥
+	tampered.Certificate = ""
+	plaintext, err = json.Marshal(tampered)
+	assert.NoError(suite.T(), err)
+	_, err = verifier.Verify(plaintext)
+	assert.True(suite.T(), errors.Is(err, client.ErrUnknownKey))
+}
+
+func (suite *ClientVerifierTestSuite) TestReissueAfterKeyRotation() {
+	algorithm := config.AppConfig.Security.SigningAlgorithm
+	defer func() { config.AppConfig.Security.SigningAlgorithm = algorithm }()
+	config.AppConfig.Security.SigningAlgorithm = crypto.SignatureEdDSA
+
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "SDK测试客户",
+		AuthorizationCode: "TEST-SDK-REISSUE",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	machineID := "a7d2e3f4a5b6c1d2e3f4a5b6c1d2e3fc"
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "sdk-reissue-host", MachineID: machineID, RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	// 授权在签名密钥轮换之前签发
+	issuedAt := time.Now().Add(-30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	var license models.License
+	assert.NoError(suite.T(), database.GetDB().Where("license_key = ?", licenseFiles[0].LicenseData.LicenseKey).First(&license).Error)
+	assert.NoError(suite.T(), database.GetDB().Model(&license).Update("issued_at", issuedAt).Error)
+
+	// 轮换签名密钥并导入证书，证书有效期晚于原签发时间
+	rootKey, err := crypto.GenerateSigningKeyPair(crypto.SignatureEdDSA, 0)
+	assert.NoError(suite.T(), err)
+	suite.useRootKey(rootKey)
+	signingKey, err := suite.rsaService.GenerateSigningKey(crypto.SignatureEdDSA)
+	assert.NoError(suite.T(), err)
+	suite.certifySigningKey(rootKey, signingKey.KeyID, time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+
+	encrypted, _, err := suite.licenseService.RegenerateLicenseFile(license.ID, uint(1))
+	assert.NoError(suite.T(), err)
+
+	verifier, err := client.NewVerifier(
+		client.WithRootKeyPEM(crypto.SignatureEdDSA, rootKey.PublicKeyPEM),
+		client.WithMachineID(machineID),
+	)
+	assert.NoError(suite.T(), err)
+	result, err := verifier.Verify(encrypted)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), signingKey.KeyID, result.KeyID)
+	assert.True(suite.T(), result.License.LicenseData.IssuedAt.Equal(issuedAt))
+	assert.NotNil(suite.T(), result.License.LicenseData.SignedAt)
+	assert.True(suite.T(), result.License.LicenseData.SignedAt.After(issuedAt))
+}
+
+// useRootKey 将根公钥配置到服务端，测试结束后恢复
+func (suite *ClientVerifierTestSuite) useRootKey(rootKey *crypto.SigningKeyPair) {
+	rootFile, rootAlgorithm := config.AppConfig.Security.RootPublicKeyFile, config.AppConfig.Security.RootKeyAlgorithm
+	suite.T().Cleanup(func() {
+		config.AppConfig.Security.RootPublicKeyFile = rootFile
+		config.AppConfig.Security.RootKeyAlgorithm = rootAlgorithm
+	})
+
+	rootPath := filepath.Join(suite.T().TempDir(), "root.pub")
+	assert.NoError(suite.T(), os.WriteFile(rootPath, []byte(rootKey.PublicKeyPEM), 0644))
+	config.AppConfig.Security.RootPublicKeyFile = rootPath
+	config.AppConfig.Security.RootKeyAlgorithm = rootKey.Algorithm
+}
+
+// certifySigningKey 使用根密钥为签名密钥签发证书并导入服务端
+func (suite *ClientVerifierTestSuite) certifySigningKey(rootKey *crypto.SigningKeyPair, keyID string, notBefore, notAfter time.Time) string {
+	privateKey, err := crypto.ParsePrivateKeyPEM(rootKey.PrivateKeyPEM)
+	assert.NoError(suite.T(), err)
+	root, err := crypto.NewSigner(rootKey.Algorithm, privateKey)
+	assert.NoError(suite.T(), err)
+
+	request, err := suite.rsaService.GetCertificateRequest(keyID)
+	assert.NoError(suite.T(), err)
+	request.NotBefore = notBefore
+	request.NotAfter = notAfter
+	certificate, err := crypto.IssueCertificate(root, *request)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.rsaService.ImportCertificate(request.KeyID, certificate))
+
+	return certificate
+}
+
+func TestClientVerifierSuite(t *testing.T) {
+	suite.Run(t, new(ClientVerifierTestSuite))
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"crypto/rsa"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -212,6 +213,56 @@ func (suite *HybridEncryptionTestSuite) TestEncryptedTransferFlow() {
 	assert.Equal(suite.T(), newBindFile.MachineID, decryptedNewBindFile.MachineID)
 }
 
+func (suite *HybridEncryptionTestSuite) TestTypedEnvelope() {
+	bindFile := services.BindFile{
+		Hostname:    "typed-device",
+		MachineID:   "a1b2c3d4e5f60718293a4b5c6d7e8f90",
+		RequestTime: time.Now(),
+	}
+
+	encryptedBindFile, err := suite.licenseService.EncryptBindFile(bindFile)
+	assert.NoError(suite.T(), err)
+
+	// 新生成的绑定文件使用v3信封，头部带文件类型和算法套件
+	raw, err := base64.StdEncoding.DecodeString(encryptedBindFile.EncryptedContent)
+	assert.NoError(suite.T(), err)
+	envelope, err := crypto.ParseEnvelope(raw)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), crypto.EnvelopeVersionTyped, envelope.Version)
+	assert.Equal(suite.T(), crypto.FileTypeBind, envelope.FileType)
+	assert.Equal(suite.T(), crypto.SuiteRSAOAEPAESGCM, envelope.Suite)
+	assert.NotEmpty(suite.T(), envelope.KeyID)
+
+	decrypted, err := suite.licenseService.DecryptBindFile(encryptedBindFile.EncryptedContent)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), bindFile.MachineID, decrypted.MachineID)
+
+	jsonData, err := crypto.DecryptTypedFileFromBase64(suite.privateKey, crypto.FileTypeBind, encryptedBindFile.EncryptedContent)
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), string(jsonData), bindFile.MachineID)
+
+	// 绑定文件不能当作解绑文件提交
+	_, err = suite.licenseService.DecryptUnbindFile(encryptedBindFile.EncryptedContent)
+	assert.Error(suite.T(), err)
+	_, err = crypto.DecryptTypedFileFromBase64(suite.privateKey, crypto.FileTypeUnbind, encryptedBindFile.EncryptedContent)
+	assert.Error(suite.T(), err)
+
+	// 篡改头部中的文件类型后，附加认证数据校验失败
+	tampered := append([]byte(nil), raw...)
+	tampered[4] = byte(crypto.FileTypeUnbind)
+	_, err = suite.licenseService.DecryptUnbindFile(base64.StdEncoding.EncodeToString(tampered))
+	assert.Error(suite.T(), err)
+
+	// 旧版信封没有文件类型，仍可按绑定文件解密
+	legacyJSON, err := json.Marshal(bindFile)
+	assert.NoError(suite.T(), err)
+	legacy, err := crypto.EncryptFileToBase64(suite.publicKey, legacyJSON)
+	assert.NoError(suite.T(), err)
+	decrypted, err = suite.licenseService.DecryptBindFile(legacy)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), bindFile.Hostname, decrypted.Hostname)
+}
+
 func (suite *HybridEncryptionTestSuite) TestEncryptionPerformance() {
 	// 测试不同大小数据的加密性能
 	testSizes := []int{100, 1000, 10000, 100000} // 字节
@@ -1,12 +1,20 @@
 package tests
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/lyenrowe/LicenseCenter/internal/config"
 	"github.com/lyenrowe/LicenseCenter/internal/database"
+	"github.com/lyenrowe/LicenseCenter/internal/models"
 	"github.com/lyenrowe/LicenseCenter/internal/services"
+	"github.com/lyenrowe/LicenseCenter/pkg/client"
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
+	"github.com/lyenrowe/LicenseCenter/pkg/errors"
 	"github.com/lyenrowe/LicenseCenter/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -180,6 +188,1328 @@ func (suite *LicenseServiceTestSuite) TestGetLicensesByAuth() {
 	assert.Contains(suite.T(), machineIDs, machineID2)
 }
 
+func (suite *LicenseServiceTestSuite) TestLicenseCarriesEntitlements() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-FEATURE-001",
+		MaxSeats:          2,
+		Edition:           "professional",
+		Features:          []string{"report", " export ", "report"},
+		Limits:            map[string]int64{"max_users": 50},
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"report", "export"}, auth.Features)
+
+	machineID := "e1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "feature-host", MachineID: machineID, RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	licenseData := licenseFiles[0].LicenseData
+	assert.Equal(suite.T(), "professional", licenseData.Edition)
+	assert.True(suite.T(), licenseData.HasFeature("export"))
+	assert.False(suite.T(), licenseData.HasFeature("audit"))
+	maxUsers, ok := licenseData.Limit("max_users")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), int64(50), maxUsers)
+
+	// 修改授权码的功能权益后，重新下载的授权文件使用新的权益
+	features := []string{"report", "audit"}
+	limits := map[string]int64{}
+	_, err = suite.authService.UpdateAuthorization(auth.ID, &services.UpdateAuthorizationRequest{
+		Features: &features,
+		Limits:   &limits,
+	})
+	assert.NoError(suite.T(), err)
+
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+
+	encrypted, _, err := suite.licenseService.RegenerateLicenseFile(licenses[0].ID, auth.ID)
+	assert.NoError(suite.T(), err)
+
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+	verifier, err := client.NewVerifier(client.WithPublicKeyPEM("", publicKeyPEM), client.WithMachineID(machineID))
+	assert.NoError(suite.T(), err)
+
+	result, err := verifier.Verify(encrypted)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "professional", result.License.LicenseData.Edition)
+	assert.True(suite.T(), result.License.LicenseData.HasFeature("audit"))
+	assert.False(suite.T(), result.License.LicenseData.HasFeature("export"))
+	assert.Empty(suite.T(), result.License.LicenseData.Limits)
+}
+
+func (suite *LicenseServiceTestSuite) TestTrialLicenseLifecycle() {
+	durationYears := 1
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-TRIAL-001",
+		MaxSeats:          2,
+		DurationYears:     &durationYears,
+	})
+	assert.NoError(suite.T(), err)
+
+	machineID := "f1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"
+	bindFile := services.BindFile{Hostname: "trial-host", MachineID: machineID, RequestTime: time.Now()}
+
+	// 默认签发的试用授权不占用席位
+	licenseFile, license, err := suite.licenseService.IssueTrialLicense(auth.ID, bindFile, services.TrialLicenseOptions{DurationDays: 7})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseTypeTrial, licenseFile.LicenseData.LicenseType)
+	assert.True(suite.T(), license.SeatExempt)
+	assert.WithinDuration(suite.T(), time.Now().AddDate(0, 0, 7), license.ExpiresAt, time.Minute)
+
+	updatedAuth, err := suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, updatedAuth.UsedSeats)
+
+	// 同一台设备不能再次试用，解绑后也不行
+	err = suite.licenseService.ForceUnbindLicense(license.ID, "测试")
+	assert.NoError(suite.T(), err)
+	updatedAuth, err = suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, updatedAuth.UsedSeats)
+
+	_, _, err = suite.licenseService.IssueTrialLicense(auth.ID, bindFile, services.TrialLicenseOptions{})
+	assert.Equal(suite.T(), errors.ErrTrialAlreadyUsed, err)
+
+	// 超过最长试用天数
+	_, _, err = suite.licenseService.IssueTrialLicense(auth.ID, services.BindFile{
+		Hostname: "trial-host-2", MachineID: "f2b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, services.TrialLicenseOptions{DurationDays: 3650})
+	assert.Error(suite.T(), err)
+
+	// 转为正式授权时占用席位并按授权码规则计算到期时间
+	_, trial, err := suite.licenseService.IssueTrialLicense(auth.ID, services.BindFile{
+		Hostname: "trial-host-3", MachineID: "f3b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, services.TrialLicenseOptions{})
+	assert.NoError(suite.T(), err)
+
+	converted, err := suite.licenseService.ConvertTrialLicense(trial.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseTypeFull, converted.LicenseType)
+	assert.False(suite.T(), converted.SeatExempt)
+	assert.Equal(suite.T(), trial.LicenseKey, converted.LicenseKey)
+	assert.True(suite.T(), converted.ExpiresAt.After(time.Now().AddDate(0, 11, 0)))
+
+	updatedAuth, err = suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, updatedAuth.UsedSeats)
+
+	_, err = suite.licenseService.ConvertTrialLicense(trial.ID)
+	assert.Equal(suite.T(), errors.ErrNotTrialLicense, err)
+
+	// 重新下载的授权文件为正式授权
+	encrypted, _, err := suite.licenseService.RegenerateLicenseFile(trial.ID, auth.ID)
+	assert.NoError(suite.T(), err)
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+	verifier, err := client.NewVerifier(client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID("f3b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"))
+	assert.NoError(suite.T(), err)
+	result, err := verifier.Verify(encrypted)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseTypeFull, result.License.LicenseData.LicenseType)
+
+	// 转为正式授权后保留试用标记，解绑后该设备仍不能再次试用
+	assert.NotNil(suite.T(), converted.TrialIssuedAt)
+	assert.NoError(suite.T(), suite.licenseService.ForceUnbindLicense(trial.ID, "测试"))
+	_, _, err = suite.licenseService.IssueTrialLicense(auth.ID, services.BindFile{
+		Hostname: "trial-host-3", MachineID: "f3b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, services.TrialLicenseOptions{})
+	assert.Equal(suite.T(), errors.ErrTrialAlreadyUsed, err)
+}
+
+func (suite *LicenseServiceTestSuite) TestRenewLicense() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-RENEW-001",
+		MaxSeats:          3,
+	})
+	assert.NoError(suite.T(), err)
+
+	machineID := "a9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"
+	otherMachineID := "b9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"
+	_, err = suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "renew-host-1", MachineID: machineID, RequestTime: time.Now()},
+		{Hostname: "renew-host-2", MachineID: otherMachineID, RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	var license models.License
+	for _, l := range licenses {
+		if l.MachineID == machineID {
+			license = l
+		}
+	}
+	licenseFileData, _, err := suite.licenseService.RegenerateLicenseFile(license.ID, auth.ID)
+	assert.NoError(suite.T(), err)
+
+	// 续期时间不能早于当前到期时间
+	_, err = suite.licenseService.RenewLicense(license.ID, license.ExpiresAt.Add(-time.Hour))
+	assert.Error(suite.T(), err)
+
+	newExpiry := license.ExpiresAt.AddDate(1, 0, 0)
+	renewed, err := suite.licenseService.RenewLicense(license.ID, newExpiry)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), newExpiry.Equal(renewed.License.ExpiresAt))
+
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+
+	// 客户端在原授权文件上应用续期文件
+	clock := time.Now()
+	verifier, err := client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithClock(client.ClockFunc(func() time.Time { return clock })),
+	)
+	assert.NoError(suite.T(), err)
+
+	renewalData := []byte(renewed.RenewalFile.EncryptedContent)
+	result, err := verifier.VerifyWithRenewal(licenseFileData, renewalData)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), newExpiry.Equal(result.ExpiresAt()))
+
+	// 原到期时间之后，应用续期文件仍然有效
+	clock = license.ExpiresAt.AddDate(0, 1, 0)
+	_, err = verifier.Verify(licenseFileData)
+	assert.ErrorIs(suite.T(), err, client.ErrExpired)
+	_, err = verifier.VerifyWithRenewal(licenseFileData, renewalData)
+	assert.NoError(suite.T(), err)
+	clock = time.Now()
+
+	// 批量续期只处理到期时间早于目标时间的设备
+	bulkExpiry := license.ExpiresAt.AddDate(0, 6, 0)
+	results, err := suite.licenseService.RenewAuthorizationLicenses(auth.ID, bulkExpiry)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), results, 1)
+	assert.Equal(suite.T(), otherMachineID, results[0].License.MachineID)
+
+	// 其他设备的续期文件无法在本机解密
+	_, err = verifier.VerifyWithRenewal(licenseFileData, []byte(results[0].RenewalFile.EncryptedContent))
+	assert.ErrorIs(suite.T(), err, client.ErrDecryptFailed)
+
+	// 到期时间已延长后，旧续期文件被忽略，不会缩短有效期
+	regenerated, _, err := suite.licenseService.RegenerateLicenseFile(license.ID, auth.ID)
+	assert.NoError(suite.T(), err)
+	later, err := suite.licenseService.RenewLicense(license.ID, newExpiry.AddDate(1, 0, 0))
+	assert.NoError(suite.T(), err)
+	result, err = verifier.VerifyWithRenewal(regenerated, renewalData)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), result.Renewal)
+	assert.True(suite.T(), newExpiry.Equal(result.ExpiresAt()))
+	result, err = verifier.VerifyWithRenewal(regenerated, []byte(later.RenewalFile.EncryptedContent))
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), newExpiry.AddDate(1, 0, 0).Equal(result.ExpiresAt()))
+}
+
+func (suite *LicenseServiceTestSuite) TestRenewRestrictions() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-RENEW-002",
+		MaxSeats:          2,
+	})
+	assert.NoError(suite.T(), err)
+
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "renew-full", MachineID: "c9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	var full models.License
+	err = database.GetDB().Where("license_key = ?", licenseFiles[0].LicenseData.LicenseKey).First(&full).Error
+	assert.NoError(suite.T(), err)
+
+	_, trial, err := suite.licenseService.IssueTrialLicense(auth.ID, services.BindFile{
+		Hostname: "renew-trial", MachineID: "d9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, services.TrialLicenseOptions{DurationDays: 7})
+	assert.NoError(suite.T(), err)
+
+	// 试用授权不能续期，批量续期时跳过
+	newExpiry := full.ExpiresAt.AddDate(1, 0, 0)
+	_, err = suite.licenseService.RenewLicense(trial.ID, newExpiry)
+	assert.Equal(suite.T(), errors.ErrTrialNotAllowed, err)
+
+	results, err := suite.licenseService.RenewAuthorizationLicenses(auth.ID, newExpiry)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), results, 1)
+	assert.Equal(suite.T(), full.ID, results[0].License.ID)
+
+	var unchanged models.License
+	err = database.GetDB().First(&unchanged, trial.ID).Error
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), trial.ExpiresAt.Equal(unchanged.ExpiresAt))
+
+	// 授权码被禁用后不能续期
+	disabled := 0
+	_, err = suite.authService.UpdateAuthorization(auth.ID, &services.UpdateAuthorizationRequest{Status: &disabled})
+	assert.NoError(suite.T(), err)
+	_, err = suite.licenseService.RenewLicense(full.ID, newExpiry.AddDate(1, 0, 0))
+	assert.Equal(suite.T(), errors.ErrAuthCodeDisabled, err)
+	_, err = suite.licenseService.RenewAuthorizationLicenses(auth.ID, newExpiry.AddDate(1, 0, 0))
+	assert.Equal(suite.T(), errors.ErrAuthCodeDisabled, err)
+
+	var current models.License
+	err = database.GetDB().First(&current, full.ID).Error
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), newExpiry.Equal(current.ExpiresAt))
+}
+
+func (suite *LicenseServiceTestSuite) TestRevocationList() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-CRL-001",
+		MaxSeats:          3,
+	})
+	assert.NoError(suite.T(), err)
+	otherAuth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "其他客户",
+		AuthorizationCode: "TEST-CRL-002",
+		MaxSeats:          2,
+	})
+	assert.NoError(suite.T(), err)
+
+	machineID := "c9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "crl-host-1", MachineID: machineID, RequestTime: time.Now()},
+		{Hostname: "crl-host-2", MachineID: "d9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	otherLicenseFiles, err := suite.licenseService.ActivateLicenses(otherAuth.AuthorizationCode, []services.BindFile{
+		{Hostname: "crl-host-3", MachineID: "e9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+		{Hostname: "crl-host-4", MachineID: "f9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	// 尚无吊销记录
+	emptyList, err := suite.licenseService.GenerateRevocationList(0)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), emptyList.RevocationData.Entries)
+	assert.Equal(suite.T(), int64(0), emptyList.RevocationData.Version)
+
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	for _, license := range licenses {
+		if license.MachineID == machineID {
+			assert.NoError(suite.T(), suite.licenseService.ForceUnbindLicense(license.ID, "测试"))
+		}
+	}
+	otherLicenses, err := suite.licenseService.GetLicensesByAuth(otherAuth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	for _, license := range otherLicenses {
+		if license.LicenseKey == otherLicenseFiles[0].LicenseData.LicenseKey {
+			assert.NoError(suite.T(), suite.licenseService.ForceUnbindLicense(license.ID, "测试"))
+		}
+	}
+
+	// 按授权码生成的吊销列表只包含本授权码的授权，版本号全局共用
+	scopedList, err := suite.licenseService.GenerateRevocationList(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), auth.ID, scopedList.RevocationData.AuthorizationID)
+	assert.Equal(suite.T(), int64(2), scopedList.RevocationData.Version)
+	assert.Len(suite.T(), scopedList.RevocationData.Entries, 1)
+	assert.Equal(suite.T(), licenseFiles[0].LicenseData.LicenseKey, scopedList.RevocationData.Entries[0].LicenseKey)
+	assert.Equal(suite.T(), "force_unbound", scopedList.RevocationData.Entries[0].Reason)
+
+	fullList, err := suite.licenseService.GenerateRevocationList(0)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), uint(0), fullList.RevocationData.AuthorizationID)
+	assert.Equal(suite.T(), int64(2), fullList.RevocationData.Version)
+	assert.Len(suite.T(), fullList.RevocationData.Entries, 2)
+
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+	fullListData, err := json.Marshal(fullList)
+	assert.NoError(suite.T(), err)
+
+	verifier, err := client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithRevocationList(fullListData),
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), fullList.RevocationData.Version, verifier.RevocationVersion(0))
+
+	licenseData, err := json.Marshal(licenseFiles[0])
+	assert.NoError(suite.T(), err)
+	result, err := verifier.Verify(licenseData)
+	assert.ErrorIs(suite.T(), err, client.ErrRevoked)
+	assert.NotNil(suite.T(), result)
+
+	// 旧版本的吊销列表不能覆盖新版本
+	emptyListData, err := json.Marshal(emptyList)
+	assert.NoError(suite.T(), err)
+	_, err = verifier.LoadRevocationList(emptyListData)
+	assert.ErrorIs(suite.T(), err, client.ErrCRLOutdated)
+
+	// 篡改后的吊销列表无法通过验签
+	fullList.RevocationData.Entries = nil
+	tamperedData, err := json.Marshal(fullList)
+	assert.NoError(suite.T(), err)
+	_, err = verifier.LoadRevocationList(tamperedData)
+	assert.ErrorIs(suite.T(), err, client.ErrInvalidSignature)
+
+	_, err = verifier.Verify(licenseData)
+	assert.ErrorIs(suite.T(), err, client.ErrRevoked)
+
+	// 再解绑一台设备后，其他授权码版本更高的列表不会覆盖本授权码的列表
+	for _, license := range otherLicenses {
+		if license.LicenseKey == otherLicenseFiles[1].LicenseData.LicenseKey {
+			assert.NoError(suite.T(), suite.licenseService.ForceUnbindLicense(license.ID, "测试"))
+		}
+	}
+	otherList, err := suite.licenseService.GenerateRevocationList(otherAuth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), otherList.RevocationData.Version)
+	otherListData, err := json.Marshal(otherList)
+	assert.NoError(suite.T(), err)
+	scopedListData, err := json.Marshal(scopedList)
+	assert.NoError(suite.T(), err)
+
+	scopedVerifier, err := client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithRevocationList(scopedListData),
+	)
+	assert.NoError(suite.T(), err)
+	_, err = scopedVerifier.LoadRevocationList(otherListData)
+	assert.NoError(suite.T(), err)
+	_, err = scopedVerifier.Verify(licenseData)
+	assert.ErrorIs(suite.T(), err, client.ErrRevoked)
+	_, err = scopedVerifier.LoadRevocationList(scopedListData)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(2), scopedVerifier.RevocationVersion(auth.ID))
+	assert.Equal(suite.T(), int64(3), scopedVerifier.RevocationVersion(otherAuth.ID))
+}
+
+func (suite *LicenseServiceTestSuite) TestRevocationStore() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-CRL-STORE-001",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	machineID := "a7b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"
+	_, err = suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "crl-store-host", MachineID: machineID, RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	oldList, err := suite.licenseService.GenerateRevocationList(0)
+	assert.NoError(suite.T(), err)
+	oldListData, err := json.Marshal(oldList)
+	assert.NoError(suite.T(), err)
+
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.licenseService.ForceUnbindLicense(licenses[0].ID, "测试"))
+
+	newList, err := suite.licenseService.GenerateRevocationList(0)
+	assert.NoError(suite.T(), err)
+	newListData, err := json.Marshal(newList)
+	assert.NoError(suite.T(), err)
+
+	publicKeyPEM, err := suite.rsaService.GetPublicKeyPEM()
+	assert.NoError(suite.T(), err)
+	storePath := filepath.Join(suite.T().TempDir(), "revocations.state")
+
+	verifier, err := client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithRevocationStore(client.NewFileRevocationStore(storePath, machineID)),
+		client.WithRevocationList(newListData),
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), verifier.RevocationVersion(0))
+
+	// 程序重启后仍不能导入旧版本的列表
+	_, err = client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithRevocationStore(client.NewFileRevocationStore(storePath, machineID)),
+		client.WithRevocationList(oldListData),
+	)
+	assert.ErrorIs(suite.T(), err, client.ErrCRLOutdated)
+
+	restarted, err := client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID(machineID),
+		client.WithRevocationStore(client.NewFileRevocationStore(storePath, machineID)),
+	)
+	assert.NoError(suite.T(), err)
+	_, err = restarted.LoadRevocationList(newListData)
+	assert.NoError(suite.T(), err)
+
+	// 版本记录复制到其他机器后无法使用
+	_, err = client.NewVerifier(
+		client.WithPublicKeyPEM("", publicKeyPEM),
+		client.WithMachineID("b7b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+		client.WithRevocationStore(client.NewFileRevocationStore(storePath, "b7b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")),
+		client.WithRevocationList(newListData),
+	)
+	assert.ErrorIs(suite.T(), err, client.ErrCRLStateInvalid)
+}
+
+func (suite *LicenseServiceTestSuite) TestDeactivateLicense() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-DEACTIVATE-001",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+	otherAuth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "其他客户",
+		AuthorizationCode: "TEST-DEACTIVATE-002",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "retired-host", MachineID: "a8b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	unbindFile, err := client.NewUnbindFile(&licenseFiles[0], "retired-host", "1.0.0", "设备下线")
+	assert.NoError(suite.T(), err)
+
+	// 解绑文件不能用于其他授权码
+	_, err = suite.licenseService.DeactivateLicense(otherAuth.AuthorizationCode, *unbindFile)
+	assert.Error(suite.T(), err)
+
+	// 席位已满时无法激活新设备
+	_, err = suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "new-host", MachineID: "b8b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.Error(suite.T(), err)
+
+	license, err := suite.licenseService.DeactivateLicense(auth.AuthorizationCode, *unbindFile)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseStatusUnbound, license.Status)
+	assert.NotNil(suite.T(), license.UnboundAt)
+
+	updatedAuth, err := suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, updatedAuth.UsedSeats)
+
+	// 同一个解绑文件不能重复使用
+	_, err = suite.licenseService.DeactivateLicense(auth.AuthorizationCode, *unbindFile)
+	assert.Equal(suite.T(), errors.ErrLicenseNotFound, err)
+
+	// 归还的席位可以激活新设备
+	_, err = suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "new-host", MachineID: "b8b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *LicenseServiceTestSuite) TestReconcileSeats() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-RECONCILE-001",
+		MaxSeats:          5,
+	})
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "seat-host-1", MachineID: "a7b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+		{Hostname: "seat-host-2", MachineID: "b7b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	// 免席位的试用授权不计入已用席位
+	_, _, err = suite.licenseService.IssueTrialLicense(auth.ID, services.BindFile{
+		Hostname: "seat-host-3", MachineID: "c7b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, services.TrialLicenseOptions{})
+	assert.NoError(suite.T(), err)
+
+	discrepancies, err := suite.authService.ReconcileSeats(false)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), discrepancies)
+
+	// 重复强制解绑只释放一次席位
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	var seatLicenseID uint
+	for _, license := range licenses {
+		if license.ConsumesSeat() {
+			seatLicenseID = license.ID
+		}
+	}
+	assert.NoError(suite.T(), suite.licenseService.ForceUnbindLicense(seatLicenseID, "测试"))
+	assert.Error(suite.T(), suite.licenseService.ForceUnbindLicense(seatLicenseID, "测试"))
+
+	updatedAuth, err := suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, updatedAuth.UsedSeats)
+
+	// 模拟计数漂移
+	err = database.GetDB().Model(updatedAuth).Update("used_seats", 4).Error
+	assert.NoError(suite.T(), err)
+
+	discrepancies, err = suite.authService.ReconcileSeats(false)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), discrepancies, 1)
+	assert.Equal(suite.T(), 4, discrepancies[0].RecordedSeats)
+	assert.Equal(suite.T(), 1, discrepancies[0].ActualSeats)
+	assert.False(suite.T(), discrepancies[0].Fixed)
+
+	discrepancies, err = suite.authService.ReconcileSeats(true)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), discrepancies, 1)
+	assert.True(suite.T(), discrepancies[0].Fixed)
+
+	updatedAuth, err = suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, updatedAuth.UsedSeats)
+
+	discrepancies, err = suite.authService.ReconcileSeats(false)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), discrepancies)
+}
+
+func (suite *LicenseServiceTestSuite) TestSeatLedger() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-LEDGER-001",
+		MaxSeats:          5,
+	})
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "ledger-host-1", MachineID: "d7b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+		{Hostname: "ledger-host-2", MachineID: "e7b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), licenses, 2)
+
+	adminID := uint(7)
+	actor := services.Actor{Type: models.SeatActorAdmin, ID: &adminID, Name: "admin", IPAddress: "127.0.0.1"}
+	err = suite.licenseService.WithActor(actor).ForceUnbindLicense(licenses[0].ID, "设备报废")
+	assert.NoError(suite.T(), err)
+
+	maxSeats := 8
+	_, err = suite.authService.WithActor(actor).UpdateAuthorization(auth.ID, &services.UpdateAuthorizationRequest{
+		MaxSeats: &maxSeats,
+	})
+	assert.NoError(suite.T(), err)
+
+	entries, total, err := suite.authService.ListSeatLedger(auth.ID, 1, 20)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(4), total)
+	assert.Len(suite.T(), entries, 4)
+
+	// 按时间倒序返回
+	assert.Equal(suite.T(), models.SeatReasonMaxSeats, entries[0].Reason)
+	assert.Equal(suite.T(), 3, entries[0].MaxDelta)
+	assert.Equal(suite.T(), 8, entries[0].MaxSeats)
+	assert.Equal(suite.T(), 1, entries[0].UsedSeats)
+	assert.Equal(suite.T(), models.SeatActorAdmin, entries[0].ActorType)
+
+	assert.Equal(suite.T(), models.SeatReasonForceUnbind, entries[1].Reason)
+	assert.Equal(suite.T(), -1, entries[1].UsedDelta)
+	assert.Equal(suite.T(), 1, entries[1].UsedSeats)
+	assert.Equal(suite.T(), "设备报废", entries[1].Remark)
+	assert.NotNil(suite.T(), entries[1].ActorID)
+	assert.Equal(suite.T(), adminID, *entries[1].ActorID)
+	assert.NotNil(suite.T(), entries[1].LicenseID)
+	assert.Equal(suite.T(), licenses[0].ID, *entries[1].LicenseID)
+
+	for _, entry := range entries[2:] {
+		assert.Equal(suite.T(), models.SeatReasonActivate, entry.Reason)
+		assert.Equal(suite.T(), 1, entry.UsedDelta)
+		assert.Equal(suite.T(), models.SeatActorSystem, entry.ActorType)
+		assert.NotNil(suite.T(), entry.LicenseID)
+	}
+	assert.Equal(suite.T(), 2, entries[2].UsedSeats)
+	assert.Equal(suite.T(), 1, entries[3].UsedSeats)
+
+	entries, total, err = suite.authService.ListSeatLedger(auth.ID, 2, 3)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(4), total)
+	assert.Len(suite.T(), entries, 1)
+}
+
+func (suite *LicenseServiceTestSuite) TestUnbindMetadataPersisted() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-UNBIND-META-001",
+		MaxSeats:          3,
+	})
+	assert.NoError(suite.T(), err)
+
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "meta-host-1", MachineID: "f1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+		{Hostname: "meta-host-2", MachineID: "f2b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	// 客户凭解绑文件转移设备，保存解绑文件中的元数据
+	unbindFile, err := client.NewUnbindFile(&licenseFiles[0], "meta-host-1-renamed", "2.3.1", "更换服务器")
+	assert.NoError(suite.T(), err)
+	customerID := auth.ID
+	_, err = suite.licenseService.WithActor(services.Actor{
+		Type: models.SeatActorCustomer, ID: &customerID, Name: auth.AuthorizationCode,
+	}).TransferLicense(auth.AuthorizationCode, *unbindFile, services.BindFile{
+		Hostname: "meta-host-3", MachineID: "f3b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	})
+	assert.NoError(suite.T(), err)
+
+	var transferred models.License
+	err = database.GetDB().Where("license_key = ?", licenseFiles[0].LicenseData.LicenseKey).First(&transferred).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseStatusUnbound, transferred.Status)
+	assert.Equal(suite.T(), "更换服务器", transferred.UnbindReason)
+	assert.Equal(suite.T(), "meta-host-1-renamed", transferred.UnbindHostname)
+	assert.Equal(suite.T(), "2.3.1", transferred.UnbindClientVersion)
+	assert.NotNil(suite.T(), transferred.UnbindRequestedAt)
+	assert.Equal(suite.T(), models.SeatActorCustomer, transferred.UnboundByType)
+	assert.Equal(suite.T(), auth.AuthorizationCode, transferred.UnboundBy)
+
+	// 管理员强制解绑保存原因和管理员身份
+	var forced models.License
+	err = database.GetDB().Where("license_key = ?", licenseFiles[1].LicenseData.LicenseKey).First(&forced).Error
+	assert.NoError(suite.T(), err)
+	adminID := uint(3)
+	err = suite.licenseService.WithActor(services.Actor{
+		Type: models.SeatActorAdmin, ID: &adminID, Name: "ops-admin",
+	}).ForceUnbindLicense(forced.ID, "设备丢失")
+	assert.NoError(suite.T(), err)
+
+	err = database.GetDB().First(&forced, forced.ID).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseStatusForceUnbound, forced.Status)
+	assert.Equal(suite.T(), "设备丢失", forced.UnbindReason)
+	assert.Equal(suite.T(), models.SeatActorAdmin, forced.UnboundByType)
+	assert.NotNil(suite.T(), forced.UnboundByID)
+	assert.Equal(suite.T(), adminID, *forced.UnboundByID)
+	assert.Equal(suite.T(), "ops-admin", forced.UnboundBy)
+	assert.Empty(suite.T(), forced.UnbindClientVersion)
+}
+
+func (suite *LicenseServiceTestSuite) TestLicenseLineage() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-LINEAGE-001",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "lineage-host-1", MachineID: "a9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	// 连续两次更换硬件
+	currentFile := &licenseFiles[0]
+	machineIDs := []string{"b9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", "c9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"}
+	for i, machineID := range machineIDs {
+		unbindFile, err := client.NewUnbindFile(currentFile, currentFile.LicenseData.Hostname, "1.0.0", "更换硬件")
+		assert.NoError(suite.T(), err)
+		currentFile, err = suite.licenseService.TransferLicense(auth.AuthorizationCode, *unbindFile, services.BindFile{
+			Hostname: fmt.Sprintf("lineage-host-%d", i+2), MachineID: machineID, RequestTime: time.Now(),
+		})
+		assert.NoError(suite.T(), err)
+	}
+
+	var root models.License
+	err = database.GetDB().Where("machine_id = ?", "a9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4").First(&root).Error
+	assert.NoError(suite.T(), err)
+
+	// 从链路中任意一台设备都能查到完整链路
+	lineage, err := suite.licenseService.GetLicenseLineage(root.ID)
+	assert.NoError(suite.T(), err)
+	byMachine, err := suite.licenseService.GetLicenseLineageByMachineID(machineIDs[0])
+	assert.NoError(suite.T(), err)
+
+	for _, l := range []*services.LicenseLineage{lineage, byMachine} {
+		assert.Equal(suite.T(), root.ID, l.RootLicenseID)
+		assert.True(suite.T(), root.ActivatedAt.Equal(l.OriginalActivatedAt))
+		assert.Len(suite.T(), l.Licenses, 3)
+		assert.Equal(suite.T(), "c9b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", l.Licenses[2].MachineID)
+	}
+
+	assert.Nil(suite.T(), lineage.Licenses[0].PredecessorID)
+	assert.Equal(suite.T(), lineage.Licenses[1].ID, *lineage.Licenses[0].SuccessorID)
+	assert.Equal(suite.T(), lineage.Licenses[0].ID, *lineage.Licenses[1].PredecessorID)
+	assert.Equal(suite.T(), lineage.Licenses[2].ID, *lineage.Licenses[1].SuccessorID)
+	assert.Nil(suite.T(), lineage.Licenses[2].SuccessorID)
+	assert.Equal(suite.T(), models.LicenseStatusActive, lineage.Licenses[2].Status)
+
+	// 首次激活时间沿链路传递
+	latest := lineage.Licenses[2]
+	assert.NotNil(suite.T(), latest.OriginalActivatedAt)
+	assert.True(suite.T(), root.ActivatedAt.Equal(latest.FirstActivatedAt()))
+
+	_, err = suite.licenseService.GetLicenseLineageByMachineID("ffffffffffffffffffffffffffffffff")
+	assert.Equal(suite.T(), errors.ErrLicenseNotFound, err)
+}
+
+func (suite *LicenseServiceTestSuite) TestTransferPolicy() {
+	transfer := func(authCode string, licenseFile *services.LicenseFile, machineID string) (*services.LicenseFile, error) {
+		unbindFile, err := client.NewUnbindFile(licenseFile, licenseFile.LicenseData.Hostname, "1.0.0", "更换硬件")
+		assert.NoError(suite.T(), err)
+		return suite.licenseService.TransferLicense(authCode, *unbindFile, services.BindFile{
+			Hostname: "policy-" + machineID[:4], MachineID: machineID, RequestTime: time.Now(),
+		})
+	}
+
+	// 每个席位在周期内的转移次数
+	periodAuth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:          "测试客户",
+		AuthorizationCode:     "TEST-POLICY-PERIOD",
+		MaxSeats:              1,
+		MaxTransfersPerPeriod: 1,
+		TransferPeriodDays:    30,
+	})
+	assert.NoError(suite.T(), err)
+	licenseFiles, err := suite.licenseService.ActivateLicenses(periodAuth.AuthorizationCode, []services.BindFile{
+		{Hostname: "policy-host", MachineID: "aab2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	transferred, err := transfer(periodAuth.AuthorizationCode, &licenseFiles[0], "abb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+	assert.NoError(suite.T(), err)
+
+	var current models.License
+	err = database.GetDB().Where("license_key = ?", transferred.LicenseData.LicenseKey).First(&current).Error
+	assert.NoError(suite.T(), err)
+	allowance, err := suite.licenseService.GetTransferAllowance(periodAuth, &current)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, allowance.TransfersInPeriod)
+	assert.Equal(suite.T(), 0, *allowance.RemainingInPeriod)
+	assert.Nil(suite.T(), allowance.RemainingTotal)
+
+	_, err = transfer(periodAuth.AuthorizationCode, transferred, "acb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+	assert.Equal(suite.T(), errors.ErrTransferLimited, err)
+
+	// 上次转移已超出统计周期后可以再次转移
+	err = database.GetDB().Model(&current).Update("activated_at", time.Now().AddDate(0, 0, -31)).Error
+	assert.NoError(suite.T(), err)
+	_, err = transfer(periodAuth.AuthorizationCode, transferred, "acb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+	assert.NoError(suite.T(), err)
+
+	// 两次转移的最少间隔
+	cooldownAuth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:         "测试客户",
+		AuthorizationCode:    "TEST-POLICY-COOLDOWN",
+		MaxSeats:             1,
+		TransferCooldownDays: 7,
+	})
+	assert.NoError(suite.T(), err)
+	licenseFiles, err = suite.licenseService.ActivateLicenses(cooldownAuth.AuthorizationCode, []services.BindFile{
+		{Hostname: "policy-host", MachineID: "bab2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	transferred, err = transfer(cooldownAuth.AuthorizationCode, &licenseFiles[0], "bbb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+	assert.NoError(suite.T(), err)
+	_, err = transfer(cooldownAuth.AuthorizationCode, transferred, "bcb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+	assert.Equal(suite.T(), errors.ErrTransferCooldown, err)
+
+	err = database.GetDB().Model(&models.License{}).
+		Where("license_key = ?", transferred.LicenseData.LicenseKey).
+		Update("activated_at", time.Now().AddDate(0, 0, -8)).Error
+	assert.NoError(suite.T(), err)
+	_, err = transfer(cooldownAuth.AuthorizationCode, transferred, "bcb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+	assert.NoError(suite.T(), err)
+
+	// 授权码累计转移次数
+	totalAuth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-POLICY-TOTAL",
+		MaxSeats:          2,
+		MaxTotalTransfers: 1,
+	})
+	assert.NoError(suite.T(), err)
+	licenseFiles, err = suite.licenseService.ActivateLicenses(totalAuth.AuthorizationCode, []services.BindFile{
+		{Hostname: "policy-host-1", MachineID: "cab2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+		{Hostname: "policy-host-2", MachineID: "cbb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	_, err = transfer(totalAuth.AuthorizationCode, &licenseFiles[0], "ccb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+	assert.NoError(suite.T(), err)
+	_, err = transfer(totalAuth.AuthorizationCode, &licenseFiles[1], "cdb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+	assert.Equal(suite.T(), errors.ErrTransferLimited, err)
+
+	// 转移被拒绝时旧设备保持有效
+	var kept models.License
+	err = database.GetDB().Where("license_key = ?", licenseFiles[1].LicenseData.LicenseKey).First(&kept).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseStatusActive, kept.Status)
+}
+
+func (suite *LicenseServiceTestSuite) TestReplaceDevice() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-REPLACE-001",
+		MaxSeats:          2,
+	})
+	assert.NoError(suite.T(), err)
+
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "dead-host", MachineID: "dab2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+		{Hostname: "live-host", MachineID: "dbb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	var oldLicense models.License
+	err = database.GetDB().Where("license_key = ?", licenseFiles[0].LicenseData.LicenseKey).First(&oldLicense).Error
+	assert.NoError(suite.T(), err)
+
+	adminID := uint(5)
+	replacer := suite.licenseService.WithActor(services.Actor{
+		Type: models.SeatActorAdmin, ID: &adminID, Name: "ops-admin", IPAddress: "10.0.0.1",
+	})
+
+	// 新设备已被激活时整体回滚，旧设备保持有效
+	_, _, err = replacer.ReplaceDevice(oldLicense.ID, services.BindFile{
+		Hostname: "live-host", MachineID: "dbb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, "主板损坏")
+	assert.Equal(suite.T(), errors.ErrDuplicateMachine, err)
+	err = database.GetDB().First(&oldLicense, oldLicense.ID).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseStatusActive, oldLicense.Status)
+
+	// 授权码被禁用时不能替换设备
+	disabled, enabled := 0, 1
+	_, err = suite.authService.UpdateAuthorization(auth.ID, &services.UpdateAuthorizationRequest{Status: &disabled})
+	assert.NoError(suite.T(), err)
+	_, _, err = replacer.ReplaceDevice(oldLicense.ID, services.BindFile{
+		Hostname: "new-host", MachineID: "dcb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, "主板损坏")
+	assert.Equal(suite.T(), errors.ErrAuthCodeDisabled, err)
+	_, err = suite.authService.UpdateAuthorization(auth.ID, &services.UpdateAuthorizationRequest{Status: &enabled})
+	assert.NoError(suite.T(), err)
+
+	licenseFile, newLicense, err := replacer.ReplaceDevice(oldLicense.ID, services.BindFile{
+		Hostname: "new-host", MachineID: "dcb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, "主板损坏")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "dcb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", licenseFile.LicenseData.MachineID)
+	assert.True(suite.T(), oldLicense.ExpiresAt.Equal(newLicense.ExpiresAt))
+	assert.Equal(suite.T(), oldLicense.ID, *newLicense.PredecessorID)
+
+	err = database.GetDB().First(&oldLicense, oldLicense.ID).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LicenseStatusForceUnbound, oldLicense.Status)
+	assert.Equal(suite.T(), "主板损坏", oldLicense.UnbindReason)
+	assert.Equal(suite.T(), newLicense.ID, *oldLicense.SuccessorID)
+
+	// 席位数不变
+	updatedAuth, err := suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, updatedAuth.UsedSeats)
+
+	var logs []models.AdminLog
+	err = database.GetDB().Where("action = ?", models.LogActionReplaceDevice).Find(&logs).Error
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), logs, 1)
+	assert.Equal(suite.T(), adminID, *logs[0].AdminID)
+	assert.Equal(suite.T(), fmt.Sprintf("%d", oldLicense.ID), logs[0].TargetID)
+	assert.Equal(suite.T(), "10.0.0.1", logs[0].IPAddress)
+	assert.Contains(suite.T(), logs[0].Details, "dcb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")
+
+	// 已解绑的授权不能再次替换
+	_, _, err = replacer.ReplaceDevice(oldLicense.ID, services.BindFile{
+		Hostname: "other-host", MachineID: "ddb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, "主板损坏")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *LicenseServiceTestSuite) TestReplaceDeviceTransferAllowance() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:          "测试客户",
+		AuthorizationCode:     "TEST-REPLACE-ALLOWANCE",
+		MaxSeats:              1,
+		MaxTransfersPerPeriod: 1,
+		TransferPeriodDays:    30,
+		TransferCooldownDays:  7,
+		MaxTotalTransfers:     1,
+	})
+	assert.NoError(suite.T(), err)
+
+	licenseFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "broken-host", MachineID: "eab2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	var oldLicense models.License
+	err = database.GetDB().Where("license_key = ?", licenseFiles[0].LicenseData.LicenseKey).First(&oldLicense).Error
+	assert.NoError(suite.T(), err)
+	before, err := suite.licenseService.GetTransferAllowance(auth, &oldLicense)
+	assert.NoError(suite.T(), err)
+
+	adminID := uint(5)
+	replacer := suite.licenseService.WithActor(services.Actor{Type: models.SeatActorAdmin, ID: &adminID, Name: "ops-admin"})
+	replacedFile, replaced, err := replacer.ReplaceDevice(oldLicense.ID, services.BindFile{
+		Hostname: "spare-host", MachineID: "ebb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	}, "硬盘损坏")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.TransferTypeReplace, replaced.TransferType)
+
+	// 管理员替换不占用客户的转移额度，也不触发冷却期
+	after, err := suite.licenseService.GetTransferAllowance(auth, replaced)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, after.TransfersInPeriod)
+	assert.Equal(suite.T(), *before.RemainingInPeriod, *after.RemainingInPeriod)
+	assert.Equal(suite.T(), *before.RemainingTotal, *after.RemainingTotal)
+	assert.Nil(suite.T(), after.NextTransferAt)
+
+	// 客户仍可转移替换后的设备，转移计入额度
+	unbindFile, err := client.NewUnbindFile(replacedFile, "spare-host", "1.0.0", "更换硬件")
+	assert.NoError(suite.T(), err)
+	transferred, err := suite.licenseService.TransferLicense(auth.AuthorizationCode, *unbindFile, services.BindFile{
+		Hostname: "final-host", MachineID: "ecb2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now(),
+	})
+	assert.NoError(suite.T(), err)
+
+	var current models.License
+	err = database.GetDB().Where("license_key = ?", transferred.LicenseData.LicenseKey).First(&current).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.TransferTypeCustomer, current.TransferType)
+	allowance, err := suite.licenseService.GetTransferAllowance(auth, &current)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, allowance.TransfersInPeriod)
+	assert.Equal(suite.T(), 0, *allowance.RemainingTotal)
+	assert.NotNil(suite.T(), allowance.NextTransferAt)
+}
+
+func (suite *LicenseServiceTestSuite) TestActivateLicensesPartial() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-PARTIAL-001",
+		MaxSeats:          2,
+	})
+	assert.NoError(suite.T(), err)
+
+	encrypt := func(hostname, machineID string) string {
+		encrypted, err := suite.licenseService.EncryptBindFile(services.BindFile{
+			Hostname: hostname, MachineID: machineID, RequestTime: time.Now(),
+		})
+		assert.NoError(suite.T(), err)
+		return encrypted.EncryptedContent
+	}
+
+	encryptedBindFiles := []string{
+		encrypt("partial-host-1", "e1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+		"not-a-valid-bind-file",
+		encrypt("partial-host-1-copy", "e1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+		encrypt("partial-host-2", "e2b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+		encrypt("partial-host-3", "e3b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+	}
+
+	results, err := suite.licenseService.ActivateLicensesPartialEncrypted(auth.AuthorizationCode, encryptedBindFiles)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), results, 5)
+
+	for i, result := range results {
+		assert.Equal(suite.T(), i+1, result.Index)
+	}
+
+	assert.True(suite.T(), results[0].Success)
+	assert.NotNil(suite.T(), results[0].LicenseFile)
+
+	// 无法解密的文件
+	assert.False(suite.T(), results[1].Success)
+	assert.Equal(suite.T(), 41003, results[1].ErrorCode)
+	assert.NotEmpty(suite.T(), results[1].Message)
+	assert.Nil(suite.T(), results[1].LicenseFile)
+
+	// 同一批次中重复的机器按重新激活处理，不再消耗席位
+	assert.True(suite.T(), results[2].Success)
+	assert.True(suite.T(), results[2].Reissued)
+	assert.False(suite.T(), results[0].Reissued)
+
+	assert.True(suite.T(), results[3].Success)
+
+	// 席位用完后的文件
+	assert.False(suite.T(), results[4].Success)
+	assert.Equal(suite.T(), errors.ErrInsufficientSeats.Code, results[4].ErrorCode)
+	assert.Equal(suite.T(), "e3b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", results[4].MachineID)
+
+	updatedAuth, err := suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, updatedAuth.UsedSeats)
+
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), licenses, 2)
+
+	// 授权码无效时整批失败
+	_, err = suite.licenseService.ActivateLicensesPartialEncrypted("NOT-EXIST", encryptedBindFiles)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *LicenseServiceTestSuite) TestReactivateSameMachine() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-REACTIVATE-001",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+	otherAuth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "其他客户",
+		AuthorizationCode: "TEST-REACTIVATE-002",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	bindFile := services.BindFile{Hostname: "lost-file-host", MachineID: "f4b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()}
+	first, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{bindFile})
+	assert.NoError(suite.T(), err)
+
+	// 席位已满时重新上传同一设备的绑定文件，返回原授权且不消耗席位
+	bindFile.RequestTime = time.Now()
+	second, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{bindFile})
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), second, 1)
+	assert.Equal(suite.T(), first[0].LicenseData.LicenseKey, second[0].LicenseData.LicenseKey)
+	assert.Equal(suite.T(), first[0].LicenseData.UnbindPrivateKey, second[0].LicenseData.UnbindPrivateKey)
+	assert.True(suite.T(), first[0].LicenseData.ExpiresAt.Equal(second[0].LicenseData.ExpiresAt))
+
+	updatedAuth, err := suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, updatedAuth.UsedSeats)
+
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), licenses, 1)
+
+	// 原授权生成的解绑文件仍然有效
+	unbindFile, err := client.NewUnbindFile(&second[0], "lost-file-host", "1.0.0", "测试")
+	assert.NoError(suite.T(), err)
+
+	// 其他授权码不能激活该设备
+	_, err = suite.licenseService.ActivateLicenses(otherAuth.AuthorizationCode, []services.BindFile{bindFile})
+	assert.Equal(suite.T(), errors.ErrDuplicateMachine, err)
+
+	_, err = suite.licenseService.DeactivateLicense(auth.AuthorizationCode, *unbindFile)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *LicenseServiceTestSuite) TestPreviewActivation() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-PREVIEW-001",
+		MaxSeats:          2,
+	})
+	assert.NoError(suite.T(), err)
+	otherAuth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "其他客户",
+		AuthorizationCode: "TEST-PREVIEW-002",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "preview-existing", MachineID: "a5b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+	_, err = suite.licenseService.ActivateLicenses(otherAuth.AuthorizationCode, []services.BindFile{
+		{Hostname: "preview-other", MachineID: "b5b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	encrypt := func(hostname, machineID string) string {
+		encrypted, err := suite.licenseService.EncryptBindFile(services.BindFile{
+			Hostname: hostname, MachineID: machineID, RequestTime: time.Now(),
+		})
+		assert.NoError(suite.T(), err)
+		return encrypted.EncryptedContent
+	}
+
+	preview, err := suite.licenseService.PreviewActivation(auth.AuthorizationCode, []string{
+		encrypt("preview-existing", "a5b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+		encrypt("preview-other", "b5b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+		encrypt("preview-new-1", "c5b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+		encrypt("preview-new-2", "d5b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4"),
+		"invalid",
+	})
+	assert.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), 1, preview.AvailableSeats)
+	assert.Equal(suite.T(), 1, preview.SeatsRequired)
+	assert.Equal(suite.T(), 0, preview.SeatsRemaining)
+	assert.False(suite.T(), preview.CanActivateAll)
+	assert.Len(suite.T(), preview.Items, 5)
+
+	assert.Equal(suite.T(), services.PreviewActionReissue, preview.Items[0].Action)
+	assert.NotNil(suite.T(), preview.Items[0].ExpiresAt)
+
+	assert.Equal(suite.T(), services.PreviewActionReject, preview.Items[1].Action)
+	assert.Equal(suite.T(), errors.ErrDuplicateMachine.Code, preview.Items[1].ErrorCode)
+
+	assert.Equal(suite.T(), services.PreviewActionActivate, preview.Items[2].Action)
+	assert.NotNil(suite.T(), preview.Items[2].ExpiresAt)
+	assert.WithinDuration(suite.T(), time.Now().AddDate(1, 0, 0), *preview.Items[2].ExpiresAt, time.Minute)
+
+	assert.Equal(suite.T(), services.PreviewActionReject, preview.Items[3].Action)
+	assert.Equal(suite.T(), errors.ErrInsufficientSeats.Code, preview.Items[3].ErrorCode)
+	assert.Nil(suite.T(), preview.Items[3].ExpiresAt)
+
+	assert.Equal(suite.T(), services.PreviewActionReject, preview.Items[4].Action)
+	assert.Equal(suite.T(), 41003, preview.Items[4].ErrorCode)
+
+	// 预检不写入任何数据
+	licenses, err := suite.licenseService.GetLicensesByAuth(auth.AuthorizationCode)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), licenses, 1)
+	updatedAuth, err := suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, updatedAuth.UsedSeats)
+}
+
+func (suite *LicenseServiceTestSuite) TestActivationJob() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-JOB-001",
+		MaxSeats:          3,
+	})
+	assert.NoError(suite.T(), err)
+	otherAuth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "其他客户",
+		AuthorizationCode: "TEST-JOB-002",
+		MaxSeats:          1,
+	})
+	assert.NoError(suite.T(), err)
+
+	encrypt := func(hostname, machineID string) string {
+		encrypted, err := suite.licenseService.EncryptBindFile(services.BindFile{
+			Hostname: hostname, MachineID: machineID, RequestTime: time.Now(),
+		})
+		assert.NoError(suite.T(), err)
+		return encrypted.EncryptedContent
+	}
+
+	jobService := services.NewActivationJobService()
+	job, err := jobService.CreateJob(auth.AuthorizationCode, []services.ActivationJobFile{
+		{Filename: "a.bind", Content: encrypt("job-host-1", "a6b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")},
+		{Filename: "b.bind", Content: "invalid"},
+		{Filename: "c.bind", Content: encrypt("job-host-2", "b6b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4")},
+	}, "127.0.0.1")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.ActivationJobPending, job.Status)
+	assert.Equal(suite.T(), 3, job.Total)
+
+	// 未完成的任务不能下载结果，其他授权码看不到该任务
+	_, err = jobService.GetJobResults(auth.ID, job.JobID)
+	assert.Equal(suite.T(), errors.ErrJobNotFinished, err)
+	_, err = jobService.GetJob(otherAuth.ID, job.JobID)
+	assert.Equal(suite.T(), errors.ErrJobNotFound, err)
+
+	// 模拟其他实例正在处理：心跳未超时的任务不会被接管
+	err = database.GetDB().Model(&models.ActivationJob{}).Where("job_id = ?", job.JobID).
+		Updates(map[string]interface{}{
+			"status":       models.ActivationJobRunning,
+			"worker_id":    "other-worker",
+			"heartbeat_at": time.Now(),
+		}).Error
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), jobService.ResumeInterruptedJobs())
+	assert.NoError(suite.T(), jobService.ProcessPendingJobs(context.Background()))
+	stuck, err := jobService.GetJob(auth.ID, job.JobID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.ActivationJobRunning, stuck.Status)
+	assert.Equal(suite.T(), 0, stuck.Processed)
+
+	// 该实例中断后心跳超时，任务重新排队并由当前实例接管
+	err = database.GetDB().Model(&models.ActivationJob{}).Where("job_id = ?", job.JobID).
+		Update("heartbeat_at", time.Now().Add(-10*time.Minute)).Error
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), jobService.ResumeInterruptedJobs())
+	assert.NoError(suite.T(), jobService.ProcessPendingJobs(context.Background()))
+
+	finished, err := jobService.GetJob(auth.ID, job.JobID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.ActivationJobCompleted, finished.Status)
+	assert.Equal(suite.T(), 3, finished.Processed)
+	assert.Equal(suite.T(), 2, finished.Succeeded)
+	assert.Equal(suite.T(), 1, finished.Failed)
+	assert.NotNil(suite.T(), finished.FinishedAt)
+	assert.NotEqual(suite.T(), "other-worker", finished.WorkerID)
+	assert.True(suite.T(), finished.HeartbeatAt.After(time.Now().Add(-time.Minute)))
+
+	results, err := jobService.GetJobResults(auth.ID, job.JobID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), results, 3)
+	assert.True(suite.T(), results[0].Success)
+	assert.Equal(suite.T(), "a.bind", results[0].Filename)
+	assert.NotEmpty(suite.T(), results[0].LicenseFile.EncryptedContent)
+	assert.False(suite.T(), results[1].Success)
+	assert.Equal(suite.T(), 41003, results[1].ErrorCode)
+	assert.Nil(suite.T(), results[1].LicenseFile)
+	assert.True(suite.T(), results[2].Success)
+	assert.Equal(suite.T(), 3, results[2].Index)
+
+	// 再次处理不会重复激活
+	assert.NoError(suite.T(), jobService.ResumeInterruptedJobs())
+	assert.NoError(suite.T(), jobService.ProcessPendingJobs(context.Background()))
+	updatedAuth, err := suite.authService.GetAuthorizationByID(auth.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, updatedAuth.UsedSeats)
+}
+
+func (suite *LicenseServiceTestSuite) TestUnbindKeyTypes() {
+	auth, err := suite.authService.CreateAuthorization(&services.CreateAuthorizationRequest{
+		CustomerName:      "测试客户",
+		AuthorizationCode: "TEST-UNBIND-KEY-001",
+		MaxSeats:          3,
+	})
+	assert.NoError(suite.T(), err)
+
+	defaultKeyType := config.AppConfig.System.UnbindKeyType
+	defer func() { config.AppConfig.System.UnbindKeyType = defaultKeyType }()
+
+	config.AppConfig.System.UnbindKeyType = crypto.UnbindKeyTypeEd25519
+	ed25519Files, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "ed25519-host", MachineID: "a7c2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	config.AppConfig.System.UnbindKeyType = crypto.UnbindKeyTypeRSA
+	rsaFiles, err := suite.licenseService.ActivateLicenses(auth.AuthorizationCode, []services.BindFile{
+		{Hostname: "rsa-host", MachineID: "b7c2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+		{Hostname: "legacy-host", MachineID: "c7c2c3d4e5f6a1b2c3d4e5f6a1b2c3d4", RequestTime: time.Now()},
+	})
+	assert.NoError(suite.T(), err)
+
+	var ed25519License, rsaLicense models.License
+	assert.NoError(suite.T(), database.GetDB().Where("machine_id = ?", "a7c2c3d4e5f6a1b2c3d4e5f6a1b2c3d4").First(&ed25519License).Error)
+	assert.NoError(suite.T(), database.GetDB().Where("machine_id = ?", "b7c2c3d4e5f6a1b2c3d4e5f6a1b2c3d4").First(&rsaLicense).Error)
+	assert.Equal(suite.T(), crypto.UnbindKeyTypeEd25519, ed25519License.UnbindKeyType)
+	assert.Equal(suite.T(), crypto.UnbindKeyTypeRSA, rsaLicense.UnbindKeyType)
+
+	// 模拟升级前签发的授权：未记录密钥类型时按RSA验证
+	err = database.GetDB().Model(&models.License{}).Where("machine_id = ?", "c7c2c3d4e5f6a1b2c3d4e5f6a1b2c3d4").
+		Update("unbind_key_type", "").Error
+	assert.NoError(suite.T(), err)
+
+	for _, licenseFile := range []services.LicenseFile{ed25519Files[0], rsaFiles[0], rsaFiles[1]} {
+		unbindFile, err := client.NewUnbindFile(&licenseFile, licenseFile.LicenseData.Hostname, "1.0.0", "设备下线")
+		assert.NoError(suite.T(), err)
+
+		license, err := suite.licenseService.DeactivateLicense(auth.AuthorizationCode, *unbindFile)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), models.LicenseStatusUnbound, license.Status)
+	}
+
+	// 签名算法必须与授权记录的密钥类型一致
+	keyPair, err := crypto.GenerateUnbindKeyPair(crypto.UnbindKeyTypeEd25519)
+	assert.NoError(suite.T(), err)
+	signature, err := crypto.SignUnbindProof(keyPair.PrivateKeyPEM, []byte("payload"))
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), crypto.VerifyUnbindProof(crypto.UnbindKeyTypeEd25519, keyPair.PublicKeyPEM, []byte("payload"), signature))
+	assert.Error(suite.T(), crypto.VerifyUnbindProof(crypto.UnbindKeyTypeRSA, keyPair.PublicKeyPEM, []byte("payload"), signature))
+	assert.Error(suite.T(), crypto.VerifyUnbindProof(crypto.UnbindKeyTypeEd25519, keyPair.PublicKeyPEM, []byte("tampered"), signature))
+}
+
 func TestLicenseServiceSuite(t *testing.T) {
 	suite.Run(t, new(LicenseServiceTestSuite))
 }
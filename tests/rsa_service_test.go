@@ -1,11 +1,20 @@
 package tests
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/lyenrowe/LicenseCenter/internal/config"
 	"github.com/lyenrowe/LicenseCenter/internal/database"
+	"github.com/lyenrowe/LicenseCenter/internal/models"
 	"github.com/lyenrowe/LicenseCenter/internal/services"
+	"github.com/lyenrowe/LicenseCenter/pkg/crypto"
 	"github.com/lyenrowe/LicenseCenter/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -146,6 +155,355 @@ func (suite *RSAServiceTestSuite) TestEmptyDatabase() {
 	assert.Contains(suite.T(), publicKeyPEM, "-----BEGIN PUBLIC KEY-----")
 }
 
+func (suite *RSAServiceTestSuite) TestRotateKeysDecryptsOldFiles() {
+	// 使用轮换前的公钥生成文件
+	_, oldPublicKey, err := suite.rsaService.GenerateAndSaveKeyPair()
+	assert.NoError(suite.T(), err)
+	oldKeyID, err := crypto.KeyIDFromPublicKey(oldPublicKey)
+	assert.NoError(suite.T(), err)
+
+	plaintext := []byte(`{"machine_id":"ROTATE-TEST"}`)
+	encrypted, err := crypto.EncryptFileToBase64(oldPublicKey, plaintext)
+	assert.NoError(suite.T(), err)
+
+	// 旧版无密钥ID的文件
+	aesKey := crypto.GenerateClientAESKey("ROTATE-TEST")
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, oldPublicKey, aesKey, nil)
+	assert.NoError(suite.T(), err)
+	sealed, err := crypto.AESGCMEncrypt(plaintext, aesKey)
+	assert.NoError(suite.T(), err)
+	legacy := base64.StdEncoding.EncodeToString((&crypto.Envelope{EncryptedAESKey: wrappedKey, EncryptedData: sealed}).Marshal())
+
+	// 轮换密钥
+	err = suite.rsaService.RotateKeys()
+	assert.NoError(suite.T(), err)
+
+	activeKey, err := suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), oldKeyID, activeKey.KeyID)
+
+	// 宽限期内旧文件仍可解密
+	decrypted, _, err := suite.rsaService.DecryptFile(encrypted)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), plaintext, decrypted)
+
+	decrypted, extractedKey, err := suite.rsaService.DecryptFile(legacy)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), plaintext, decrypted)
+	assert.Equal(suite.T(), aesKey, extractedKey)
+
+	// 不能退役活跃密钥
+	err = suite.rsaService.RetireKey(activeKey.KeyID)
+	assert.Error(suite.T(), err)
+
+	// 退役后旧文件不再可解密
+	err = suite.rsaService.RetireKey(oldKeyID)
+	assert.NoError(suite.T(), err)
+
+	var oldKey models.RSAKey
+	err = database.GetDB().Where("key_id = ?", oldKeyID).First(&oldKey).Error
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.RSAKeyStatusRetired, oldKey.Status)
+
+	_, _, err = suite.rsaService.DecryptFile(encrypted)
+	assert.Error(suite.T(), err)
+	_, _, err = suite.rsaService.DecryptFile(legacy)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *RSAServiceTestSuite) TestKeyringCache() {
+	_, publicKey, err := suite.rsaService.GenerateAndSaveKeyPair()
+	assert.NoError(suite.T(), err)
+	keyID, err := crypto.KeyIDFromPublicKey(publicKey)
+	assert.NoError(suite.T(), err)
+
+	activeKey, err := suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), keyID, activeKey.KeyID)
+
+	// 模拟其他实例轮换密钥：写入新密钥并更新密钥环版本
+	keyPair, err := crypto.GenerateRSAKeyPair(2048)
+	assert.NoError(suite.T(), err)
+	privateKeyPEM, err := keyPair.PrivateKeyToPEM()
+	assert.NoError(suite.T(), err)
+	publicKeyPEM, err := keyPair.PublicKeyToPEM()
+	assert.NoError(suite.T(), err)
+	newKeyID, err := crypto.KeyIDFromPublicKey(keyPair.PublicKey)
+	assert.NoError(suite.T(), err)
+
+	db := database.GetDB()
+	assert.NoError(suite.T(), db.Model(&models.RSAKey{}).Where("key_id = ?", keyID).
+		Update("status", models.RSAKeyStatusRetiring).Error)
+	assert.NoError(suite.T(), db.Create(&models.RSAKey{
+		KeyID: newKeyID, PrivateKey: privateKeyPEM, PublicKey: publicKeyPEM, Status: models.RSAKeyStatusActive,
+	}).Error)
+	assert.NoError(suite.T(), db.Model(&models.SystemConfig{}).Where("config_key = ?", models.ConfigKeyringVersion).
+		Update("config_value", "other-instance").Error)
+
+	// 检查间隔内仍使用缓存的密钥，不查询数据库
+	activeKey, err = suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), keyID, activeKey.KeyID)
+
+	// 超过检查间隔后发现版本变化，重新加载
+	checkSeconds := config.AppConfig.Security.KeyringCheckSeconds
+	defer func() { config.AppConfig.Security.KeyringCheckSeconds = checkSeconds }()
+	config.AppConfig.Security.KeyringCheckSeconds = 1
+	time.Sleep(1100 * time.Millisecond)
+
+	activeKey, err = suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), newKeyID, activeKey.KeyID)
+
+	_, signKeyID, err := suite.rsaService.SignDataWithKeyID([]byte("test"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), newKeyID, signKeyID)
+
+	// 本实例轮换后立即生效
+	config.AppConfig.Security.KeyringCheckSeconds = checkSeconds
+	assert.NoError(suite.T(), suite.rsaService.RotateKeys())
+	activeKey, err = suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), newKeyID, activeKey.KeyID)
+}
+
+func (suite *RSAServiceTestSuite) TestSigningAlgorithms() {
+	algorithm := config.AppConfig.Security.SigningAlgorithm
+	defer func() { config.AppConfig.Security.SigningAlgorithm = algorithm }()
+
+	config.AppConfig.Security.SigningAlgorithm = crypto.SignatureRS256
+	rsaKey, err := suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+
+	data := []byte(`{"license_key":"signing-test"}`)
+	for _, alg := range []string{crypto.SignatureES256, crypto.SignatureEdDSA, crypto.SignaturePS256} {
+		config.AppConfig.Security.SigningAlgorithm = alg
+
+		signature, err := suite.rsaService.Sign(data)
+		assert.NoError(suite.T(), err, alg)
+		assert.Equal(suite.T(), alg, signature.Algorithm)
+		assert.NoError(suite.T(), suite.rsaService.VerifySignature(data, signature.Signature), alg)
+		assert.Error(suite.T(), suite.rsaService.VerifySignature([]byte("tampered"), signature.Signature), alg)
+
+		signingKey, err := suite.rsaService.GetActiveSigningKey()
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), signature.KeyID, signingKey.KeyID)
+		assert.Equal(suite.T(), alg, signingKey.Algorithm)
+
+		publicKey, err := crypto.ParsePublicKeyPEM(signingKey.PublicKey)
+		assert.NoError(suite.T(), err)
+		verifier, err := crypto.NewVerifier(alg, publicKey)
+		assert.NoError(suite.T(), err)
+		assert.NoError(suite.T(), verifier.Verify(data, signature.Signature))
+
+		// 签名密钥不能按其他算法验证
+		_, err = crypto.NewVerifier(crypto.SignatureEdDSA, publicKey)
+		if alg != crypto.SignatureEdDSA {
+			assert.Error(suite.T(), err, alg)
+		}
+	}
+
+	// EdDSA签名只有64字节，RSA-2048签名为256字节
+	config.AppConfig.Security.SigningAlgorithm = crypto.SignatureEdDSA
+	signature, err := suite.rsaService.Sign(data)
+	assert.NoError(suite.T(), err)
+	raw, err := base64.StdEncoding.DecodeString(signature.Signature)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), raw, 64)
+
+	// 签名专用密钥不用于加密文件；PS256为RSA算法，生成的新密钥同时替换加密密钥
+	activeKey, err := suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), rsaKey.KeyID, activeKey.KeyID)
+	assert.Equal(suite.T(), crypto.SignaturePS256, activeKey.Algorithm)
+
+	_, publicKey, err := suite.rsaService.GetActiveKeyPair()
+	assert.NoError(suite.T(), err)
+	encrypted, err := crypto.EncryptFileToBase64(publicKey, data)
+	assert.NoError(suite.T(), err)
+	decrypted, _, err := suite.rsaService.DecryptFile(encrypted)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), data, decrypted)
+
+	// 轮换时同时轮换签名专用密钥
+	edKey, err := suite.rsaService.GetActiveSigningKey()
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.rsaService.RotateKeys())
+	rotated, err := suite.rsaService.GetActiveSigningKey()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), crypto.SignatureEdDSA, rotated.Algorithm)
+	assert.NotEqual(suite.T(), edKey.KeyID, rotated.KeyID)
+}
+
+func (suite *RSAServiceTestSuite) TestImportCertificate() {
+	security := config.AppConfig.Security
+	defer func() { config.AppConfig.Security = security }()
+	config.AppConfig.Security.SigningAlgorithm = crypto.SignatureEdDSA
+
+	rootKey, err := crypto.GenerateSigningKeyPair(crypto.SignatureEdDSA, 0)
+	assert.NoError(suite.T(), err)
+	rootPath := filepath.Join(suite.T().TempDir(), "root.pub")
+	assert.NoError(suite.T(), os.WriteFile(rootPath, []byte(rootKey.PublicKeyPEM), 0644))
+	config.AppConfig.Security.RootPublicKeyFile = rootPath
+	config.AppConfig.Security.RootKeyAlgorithm = crypto.SignatureEdDSA
+
+	request, err := suite.rsaService.GetCertificateRequest("")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), crypto.SignatureEdDSA, request.Algorithm)
+	request.NotBefore = time.Now().Add(-time.Hour)
+	request.NotAfter = time.Now().Add(24 * time.Hour)
+
+	// 未配置根公钥时无法导入证书
+	config.AppConfig.Security.RootPublicKeyFile = ""
+	certificate, err := crypto.IssueCertificate(suite.rootSigner(rootKey), *request)
+	assert.NoError(suite.T(), err)
+	err = suite.rsaService.ImportCertificate(request.KeyID, certificate)
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "40035")
+	config.AppConfig.Security.RootPublicKeyFile = rootPath
+
+	// 非可信根密钥签发的证书无法导入
+	otherRoot, err := crypto.GenerateSigningKeyPair(crypto.SignatureEdDSA, 0)
+	assert.NoError(suite.T(), err)
+	certificate, err = crypto.IssueCertificate(suite.rootSigner(otherRoot), *request)
+	assert.NoError(suite.T(), err)
+	err = suite.rsaService.ImportCertificate(request.KeyID, certificate)
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "40035")
+
+	// 证书不能导入到其他密钥
+	certificate, err = crypto.IssueCertificate(suite.rootSigner(rootKey), *request)
+	assert.NoError(suite.T(), err)
+	rsaKey, err := suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+	assert.Error(suite.T(), suite.rsaService.ImportCertificate(rsaKey.KeyID, certificate))
+
+	// 导入后签名结果附带证书
+	signature, err := suite.rsaService.Sign([]byte("certified"))
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), signature.Certificate)
+
+	assert.NoError(suite.T(), suite.rsaService.ImportCertificate(request.KeyID, certificate))
+	signature, err = suite.rsaService.Sign([]byte("certified"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), request.KeyID, signature.KeyID)
+	assert.Equal(suite.T(), certificate, signature.Certificate)
+
+	cert, err := crypto.ParseCertificate(signature.Certificate)
+	assert.NoError(suite.T(), err)
+	publicKey, err := crypto.ParsePublicKeyPEM(rootKey.PublicKeyPEM)
+	assert.NoError(suite.T(), err)
+	root, err := crypto.NewVerifier(crypto.SignatureEdDSA, publicKey)
+	assert.NoError(suite.T(), err)
+	verifier, err := cert.Verify(root, time.Now())
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), verifier.Verify([]byte("certified"), signature.Signature))
+
+	// 签发时间不在证书有效期内
+	_, err = cert.Verify(root, time.Now().Add(48*time.Hour))
+	assert.Error(suite.T(), err)
+}
+
+func (suite *RSAServiceTestSuite) TestPrivateKeyEncryption() {
+	security := config.AppConfig.Security
+	defer func() {
+		// 恢复明文私钥配置，清除用测试主密钥加密的密钥
+		config.AppConfig.Security = security
+		database.GetDB().Exec("DELETE FROM rsa_keys")
+		_, _, err := suite.rsaService.GenerateAndSaveKeyPair()
+		assert.NoError(suite.T(), err)
+	}()
+
+	_, oldPublicKey, err := suite.rsaService.GenerateAndSaveKeyPair()
+	assert.NoError(suite.T(), err)
+	oldKeyID, err := crypto.KeyIDFromPublicKey(oldPublicKey)
+	assert.NoError(suite.T(), err)
+
+	// 未配置主密钥时不能重新加密
+	config.AppConfig.Security.MasterKeyFile = ""
+	config.AppConfig.Security.MasterKeyEnv = "LICENSE_MASTER_KEY_TEST_UNSET"
+	_, err = suite.rsaService.RewrapPrivateKeys()
+	assert.Error(suite.T(), err)
+
+	dir := suite.T().TempDir()
+	writeMasterKey := func(name string) string {
+		masterKey, err := crypto.GenerateMasterKey()
+		assert.NoError(suite.T(), err)
+		path := filepath.Join(dir, name)
+		assert.NoError(suite.T(), os.WriteFile(path, []byte(masterKey+"\n"), 0600))
+		return path
+	}
+
+	// 启用主密钥后加密现有的明文私钥
+	config.AppConfig.Security.MasterKeyFile = writeMasterKey("master.key")
+	count, err := suite.rsaService.RewrapPrivateKeys()
+	assert.NoError(suite.T(), err)
+	assert.Greater(suite.T(), count, 0)
+
+	var records []models.RSAKey
+	assert.NoError(suite.T(), database.GetDB().Find(&records).Error)
+	for _, record := range records {
+		assert.True(suite.T(), crypto.IsSealedPrivateKey(record.PrivateKey), record.KeyID)
+		assert.NotContains(suite.T(), record.PrivateKey, "PRIVATE KEY")
+	}
+
+	// 已加密的私钥不再重复加密
+	count, err = suite.rsaService.RewrapPrivateKeys()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	// 轮换后新密钥加密保存，宽限期内的旧密钥仍可解密
+	data := []byte(`{"license_key":"sealed-key-test"}`)
+	encrypted, err := crypto.EncryptFileToBase64(oldPublicKey, data)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.rsaService.RotateKeys())
+
+	activeKey, err := suite.rsaService.GetActiveKey()
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), oldKeyID, activeKey.KeyID)
+	var stored models.RSAKey
+	assert.NoError(suite.T(), database.GetDB().Where("key_id = ?", activeKey.KeyID).First(&stored).Error)
+	assert.True(suite.T(), crypto.IsSealedPrivateKey(stored.PrivateKey))
+
+	decrypted, _, err := suite.rsaService.DecryptFile(encrypted)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), data, decrypted)
+	signature, err := suite.rsaService.SignData(data)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.rsaService.VerifySignature(data, signature))
+
+	// 更换主密钥时需要提供旧主密钥
+	oldMasterKey, err := os.ReadFile(config.AppConfig.Security.MasterKeyFile)
+	assert.NoError(suite.T(), err)
+	previous, err := services.LoadLocalKeyWrapper(string(oldMasterKey))
+	assert.NoError(suite.T(), err)
+
+	config.AppConfig.Security.MasterKeyFile = writeMasterKey("master-new.key")
+	_, err = suite.rsaService.RewrapPrivateKeys()
+	assert.Error(suite.T(), err)
+
+	count, err = suite.rsaService.RewrapPrivateKeys(previous)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), len(records)+1, count)
+
+	assert.NoError(suite.T(), database.GetDB().Where("key_id = ?", activeKey.KeyID).First(&stored).Error)
+	assert.NotEqual(suite.T(), previous.KeyID(), crypto.SealedKeyID(stored.PrivateKey))
+	_, err = crypto.OpenPrivateKey(stored.PrivateKey, previous)
+	assert.Error(suite.T(), err)
+
+	_, _, err = suite.rsaService.GetKeyPairByKeyID(oldKeyID)
+	assert.NoError(suite.T(), err)
+}
+
+// rootSigner 创建根密钥签名器
+func (suite *RSAServiceTestSuite) rootSigner(keyPair *crypto.SigningKeyPair) crypto.Signer {
+	privateKey, err := crypto.ParsePrivateKeyPEM(keyPair.PrivateKeyPEM)
+	assert.NoError(suite.T(), err)
+	signer, err := crypto.NewSigner(keyPair.Algorithm, privateKey)
+	assert.NoError(suite.T(), err)
+	return signer
+}
+
 // 运行测试套件
 func TestRSAServiceSuite(t *testing.T) {
 	suite.Run(t, new(RSAServiceTestSuite))
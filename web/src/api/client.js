@@ -25,12 +25,34 @@ export function getDashboard() {
   })
 }
 
+// 激活预检，不做任何修改
+export function previewActivation(bindFiles) {
+  const formData = new FormData()
+  bindFiles.forEach((file) => {
+    formData.append('bind_files', file)
+  })
+
+  return request({
+    url: '/actions/activate-licenses/preview',
+    method: 'post',
+    data: formData,
+    headers: {
+      'Content-Type': 'multipart/form-data'
+    },
+    timeout: 60000
+  })
+}
+
 // 批量激活设备
-export function activateLicenses(bindFiles) {
+export function activateLicenses(bindFiles, partial = false) {
   const formData = new FormData()
   bindFiles.forEach((file) => {
     formData.append('bind_files', file)
   })
+  if (partial) {
+    // 部分成功模式：无效文件不影响其他设备，结果见ZIP中的manifest.json
+    formData.append('partial', 'true')
+  }
   
   return request({
     url: '/actions/activate-licenses',
@@ -58,6 +80,42 @@ export function activateLicenses(bindFiles) {
   })
 }
 
+// 提交异步批量激活任务，适用于大批量绑定文件
+export function createActivationJob(bindFiles) {
+  const formData = new FormData()
+  bindFiles.forEach((file) => {
+    formData.append('bind_files', file)
+  })
+
+  return request({
+    url: '/actions/activation-jobs',
+    method: 'post',
+    data: formData,
+    headers: {
+      'Content-Type': 'multipart/form-data'
+    },
+    timeout: 120000
+  })
+}
+
+// 查询异步激活任务进度
+export function getActivationJob(jobId) {
+  return request({
+    url: `/actions/activation-jobs/${jobId}`,
+    method: 'get'
+  })
+}
+
+// 下载异步激活任务的授权文件包
+export function downloadActivationJob(jobId) {
+  return request({
+    url: `/actions/activation-jobs/${jobId}/download`,
+    method: 'get',
+    responseType: 'blob', // 返回ZIP文件
+    timeout: 60000
+  })
+}
+
 // 转移授权
 export function transferLicense(unbindFile, bindFile) {
   const formData = new FormData()
@@ -90,6 +148,22 @@ export function transferLicense(unbindFile, bindFile) {
   })
 }
 
+// 停用设备（仅上传解绑文件，归还席位）
+export function deactivateLicense(unbindFile) {
+  const formData = new FormData()
+  formData.append('unbind_file', unbindFile)
+
+  return request({
+    url: '/actions/deactivate-license',
+    method: 'post',
+    data: formData,
+    headers: {
+      'Content-Type': 'multipart/form-data'
+    },
+    timeout: 30000
+  })
+}
+
 // 下载license文件
 export function downloadLicense(licenseId) {
   return request({